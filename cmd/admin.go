@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/internal/user/role"
+	"github.com/ncostamagna/axul-user/pkg/bootstrap"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"os"
+)
+
+const adminUsage = `usage: main admin USER_ID
+
+  gives the owner role of ADMIN_APP to the user, the first admin is
+  created with it and then the admins grant the roles with the API`
+
+// adminCommand runs the admin subcommand and returns the exit code
+func adminCommand(ctx context.Context, logger loghub.Logger, args []string) int {
+	app := os.Getenv("ADMIN_APP")
+	if len(args) != 1 || app == "" {
+		fmt.Println(adminUsage)
+		return 2
+	}
+
+	db, err := bootstrap.DBConnection()
+	if err != nil {
+		logger.Error(err)
+		return 1
+	}
+
+	if _, err := user.NewRepository(db, logger).Get(ctx, args[0]); err != nil {
+		logger.Error(err)
+		return 1
+	}
+
+	roleService := role.NewService(role.NewRepository(db, logger), nil, transaction.NewManager(db), logger)
+	if _, err := roleService.GetVersion(ctx, args[0], app); err != nil {
+		if !errors.As(err, &role.ErrUserAppNotFound{}) {
+			logger.Error(err)
+			return 1
+		}
+
+		if _, err := roleService.Create(ctx, args[0], app); err != nil {
+			logger.Error(err)
+			return 1
+		}
+	}
+
+	if err := roleService.AddRole(ctx, args[0], app, 0, []string{"owner"}); err != nil {
+		logger.Error(err)
+		return 1
+	}
+
+	fmt.Printf("%s is an admin of %s\n", args[0], app)
+	return 0
+}
@@ -3,17 +3,32 @@ package main
 import (
 	"github.com/joho/godotenv"
 	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/internal/user/accesstoken"
+	"github.com/ncostamagna/axul-user/internal/user/attribute"
+	"github.com/ncostamagna/axul-user/internal/user/emailchange"
+	"github.com/ncostamagna/axul-user/internal/user/identity"
+	"github.com/ncostamagna/axul-user/internal/user/impersonation"
+	"github.com/ncostamagna/axul-user/internal/user/passkey"
+	"github.com/ncostamagna/axul-user/internal/user/passwordless"
+	"github.com/ncostamagna/axul-user/internal/user/phoneverify"
+	"github.com/ncostamagna/axul-user/internal/user/photo"
 	"github.com/ncostamagna/axul-user/internal/user/role"
 	"github.com/ncostamagna/axul-user/pkg/bootstrap"
+	"github.com/ncostamagna/axul-user/pkg/cache"
 	"github.com/ncostamagna/axul-user/pkg/handler"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
 	authentication "github.com/ncostamagna/axul_auth/auth"
 	"time"
 
 	"context"
+	"errors"
+	"expvar"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	_ "time/tzdata"
 )
 
 func main() {
@@ -22,6 +37,17 @@ func main() {
 
 	_ = godotenv.Load()
 
+	flag.Parse()
+	ctx := context.Background()
+
+	if flag.Arg(0) == "migrate" {
+		os.Exit(migrateCommand(ctx, logger, flag.Args()[1:]))
+	}
+
+	if flag.Arg(0) == "admin" {
+		os.Exit(adminCommand(ctx, logger, flag.Args()[1:]))
+	}
+
 	logger.Info("DataBases")
 	db, err := bootstrap.DBConnection()
 	if err != nil {
@@ -29,9 +55,35 @@ func main() {
 		os.Exit(-1)
 	}
 
-	flag.Parse()
-	ctx := context.Background()
+	migrator, err := bootstrap.NewMigrator(db, logger)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(-1)
+	}
+
+	if os.Getenv("DATABASE_MIGRATE") == "true" {
+		if _, err := migrator.Up(ctx); err != nil {
+			logger.Error(err)
+			os.Exit(-1)
+		}
+	}
 
+	if err := migrator.Check(ctx); err != nil {
+		logger.Error(err)
+		os.Exit(-1)
+	}
+
+	if _, err := user.NormalizeLogins(ctx, db, logger); err != nil {
+		logger.Error(err)
+		os.Exit(-1)
+	}
+
+	if err := bootstrap.Replicas(db, logger); err != nil {
+		logger.Error(err)
+		os.Exit(-1)
+	}
+
+	adminApp := os.Getenv("ADMIN_APP")
 	token := os.Getenv("TOKEN")
 	auth, err := authentication.New(token)
 	if err != nil {
@@ -39,16 +91,149 @@ func main() {
 		os.Exit(-1)
 	}
 
+	var accessTokenService accesstoken.Service
+	{
+		repository := accesstoken.NewRepository(db, logger)
+		accessTokenService = accesstoken.NewService(repository, logger)
+	}
+
+	impersonationRepository := impersonation.NewRepository(db, logger)
+
+	lru, ttl := bootstrap.NewCache()
+	userCache := cache.NewStore("users", lru, ttl, logger)
+	roleCache := cache.NewStore("roles", lru, ttl, logger)
+	expvar.Publish("cache_users", userCache)
+	expvar.Publish("cache_roles", roleCache)
+
 	var service user.Service
 	{
-		repository := user.NewRepository(db, logger)
-		service = user.NewService(repository, auth, logger)
+		locales, err := bootstrap.NewLocales()
+		if err != nil {
+			logger.Error(err)
+			os.Exit(-1)
+		}
+
+		repository := user.NewCachedRepository(user.NewRepository(db, logger), userCache)
+		service = user.NewService(repository, auth, accessTokenService, impersonationRepository, locales, logger)
 	}
 
 	var roleService role.Service
 	{
-		repository := role.NewRepository(db, logger)
-		roleService = role.NewService(repository, service, logger)
+		repository := role.NewCachedRepository(role.NewRepository(db, logger), roleCache)
+		roleService = role.NewService(repository, service, transaction.NewManager(db), logger)
+	}
+
+	var impersonationService impersonation.Service
+	{
+		ttl, _ := strconv.Atoi(os.Getenv("IMPERSONATION_TTL"))
+		impersonationService = impersonation.NewService(impersonationRepository, service, roleService, impersonation.Config{
+			AdminApp: adminApp,
+			TTL:      time.Duration(ttl) * time.Second,
+		}, logger)
+	}
+
+	var identityService identity.Service
+	{
+		providers, err := bootstrap.IdentityProviders(ctx)
+		if err != nil {
+			logger.Error(err)
+			os.Exit(-1)
+		}
+
+		// the states are signed with their own key, a leaked token key
+		// mustn't let anyone forge a login
+		stateKey := os.Getenv("IDENTITY_STATE_KEY")
+		if len(providers) > 0 && stateKey == "" {
+			logger.Error(errors.New("IDENTITY_STATE_KEY is required when an identity provider is configured"))
+			os.Exit(-1)
+		}
+
+		repository := identity.NewRepository(db, logger)
+		identityService = identity.NewService(repository, service, providers, stateKey, logger)
+	}
+
+	mail := bootstrap.NewMailer(logger)
+
+	var passwordlessService passwordless.Service
+	{
+		ttl, _ := strconv.Atoi(os.Getenv("MAGIC_LOGIN_TTL"))
+		attempts, _ := strconv.Atoi(os.Getenv("MAGIC_LOGIN_MAX_ATTEMPTS"))
+		requests, _ := strconv.Atoi(os.Getenv("MAGIC_LOGIN_MAX_REQUESTS"))
+
+		repository := passwordless.NewRepository(db, logger)
+		passwordlessService = passwordless.NewService(repository, service, mail, passwordless.Config{
+			LinkURL:     os.Getenv("MAGIC_LOGIN_URL"),
+			TTL:         time.Duration(ttl) * time.Second,
+			MaxAttempts: attempts,
+			MaxRequests: requests,
+		}, logger)
+	}
+
+	var emailChangeService emailchange.Service
+	{
+		ttl, _ := strconv.Atoi(os.Getenv("EMAIL_CHANGE_TTL"))
+
+		repository := emailchange.NewRepository(db, logger)
+		emailChangeService = emailchange.NewService(repository, service, transaction.NewManager(db), mail, emailchange.Config{
+			ConfirmURL: os.Getenv("EMAIL_CHANGE_CONFIRM_URL"),
+			CancelURL:  os.Getenv("EMAIL_CHANGE_CANCEL_URL"),
+			TTL:        time.Duration(ttl) * time.Second,
+		}, logger)
+	}
+
+	var phoneVerifyService phoneverify.Service
+	{
+		ttl, _ := strconv.Atoi(os.Getenv("PHONE_VERIFICATION_TTL"))
+		attempts, _ := strconv.Atoi(os.Getenv("PHONE_VERIFICATION_MAX_ATTEMPTS"))
+		sends, _ := strconv.Atoi(os.Getenv("PHONE_VERIFICATION_MAX_SENDS"))
+
+		repository := phoneverify.NewRepository(db, logger)
+		phoneVerifyService = phoneverify.NewService(repository, service, bootstrap.NewSMSSender(logger), phoneverify.Config{
+			TTL:         time.Duration(ttl) * time.Second,
+			MaxAttempts: attempts,
+			MaxSends:    sends,
+		}, logger)
+	}
+
+	var photoService photo.Service
+	{
+		store, err := bootstrap.NewBlobStore()
+		if err != nil {
+			logger.Error(err)
+			os.Exit(-1)
+		}
+
+		maxSize, _ := strconv.ParseInt(os.Getenv("PHOTO_MAX_SIZE"), 10, 64)
+		photoService = photo.NewService(service, store, photo.Config{
+			BaseURL: os.Getenv("PHOTO_BASE_URL"),
+			MaxSize: maxSize,
+		}, logger)
+	}
+
+	var attributeService attribute.Service
+	{
+		schemas, err := attribute.LoadSchemas(os.Getenv("ATTRIBUTE_SCHEMAS_DIR"))
+		if err != nil {
+			logger.Error(err)
+			os.Exit(-1)
+		}
+
+		repository := attribute.NewRepository(db, logger)
+		attributeService = attribute.NewService(repository, service, schemas, logger)
+	}
+
+	var passkeyService passkey.Service
+	{
+		w, err := bootstrap.NewWebAuthn()
+		if err != nil {
+			logger.Error(err)
+			os.Exit(-1)
+		}
+
+		if w != nil {
+			repository := passkey.NewRepository(db, logger)
+			passkeyService = passkey.NewService(repository, service, w, logger)
+		}
 	}
 
 	pagLimDef := os.Getenv("PAGINATOR_LIMIT_DEFAULT")
@@ -57,13 +242,32 @@ func main() {
 		os.Exit(-1)
 	}
 
-	h := handler.NewHTTPServer(ctx, user.MakeEndpoints(service, user.Config{LimPageDef: pagLimDef}))
-	h = handler.NewHTTPRolesServer(ctx, h, role.MakeEndpoints(roleService))
+	h := handler.NewHTTPServer(ctx, user.MakeEndpoints(service, user.Config{
+		LimPageDef:   pagLimDef,
+		EmailChanges: emailChangeService,
+		Transactions: transaction.NewManager(db),
+		Admins:       roleService,
+		AdminApp:     adminApp,
+		Purger:       roleService,
+	}))
+	h = handler.NewHTTPRolesServer(ctx, h, role.MakeEndpoints(roleService, role.Config{LimPageDef: pagLimDef, AdminApp: adminApp}))
+	h = handler.NewHTTPErrorServer(ctx, h)
+	h = handler.NewHTTPIdentityServer(ctx, h, identity.MakeEndpoints(identityService, service))
+	h = handler.NewHTTPPasswordlessServer(ctx, h, passwordless.MakeEndpoints(passwordlessService))
+	h = handler.NewHTTPEmailChangeServer(ctx, h, emailchange.MakeEndpoints(emailChangeService))
+	h = handler.NewHTTPPhoneVerifyServer(ctx, h, phoneverify.MakeEndpoints(phoneVerifyService, service))
+	h = handler.NewHTTPPhotoServer(ctx, h, photo.MakeEndpoints(photoService, service))
+	h = handler.NewHTTPAttributeServer(ctx, h, attribute.MakeEndpoints(attributeService, service))
+	h = handler.NewHTTPAccessTokenServer(ctx, h, accesstoken.MakeEndpoints(accessTokenService, service))
+	h = handler.NewHTTPImpersonationServer(ctx, h, impersonation.MakeEndpoints(impersonationService, service))
+	if passkeyService != nil {
+		h = handler.NewHTTPPasskeyServer(ctx, h, passkey.MakeEndpoints(passkeyService, service))
+	}
 
 	url := os.Getenv("APP_URL")
 	fmt.Println(fmt.Sprintf("url:  %s", url))
 	srv := &http.Server{
-		Handler:      accessControl(h),
+		Handler:      handler.AccessControl(h),
 		Addr:         url,
 		WriteTimeout: 10 * time.Second,
 		ReadTimeout:  4 * time.Second,
@@ -71,6 +275,15 @@ func main() {
 
 	errs := make(chan error)
 
+	// the metrics of expvar, like the hits of the caches, are served in
+	// another address to keep them out of the public API
+	if debugURL := os.Getenv("DEBUG_URL"); debugURL != "" {
+		go func() {
+			logger.Info(fmt.Sprintf("debug vars on %s/debug/vars", debugURL))
+			errs <- http.ListenAndServe(debugURL, expvar.Handler())
+		}()
+	}
+
 	go func() {
 		fmt.Println(fmt.Sprintf("listening on %s", url))
 		logger.Info(fmt.Sprintf("listening on %s", url))
@@ -83,17 +296,3 @@ func main() {
 	}
 
 }
-
-func accessControl(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, HEAD")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept,Authorization,Cache-Control,Content-Type,DNT,If-Modified-Since,Keep-Alive,Origin,User-Agent,X-Requested-With")
-
-		if r.Method == "OPTIONS" {
-			return
-		}
-
-		h.ServeHTTP(w, r)
-	})
-}
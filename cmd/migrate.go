@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/migrations"
+	"github.com/ncostamagna/axul-user/pkg/bootstrap"
+	"github.com/ncostamagna/axul-user/pkg/migrate"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const migrateUsage = `usage: main migrate <command>
+
+  up           apply the pending migrations and fill the normalized
+               logins of the users
+  down [N]     revert the last N migrations, 1 by default
+  status       list the migrations and when they were applied
+  create NAME  add the files of a new migration to the directory of
+               every dialect in MIGRATIONS_DIR, migrations by default`
+
+// migrateCommand runs the migrate subcommand and returns the exit code
+func migrateCommand(ctx context.Context, logger loghub.Logger, args []string) int {
+	if len(args) == 0 {
+		fmt.Println(migrateUsage)
+		return 2
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			fmt.Println(migrateUsage)
+			return 2
+		}
+
+		root := os.Getenv("MIGRATIONS_DIR")
+		if root == "" {
+			root = "migrations"
+		}
+
+		var dirs []string
+		for _, dialect := range migrations.Dialects {
+			dirs = append(dirs, filepath.Join(root, dialect))
+		}
+
+		files, err := migrate.Create(dirs, args[1])
+		if err != nil {
+			logger.Error(err)
+			return 1
+		}
+
+		for _, f := range files {
+			fmt.Println("created", f)
+		}
+		return 0
+	}
+
+	db, err := bootstrap.DBConnection()
+	if err != nil {
+		logger.Error(err)
+		return 1
+	}
+
+	migrator, err := bootstrap.NewMigrator(db, logger)
+	if err != nil {
+		logger.Error(err)
+		return 1
+	}
+
+	switch args[0] {
+	case "up":
+		n, err := migrator.Up(ctx)
+		fmt.Printf("%d migrations applied\n", n)
+		if err != nil {
+			logger.Error(err)
+			return 1
+		}
+
+		if n, err = user.NormalizeLogins(ctx, db, logger); err != nil {
+			logger.Error(err)
+			return 1
+		}
+		fmt.Printf("%d users normalized\n", n)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				fmt.Println(migrateUsage)
+				return 2
+			}
+		}
+
+		n, err := migrator.Down(ctx, steps)
+		fmt.Printf("%d migrations reverted\n", n)
+		if err != nil {
+			logger.Error(err)
+			return 1
+		}
+
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			logger.Error(err)
+			return 1
+		}
+
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d  %-40s %s\n", s.Version, s.Name, applied)
+		}
+
+	default:
+		fmt.Println(migrateUsage)
+		return 2
+	}
+
+	return 0
+}
@@ -0,0 +1,39 @@
+package accesstoken
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Prefix identifies the personal access tokens, so they can be told apart
+// from session tokens and found by secret scanners
+const Prefix = "axul_pat_"
+
+// AccessToken is a personal access token, the token is shown only once
+// when it's created and only its hash is stored
+type AccessToken struct {
+	ID         string     `json:"id" gorm:"type:char(36);not null;primary_key;unique_index"`
+	UserID     string     `json:"user_id" gorm:"type:char(36);not null;index"`
+	Name       string     `json:"name" gorm:"type:char(70);not null"`
+	Hint       string     `json:"hint" gorm:"type:char(20);not null"`
+	Hash       string     `json:"-" gorm:"type:char(64);not null;uniqueIndex"`
+	Scopes     string     `json:"scopes" gorm:"type:varchar(255);not null"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"-"`
+}
+
+func (AccessToken) TableName() string {
+	return "access_tokens"
+}
+
+func (t *AccessToken) BeforeCreate(tx *gorm.DB) (err error) {
+
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return
+}
@@ -0,0 +1,122 @@
+package accesstoken
+
+import (
+	"context"
+	"errors"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/apierror"
+	"github.com/ncostamagna/go-http-utils/response"
+	"strings"
+)
+
+type (
+	UserReq struct {
+		ID            string `json:"id"`
+		TokenID       string `json:"token_id"`
+		Authorization string `json:"Authorization"`
+	}
+
+	CreateReq struct {
+		ID            string   `json:"id"`
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresIn     int      `json:"expires_in"`
+		Authorization string   `json:"Authorization"`
+	}
+
+	CreateRes struct {
+		*AccessToken
+		Token string `json:"token"`
+	}
+)
+
+type Controller func(ctx context.Context, request interface{}) (interface{}, error)
+
+// Endpoints struct
+type Endpoints struct {
+	Create Controller
+	GetAll Controller
+	Revoke Controller
+}
+
+func MakeEndpoints(s Service, userSrv user.Service) Endpoints {
+	return Endpoints{
+		Create: makeCreateEndpoint(s, userSrv),
+		GetAll: makeGetAllEndpoint(s, userSrv),
+		Revoke: makeRevokeEndpoint(s, userSrv),
+	}
+}
+
+func makeCreateEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(CreateReq)
+
+		if err := authorize(ctx, userSrv, req.Authorization, req.ID); err != nil {
+			return nil, err
+		}
+
+		if req.Name == "" {
+			return nil, apierror.BadRequest(ErrNameRequired)
+		}
+
+		token, plain, err := service.Create(ctx, req.ID, req.Name, req.Scopes, req.ExpiresIn)
+		if err != nil {
+			if err == ErrScopesRequired || errors.As(err, &ErrInvalidScope{}) || errors.As(err, &ErrInvalidExpiration{}) {
+				return nil, apierror.BadRequest(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.Created("", CreateRes{token, plain}, nil), nil
+	}
+}
+
+func makeGetAllEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UserReq)
+
+		if err := authorize(ctx, userSrv, req.Authorization, req.ID); err != nil {
+			return nil, err
+		}
+
+		tokens, err := service.GetAll(ctx, req.ID)
+		if err != nil {
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", tokens, nil), nil
+	}
+}
+
+func makeRevokeEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UserReq)
+
+		if err := authorize(ctx, userSrv, req.Authorization, req.ID); err != nil {
+			return nil, err
+		}
+
+		if err := service.Revoke(ctx, req.ID, req.TokenID); err != nil {
+			if errors.As(err, &ErrNotFound{}) {
+				return nil, apierror.NotFound(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", nil, nil), nil
+	}
+}
+
+// authorize only accepts session tokens, an access token can't mint or
+// revoke other access tokens
+func authorize(ctx context.Context, userSrv user.Service, token, id string) error {
+	if strings.HasPrefix(token, Prefix) {
+		return apierror.Forbidden(ErrSessionRequired)
+	}
+
+	if _, err := userSrv.Authorize(ctx, token, id, user.ScopeUsersWrite); err != nil {
+		return user.AuthorizationError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package accesstoken
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNameRequired = errors.New("name is required")
+var ErrScopesRequired = errors.New("scopes are required")
+var ErrSessionRequired = errors.New("access tokens must be managed with a session token")
+
+type ErrInvalidScope struct {
+	Scope string
+}
+
+func (e ErrInvalidScope) Error() string {
+	return fmt.Sprintf("the '%s' scope isn't valid", e.Scope)
+}
+
+type ErrInvalidExpiration struct {
+	Max int
+}
+
+func (e ErrInvalidExpiration) Error() string {
+	return fmt.Sprintf("expiration must be between 1 and %d days", e.Max)
+}
+
+type ErrNotFound struct {
+	UserID  string
+	TokenID string
+}
+
+func (e ErrNotFound) Error() string {
+	return fmt.Sprintf("access token '%s' of user '%s' doesn't exist", e.TokenID, e.UserID)
+}
@@ -0,0 +1,82 @@
+package accesstoken
+
+import (
+	"context"
+	"errors"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"gorm.io/gorm"
+	"time"
+)
+
+type Repository interface {
+	GetAll(ctx context.Context, userID string) ([]AccessToken, error)
+	GetByHash(ctx context.Context, hash string) (*AccessToken, error)
+	Create(ctx context.Context, token *AccessToken) error
+	Used(ctx context.Context, id string) error
+	Revoke(ctx context.Context, userID, id string) error
+}
+
+type repo struct {
+	db     *gorm.DB
+	logger loghub.Logger
+}
+
+func NewRepository(db *gorm.DB, logger loghub.Logger) Repository {
+	return &repo{db, logger}
+}
+
+func (r *repo) GetAll(ctx context.Context, userID string) ([]AccessToken, error) {
+	var tokens []AccessToken
+
+	if err := transaction.DB(ctx, r.db).Where("user_id = ?", userID).Order("created_at desc").Find(&tokens).Error; err != nil {
+		r.logger.Error(err)
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// GetByHash returns nil without error when the token doesn't exist
+func (r *repo) GetByHash(ctx context.Context, hash string) (*AccessToken, error) {
+	var token AccessToken
+
+	if err := transaction.DB(ctx, r.db).Where("hash = ?", hash).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		r.logger.Error(err)
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *repo) Create(ctx context.Context, token *AccessToken) error {
+	return transaction.DB(ctx, r.db).Create(token).Error
+}
+
+func (r *repo) Used(ctx context.Context, id string) error {
+	result := transaction.DB(ctx, r.db).Model(&AccessToken{}).Where("id = ?", id).UpdateColumn("last_used_at", time.Now())
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+func (r *repo) Revoke(ctx context.Context, userID, id string) error {
+	result := transaction.DB(ctx, r.db).Model(&AccessToken{}).Where("id = ? and user_id = ? and revoked_at is null", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound{userID, id}
+	}
+
+	return nil
+}
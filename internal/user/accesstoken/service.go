@@ -0,0 +1,144 @@
+package accesstoken
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/ncostamagna/axul-user/internal/user"
+	authentication "github.com/ncostamagna/axul_auth/auth"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"strings"
+	"time"
+)
+
+const (
+	defaultExpiration = 30
+	maxExpiration     = 365
+
+	// usedInterval avoids writing last_used_at on every request
+	usedInterval = time.Minute
+)
+
+type Service interface {
+	Create(ctx context.Context, userID, name string, scopes []string, expiresIn int) (*AccessToken, string, error)
+	GetAll(ctx context.Context, userID string) ([]AccessToken, error)
+	Revoke(ctx context.Context, userID, id string) error
+	Check(ctx context.Context, token string) (*user.TokenInfo, error)
+}
+
+type service struct {
+	repo   Repository
+	logger loghub.Logger
+}
+
+// NewService is a service handler, it's also the user.TokenChecker
+// for personal access tokens
+func NewService(repo Repository, logger loghub.Logger) Service {
+	return &service{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Create returns the stored token and the plain token, which can't be
+// recovered later. expiresIn is in days
+func (s *service) Create(ctx context.Context, userID, name string, scopes []string, expiresIn int) (*AccessToken, string, error) {
+	if len(scopes) == 0 {
+		return nil, "", ErrScopesRequired
+	}
+
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return nil, "", ErrInvalidScope{scope}
+		}
+	}
+
+	if expiresIn == 0 {
+		expiresIn = defaultExpiration
+	}
+
+	if expiresIn < 0 || expiresIn > maxExpiration {
+		return nil, "", ErrInvalidExpiration{maxExpiration}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		s.logger.Error(err)
+		return nil, "", err
+	}
+	plain := Prefix + hex.EncodeToString(secret)
+
+	token := AccessToken{
+		UserID:    userID,
+		Name:      name,
+		Hint:      plain[:len(Prefix)+6],
+		Hash:      hash(plain),
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: time.Now().AddDate(0, 0, expiresIn),
+	}
+
+	if err := s.repo.Create(ctx, &token); err != nil {
+		s.logger.Error(err)
+		return nil, "", err
+	}
+
+	s.logger.Info(fmt.Sprintf("Create access token %s for %s User", token.ID, userID))
+	return &token, plain, nil
+}
+
+func (s *service) GetAll(ctx context.Context, userID string) ([]AccessToken, error) {
+	return s.repo.GetAll(ctx, userID)
+}
+
+func (s *service) Revoke(ctx context.Context, userID, id string) error {
+	if err := s.repo.Revoke(ctx, userID, id); err != nil {
+		return err
+	}
+
+	s.logger.Info(fmt.Sprintf("Revoke access token %s of %s User", id, userID))
+	return nil
+}
+
+// Check validates a personal access token and records its use, it returns
+// nil info for tokens without the prefix
+func (s *service) Check(ctx context.Context, token string) (*user.TokenInfo, error) {
+	if !strings.HasPrefix(token, Prefix) {
+		return nil, nil
+	}
+
+	t, err := s.repo.GetByHash(ctx, hash(token))
+	if err != nil {
+		return nil, err
+	}
+
+	if t == nil || t.RevokedAt != nil || time.Now().After(t.ExpiresAt) {
+		return nil, authentication.ErrInvalidAuthentication
+	}
+
+	if t.LastUsedAt == nil || time.Since(*t.LastUsedAt) > usedInterval {
+		if err := s.repo.Used(ctx, t.ID); err != nil {
+			s.logger.Error(err)
+		}
+	}
+
+	return &user.TokenInfo{
+		UserID: t.UserID,
+		Scopes: strings.Split(t.Scopes, ","),
+	}, nil
+}
+
+func validScope(scope string) bool {
+	for _, s := range user.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func hash(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
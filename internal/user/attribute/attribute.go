@@ -0,0 +1,44 @@
+package attribute
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Attribute is the custom profile of a user in an app, Data is a JSON
+// object validated by the schema of the app
+type Attribute struct {
+	UserID    string          `json:"user_id" gorm:"type:char(36);not null;primary_key"`
+	App       string          `json:"app" gorm:"type:char(36);not null;primary_key"`
+	Data      json.RawMessage `json:"attributes" gorm:"type:text;not null"`
+	CreatedAt time.Time       `json:"-"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+func (Attribute) TableName() string {
+	return "user_attributes"
+}
+
+// merge applies a JSON merge patch (RFC 7396), a null value removes the key
+func merge(target, patch map[string]interface{}) map[string]interface{} {
+	if target == nil {
+		target = map[string]interface{}{}
+	}
+
+	for k, v := range patch {
+		if v == nil {
+			delete(target, k)
+			continue
+		}
+
+		if p, ok := v.(map[string]interface{}); ok {
+			t, _ := target[k].(map[string]interface{})
+			target[k] = merge(t, p)
+			continue
+		}
+
+		target[k] = v
+	}
+
+	return target
+}
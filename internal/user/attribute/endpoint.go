@@ -0,0 +1,83 @@
+package attribute
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/apierror"
+	"github.com/ncostamagna/go-http-utils/response"
+)
+
+type (
+	GetReq struct {
+		ID            string `json:"id"`
+		App           string `json:"app"`
+		Authorization string `json:"Authorization"`
+	}
+
+	PatchReq struct {
+		ID            string          `json:"id"`
+		App           string          `json:"app"`
+		Attributes    json.RawMessage `json:"-"`
+		Authorization string          `json:"Authorization"`
+	}
+)
+
+type Controller func(ctx context.Context, request interface{}) (interface{}, error)
+
+// Endpoints struct
+type Endpoints struct {
+	Get   Controller
+	Patch Controller
+}
+
+func MakeEndpoints(s Service, userSrv user.Service) Endpoints {
+	return Endpoints{
+		Get:   makeGetEndpoint(s, userSrv),
+		Patch: makePatchEndpoint(s, userSrv),
+	}
+}
+
+func makeGetEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetReq)
+
+		if _, err := userSrv.Authorize(ctx, req.Authorization, req.ID, user.ScopeUsersRead); err != nil {
+			return nil, user.AuthorizationError(err)
+		}
+
+		attribute, err := service.Get(ctx, req.ID, req.App)
+		if err != nil {
+			if errors.As(err, &ErrSchemaNotFound{}) {
+				return nil, apierror.NotFound(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", attribute, nil), nil
+	}
+}
+
+func makePatchEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(PatchReq)
+
+		if _, err := userSrv.Authorize(ctx, req.Authorization, req.ID, user.ScopeUsersWrite); err != nil {
+			return nil, user.AuthorizationError(err)
+		}
+
+		attribute, err := service.Patch(ctx, req.ID, req.App, req.Attributes)
+		if err != nil {
+			switch {
+			case errors.As(err, &ErrSchemaNotFound{}), errors.As(err, &user.ErrNotFound{}):
+				return nil, apierror.NotFound(err)
+			case err == ErrObjectRequired, errors.As(err, &ErrInvalidAttributes{}):
+				return nil, apierror.BadRequest(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", attribute, nil), nil
+	}
+}
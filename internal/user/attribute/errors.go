@@ -0,0 +1,26 @@
+package attribute
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrObjectRequired = errors.New("attributes must be a JSON object")
+
+type ErrSchemaNotFound struct {
+	App string
+}
+
+func (e ErrSchemaNotFound) Error() string {
+	return fmt.Sprintf("app '%s' doesn't have custom attributes", e.App)
+}
+
+// ErrInvalidAttributes lists the schema violations of the attributes
+type ErrInvalidAttributes struct {
+	Causes []string
+}
+
+func (e ErrInvalidAttributes) Error() string {
+	return fmt.Sprintf("invalid attributes: %s", strings.Join(e.Causes, "; "))
+}
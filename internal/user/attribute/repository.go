@@ -0,0 +1,47 @@
+package attribute
+
+import (
+	"context"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	Get(ctx context.Context, userID, app string) (*Attribute, error)
+	Save(ctx context.Context, attribute *Attribute) error
+}
+
+type repo struct {
+	db     *gorm.DB
+	logger loghub.Logger
+}
+
+func NewRepository(db *gorm.DB, logger loghub.Logger) Repository {
+	return &repo{db, logger}
+}
+
+// Get returns nil when the user doesn't have attributes in the app
+func (r *repo) Get(ctx context.Context, userID, app string) (*Attribute, error) {
+	var attributes []Attribute
+
+	if err := transaction.DB(ctx, r.db).Where("user_id = ? and app = ?", userID, app).Limit(1).Find(&attributes).Error; err != nil {
+		r.logger.Error(err)
+		return nil, err
+	}
+
+	if len(attributes) == 0 {
+		return nil, nil
+	}
+
+	return &attributes[0], nil
+}
+
+func (r *repo) Save(ctx context.Context, attribute *Attribute) error {
+	if err := transaction.DB(ctx, r.db).Save(attribute).Error; err != nil {
+		r.logger.Error(err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package attribute
+
+import (
+	"fmt"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Schemas are the JSON schemas of the apps attributes, keyed by app
+type Schemas map[string]*jsonschema.Schema
+
+// LoadSchemas compiles every <app>.json file of dir, a missing dir means
+// that no app has custom attributes
+func LoadSchemas(dir string) (Schemas, error) {
+	schemas := Schemas{}
+	if dir == "" {
+		return schemas, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		app := strings.TrimSuffix(filepath.Base(file), ".json")
+
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+
+		compiler := jsonschema.NewCompiler()
+		err = compiler.AddResource(file, f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("schema of app '%s': %w", app, err)
+		}
+
+		schema, err := compiler.Compile(file)
+		if err != nil {
+			return nil, fmt.Errorf("schema of app '%s': %w", app, err)
+		}
+
+		schemas[app] = schema
+	}
+
+	return schemas, nil
+}
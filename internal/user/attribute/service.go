@@ -0,0 +1,125 @@
+package attribute
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+type Service interface {
+	Get(ctx context.Context, userID, app string) (*Attribute, error)
+	Patch(ctx context.Context, userID, app string, patch json.RawMessage) (*Attribute, error)
+}
+
+type service struct {
+	repo    Repository
+	userSrv user.Service
+	schemas Schemas
+	logger  loghub.Logger
+}
+
+// NewService is a service handler
+func NewService(repo Repository, userSrv user.Service, schemas Schemas, logger loghub.Logger) Service {
+	return &service{
+		repo:    repo,
+		userSrv: userSrv,
+		schemas: schemas,
+		logger:  logger,
+	}
+}
+
+// Get returns the attributes of the user, an empty object when it doesn't
+// have them yet
+func (s *service) Get(ctx context.Context, userID, app string) (*Attribute, error) {
+	if _, ok := s.schemas[app]; !ok {
+		return nil, ErrSchemaNotFound{app}
+	}
+
+	attribute, err := s.repo.Get(ctx, userID, app)
+	if err != nil {
+		return nil, err
+	}
+
+	if attribute == nil {
+		return &Attribute{UserID: userID, App: app, Data: json.RawMessage("{}")}, nil
+	}
+
+	return attribute, nil
+}
+
+// Patch merges the patch into the attributes and validates the result
+// against the schema of the app before saving it
+func (s *service) Patch(ctx context.Context, userID, app string, patch json.RawMessage) (*Attribute, error) {
+	schema, ok := s.schemas[app]
+	if !ok {
+		return nil, ErrSchemaNotFound{app}
+	}
+
+	var p map[string]interface{}
+	if err := json.Unmarshal(patch, &p); err != nil || p == nil {
+		return nil, ErrObjectRequired
+	}
+
+	if _, err := s.userSrv.Get(ctx, userID, ""); err != nil {
+		return nil, user.ErrNotFound{UserID: userID}
+	}
+
+	attribute, err := s.Get(ctx, userID, app)
+	if err != nil {
+		return nil, err
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(attribute.Data, &data); err != nil {
+		return nil, err
+	}
+	data = merge(data, p)
+
+	if err := validate(schema, data); err != nil {
+		return nil, err
+	}
+
+	if attribute.Data, err = json.Marshal(data); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Save(ctx, attribute); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info(fmt.Sprintf("Update %s attributes of %s User", app, userID))
+	return attribute, nil
+}
+
+func validate(schema *jsonschema.Schema, data map[string]interface{}) error {
+	// the validator expects the types of json.Unmarshal into interface{}
+	var v interface{} = data
+
+	err := schema.Validate(v)
+	if err == nil {
+		return nil
+	}
+
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return err
+	}
+
+	var causes []string
+	for _, e := range verr.BasicOutput().Errors {
+		if e.Error == "" || len(e.KeywordLocation) == 0 {
+			continue
+		}
+		location := e.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		causes = append(causes, fmt.Sprintf("%s: %s", location, e.Error))
+	}
+
+	return ErrInvalidAttributes{causes}
+}
@@ -0,0 +1,106 @@
+package user
+
+import (
+	"context"
+	"github.com/ncostamagna/axul-user/pkg/cache"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	domain "github.com/ncostamagna/axul_domain/domain/user"
+)
+
+// cachedRepo caches the lookups of a user by id, the ones of the token
+// checks and of GET /users. The writes delete the keys of the user, again
+// after the commit when they are in a transaction, and the calls in a
+// transaction don't use the cache
+type cachedRepo struct {
+	Repository
+	store *cache.Store
+}
+
+// NewCachedRepository decorates repo with the cache of store
+func NewCachedRepository(repo Repository, store *cache.Store) Repository {
+	return &cachedRepo{repo, store}
+}
+
+func (r *cachedRepo) Get(ctx context.Context, id string) (*domain.User, error) {
+	if transaction.Active(ctx) {
+		return r.Repository.Get(ctx, id)
+	}
+
+	return cache.Load(ctx, r.store, id, func(ctx context.Context) (*domain.User, error) {
+		return r.Repository.Get(ctx, id)
+	})
+}
+
+func (r *cachedRepo) GetVersion(ctx context.Context, id string) (int, error) {
+	if transaction.Active(ctx) {
+		return r.Repository.GetVersion(ctx, id)
+	}
+
+	return cache.Load(ctx, r.store, "version:"+id, func(ctx context.Context) (int, error) {
+		return r.Repository.GetVersion(ctx, id)
+	})
+}
+
+func (r *cachedRepo) GetPhone(ctx context.Context, userID string) (*Phone, error) {
+	if transaction.Active(ctx) {
+		return r.Repository.GetPhone(ctx, userID)
+	}
+
+	return cache.Load(ctx, r.store, "phone:"+userID, func(ctx context.Context) (*Phone, error) {
+		return r.Repository.GetPhone(ctx, userID)
+	})
+}
+
+func (r *cachedRepo) GetLocale(ctx context.Context, userID string) (*Locale, error) {
+	if transaction.Active(ctx) {
+		return r.Repository.GetLocale(ctx, userID)
+	}
+
+	return cache.Load(ctx, r.store, "locale:"+userID, func(ctx context.Context) (*Locale, error) {
+		return r.Repository.GetLocale(ctx, userID)
+	})
+}
+
+func (r *cachedRepo) Create(ctx context.Context, user *domain.User, locale *Locale) error {
+	if err := r.Repository.Create(ctx, user, locale); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, user.ID)
+	return nil
+}
+
+func (r *cachedRepo) CreateServiceAccount(ctx context.Context, user *domain.User, account *ServiceAccount) error {
+	if err := r.Repository.CreateServiceAccount(ctx, user, account); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, user.ID)
+	return nil
+}
+
+func (r *cachedRepo) VerifyPhone(ctx context.Context, userID, number string) error {
+	defer r.invalidate(ctx, userID)
+	return r.Repository.VerifyPhone(ctx, userID, number)
+}
+
+func (r *cachedRepo) Update(ctx context.Context, id string, version int, firstname, lastname, email, phone, photo, language, timezone, password *string) error {
+	defer r.invalidate(ctx, id)
+	return r.Repository.Update(ctx, id, version, firstname, lastname, email, phone, photo, language, timezone, password)
+}
+
+func (r *cachedRepo) Delete(ctx context.Context, id string) error {
+	defer r.invalidate(ctx, id)
+	return r.Repository.Delete(ctx, id)
+}
+
+// invalidate deletes the keys now and after the commit, a request that
+// reads the user before the commit can't keep the old values
+func (r *cachedRepo) invalidate(ctx context.Context, id string) {
+	keys := []string{id, "version:" + id, "phone:" + id, "locale:" + id}
+
+	r.store.Delete(ctx, keys...)
+	if transaction.Active(ctx) {
+		transaction.AfterCommit(ctx, func() { r.store.Delete(ctx, keys...) })
+	}
+}
@@ -0,0 +1,40 @@
+package emailchange
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Change is a pending email change, the new address confirms it and the
+// old one can cancel it. Only the hashes of the secrets are stored
+type Change struct {
+	ID          string     `json:"id" gorm:"type:char(36);not null;primary_key;unique_index"`
+	UserID      string     `json:"user_id" gorm:"type:char(36);not null;index"`
+	OldEmail    string     `json:"old_email" gorm:"type:char(70)"`
+	NewEmail    string     `json:"new_email" gorm:"type:char(70);not null"`
+	ConfirmHash string     `json:"-" gorm:"type:char(64);not null"`
+	CancelHash  string     `json:"-" gorm:"type:char(64);not null"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	CanceledAt  *time.Time `json:"canceled_at"`
+	CreatedAt   time.Time  `json:"-"`
+	UpdatedAt   time.Time  `json:"-"`
+}
+
+func (Change) TableName() string {
+	return "email_changes"
+}
+
+func (c *Change) BeforeCreate(tx *gorm.DB) (err error) {
+
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return
+}
+
+// Pending is true while the change can still be confirmed
+func (c *Change) Pending() bool {
+	return c.ConfirmedAt == nil && c.CanceledAt == nil && time.Now().Before(c.ExpiresAt)
+}
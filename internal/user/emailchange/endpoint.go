@@ -0,0 +1,75 @@
+package emailchange
+
+import (
+	"context"
+	"errors"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/apierror"
+	"github.com/ncostamagna/go-http-utils/response"
+	"strings"
+)
+
+type (
+	TokenReq struct {
+		Token string `json:"token"`
+	}
+)
+
+type Controller func(ctx context.Context, request interface{}) (interface{}, error)
+
+// Endpoints struct
+type Endpoints struct {
+	Confirm Controller
+	Cancel  Controller
+}
+
+func MakeEndpoints(s Service) Endpoints {
+	return Endpoints{
+		Confirm: makeConfirmEndpoint(s),
+		Cancel:  makeCancelEndpoint(s),
+	}
+}
+
+func makeConfirmEndpoint(service Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(TokenReq)
+
+		id, secret, _ := strings.Cut(req.Token, ".")
+		if id == "" || secret == "" {
+			return nil, apierror.BadRequest(ErrTokenRequired)
+		}
+
+		u, err := service.Confirm(ctx, id, secret)
+		if err != nil {
+			if err == ErrInvalidToken {
+				return nil, apierror.BadRequest(err)
+			}
+			if errors.As(err, &user.ErrUserAlreadyExists{}) {
+				return nil, apierror.Conflict(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", u, nil), nil
+	}
+}
+
+func makeCancelEndpoint(service Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(TokenReq)
+
+		id, secret, _ := strings.Cut(req.Token, ".")
+		if id == "" || secret == "" {
+			return nil, apierror.BadRequest(ErrTokenRequired)
+		}
+
+		if err := service.Cancel(ctx, id, secret); err != nil {
+			if err == ErrInvalidToken {
+				return nil, apierror.BadRequest(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", nil, nil), nil
+	}
+}
@@ -0,0 +1,7 @@
+package emailchange
+
+import "errors"
+
+var ErrEmailRequired = errors.New("email is required")
+var ErrTokenRequired = errors.New("token is required")
+var ErrInvalidToken = errors.New("invalid or expired email change")
@@ -0,0 +1,98 @@
+package emailchange
+
+import (
+	"context"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"gorm.io/gorm"
+	"time"
+)
+
+type Repository interface {
+	Get(ctx context.Context, id string) (*Change, error)
+	GetPending(ctx context.Context, userID string) (*Change, error)
+	Create(ctx context.Context, change *Change) error
+	Confirm(ctx context.Context, id string) error
+	Cancel(ctx context.Context, id string) error
+}
+
+type repo struct {
+	db     *gorm.DB
+	logger loghub.Logger
+}
+
+func NewRepository(db *gorm.DB, logger loghub.Logger) Repository {
+	return &repo{db, logger}
+}
+
+func (r *repo) Get(ctx context.Context, id string) (*Change, error) {
+	var change Change
+
+	if err := transaction.DB(ctx, r.db).Where("id = ?", id).First(&change).Error; err != nil {
+		return nil, err
+	}
+
+	return &change, nil
+}
+
+// GetPending returns the last open change of the user, nil when there isn't
+func (r *repo) GetPending(ctx context.Context, userID string) (*Change, error) {
+	var changes []Change
+
+	result := transaction.DB(ctx, r.db).
+		Where("user_id = ? and confirmed_at is null and canceled_at is null and expires_at > ?", userID, time.Now()).
+		Order("created_at desc").Limit(1).Find(&changes)
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+		return nil, result.Error
+	}
+
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	return &changes[0], nil
+}
+
+// Create cancels the open changes of the user and stores the new one
+func (r *repo) Create(ctx context.Context, change *Change) error {
+	return transaction.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Change{}).Where("user_id = ? and confirmed_at is null and canceled_at is null", change.UserID).
+			Update("canceled_at", time.Now()).Error; err != nil {
+			r.logger.Error(err)
+			return err
+		}
+
+		if err := tx.Create(change).Error; err != nil {
+			r.logger.Error(err)
+			return err
+		}
+
+		return nil
+	})
+}
+
+// Confirm closes the change, it fails when it was already closed
+func (r *repo) Confirm(ctx context.Context, id string) error {
+	return r.close(ctx, id, "confirmed_at")
+}
+
+func (r *repo) Cancel(ctx context.Context, id string) error {
+	return r.close(ctx, id, "canceled_at")
+}
+
+func (r *repo) close(ctx context.Context, id, column string) error {
+	result := transaction.DB(ctx, r.db).Model(&Change{}).
+		Where("id = ? and confirmed_at is null and canceled_at is null", id).
+		Update(column, time.Now())
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
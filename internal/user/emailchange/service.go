@@ -0,0 +1,222 @@
+package emailchange
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/mailer"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	domain "github.com/ncostamagna/axul_domain/domain/user"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"net/url"
+	"time"
+)
+
+type Config struct {
+	ConfirmURL string
+	CancelURL  string
+	TTL        time.Duration
+}
+
+// Service implements user.EmailChanger, the user endpoints send the email
+// updates here instead of writing them
+type Service interface {
+	Request(ctx context.Context, userID, email string) error
+	Pending(ctx context.Context, userID string) (string, error)
+	Confirm(ctx context.Context, id, secret string) (*domain.User, error)
+	Cancel(ctx context.Context, id, secret string) error
+}
+
+type service struct {
+	repo    Repository
+	userSrv user.Service
+	tx      transaction.Manager
+	mailer  mailer.Mailer
+	config  Config
+	logger  loghub.Logger
+}
+
+// NewService is a service handler
+func NewService(repo Repository, userSrv user.Service, tx transaction.Manager, m mailer.Mailer, config Config, logger loghub.Logger) Service {
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+
+	return &service{
+		repo:    repo,
+		userSrv: userSrv,
+		tx:      tx,
+		mailer:  m,
+		config:  config,
+		logger:  logger,
+	}
+}
+
+// Request opens the change, it sends the confirmation link to the new
+// address and the cancel link to the current one when the transaction of
+// ctx is committed. The errors of the emails are only logged
+func (s *service) Request(ctx context.Context, userID, email string) error {
+	u, err := s.userSrv.Get(ctx, userID, "")
+	if err != nil {
+		return user.ErrNotFound{UserID: userID}
+	}
+
+	if user.Normalize(email) == user.Normalize(u.Email) {
+		return user.ErrSameEmail
+	}
+
+	users, err := s.userSrv.GetAll(ctx, user.Filters{Email: email}, 0, 0, "")
+	if err != nil {
+		return err
+	}
+
+	if len(users) > 0 {
+		return user.ErrUserAlreadyExists{Field: "email"}
+	}
+
+	confirm, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	cancel, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	change := Change{
+		UserID:      u.ID,
+		OldEmail:    u.Email,
+		NewEmail:    email,
+		ConfirmHash: hash(confirm),
+		CancelHash:  hash(cancel),
+		ExpiresAt:   time.Now().Add(s.config.TTL),
+	}
+
+	if err := s.repo.Create(ctx, &change); err != nil {
+		return err
+	}
+
+	messages := []mailer.Message{s.confirmMessage(u, &change, confirm)}
+	if change.OldEmail != "" {
+		messages = append(messages, s.cancelMessage(u, &change, cancel))
+	}
+
+	transaction.AfterCommit(ctx, func() {
+		for _, msg := range messages {
+			if err := s.mailer.Send(context.WithoutCancel(ctx), msg); err != nil {
+				s.logger.Error(err)
+			}
+		}
+	})
+
+	s.logger.Info(fmt.Sprintf("Email change %s requested for %s User", change.ID, u.ID))
+	return nil
+}
+
+// Pending returns the address waiting for confirmation, empty when there isn't
+func (s *service) Pending(ctx context.Context, userID string) (string, error) {
+	change, err := s.repo.GetPending(ctx, userID)
+	if err != nil || change == nil {
+		return "", err
+	}
+
+	return change.NewEmail, nil
+}
+
+// Confirm swaps the email of the user with the confirmed one, the change
+// stays pending when the email can't be updated
+func (s *service) Confirm(ctx context.Context, id, secret string) (*domain.User, error) {
+	change, err := s.change(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !equal(change.ConfirmHash, hash(secret)) {
+		return nil, ErrInvalidToken
+	}
+
+	err = s.tx.Do(ctx, func(ctx context.Context) error {
+		if err := s.repo.Confirm(ctx, change.ID); err != nil {
+			return err
+		}
+
+		return s.userSrv.Update(ctx, change.UserID, 0, nil, nil, &change.NewEmail, nil, nil, nil, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info(fmt.Sprintf("Email change %s confirmed for %s User", change.ID, change.UserID))
+	return s.userSrv.Get(ctx, change.UserID, "")
+}
+
+func (s *service) Cancel(ctx context.Context, id, secret string) error {
+	change, err := s.change(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !equal(change.CancelHash, hash(secret)) {
+		return ErrInvalidToken
+	}
+
+	if err := s.repo.Cancel(ctx, change.ID); err != nil {
+		return err
+	}
+
+	s.logger.Info(fmt.Sprintf("Email change %s canceled for %s User", change.ID, change.UserID))
+	return nil
+}
+
+func (s *service) change(ctx context.Context, id string) (*Change, error) {
+	change, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if !change.Pending() {
+		return nil, ErrInvalidToken
+	}
+
+	return change, nil
+}
+
+func (s *service) confirmMessage(u *domain.User, c *Change, secret string) mailer.Message {
+	link := fmt.Sprintf("%s?token=%s", s.config.ConfirmURL, url.QueryEscape(c.ID+"."+secret))
+	return mailer.Message{
+		To:      c.NewEmail,
+		Subject: "Confirm your new email",
+		Body:    fmt.Sprintf("Hi %s,\n\nUse this link to confirm %s as your new email:\n\n%s\n", u.FirstName, c.NewEmail, link),
+	}
+}
+
+func (s *service) cancelMessage(u *domain.User, c *Change, secret string) mailer.Message {
+	link := fmt.Sprintf("%s?token=%s", s.config.CancelURL, url.QueryEscape(c.ID+"."+secret))
+	return mailer.Message{
+		To:      c.OldEmail,
+		Subject: "Your email is being changed",
+		Body:    fmt.Sprintf("Hi %s,\n\nSomeone asked to change the email of your account to %s. If it wasn't you, cancel it with this link:\n\n%s\n", u.FirstName, c.NewEmail, link),
+	}
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hash(v string) string {
+	h := sha256.Sum256([]byte(v))
+	return hex.EncodeToString(h[:])
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
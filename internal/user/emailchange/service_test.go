@@ -0,0 +1,50 @@
+package emailchange_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/internal/user/emailchange"
+	"github.com/ncostamagna/axul-user/internal/user/usertest"
+	"github.com/ncostamagna/axul-user/pkg/dbtest"
+	"github.com/ncostamagna/axul-user/pkg/mailer/mailertest"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+)
+
+var link = regexp.MustCompile(`token=(\S+)`)
+
+// a confirm that can't update the email keeps the change pending
+func TestConfirmRollback(t *testing.T) {
+	ctx := context.Background()
+
+	logger, m := loghub.New(), &mailertest.Inbox{}
+	db := dbtest.Open(t)
+	userSrv, john := usertest.NewService(t)
+	srv := emailchange.NewService(emailchange.NewRepository(db, logger), userSrv, transaction.NewManager(db), m, emailchange.Config{}, logger)
+
+	if err := srv.Request(ctx, john.ID, "new@example.com"); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+
+	token, _ := url.QueryUnescape(link.FindStringSubmatch(m.Messages()[0].Body)[1])
+	id, secret, _ := strings.Cut(token, ".")
+
+	// another user takes the address before the confirmation
+	if _, err := userSrv.Create(ctx, "jane", "Jane", "Doe", "secret-password", "new@example.com", "", "", "", "", "en", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := srv.Confirm(ctx, id, secret); !errors.As(err, &user.ErrUserAlreadyExists{}) {
+		t.Fatalf("confirm of a taken email returned %v, want ErrUserAlreadyExists", err)
+	}
+
+	if pending, err := srv.Pending(ctx, john.ID); err != nil || pending != "new@example.com" {
+		t.Errorf("pending after the failed confirm returned %q, %v", pending, err)
+	}
+}
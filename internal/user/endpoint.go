@@ -2,56 +2,80 @@ package user
 
 import (
 	"context"
-	"fmt"
 	"errors"
+	"github.com/ncostamagna/axul-user/pkg/apierror"
 	auth "github.com/ncostamagna/axul_auth/auth"
 	domain "github.com/ncostamagna/axul_domain/domain/user"
+	"github.com/ncostamagna/axul-user/pkg/cursor"
+	"github.com/ncostamagna/axul-user/pkg/replica"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	"github.com/ncostamagna/axul-user/pkg/validation"
 	"github.com/ncostamagna/go-http-utils/meta"
 	"github.com/ncostamagna/go-http-utils/response"
 )
 
 type (
 	StoreReq struct {
-		UserName     string `json:"username"`
-		FirstName    string `json:"firstname"`
-		LastName     string `json:"lastname"`
-		Password     string `json:"password"`
-		Email        string `json:"email"`
-		Language     string `json:"language"`
-		Phone        string `json:"phone"`
-		ClientID     string `json:"client_id"`
+		UserName     string `json:"username" validate:"required,max=70"`
+		FirstName    string `json:"firstname" validate:"required,max=30"`
+		LastName     string `json:"lastname" validate:"required,max=30"`
+		Password     string `json:"password" validate:"required,min=8,max=72"`
+		Email        string `json:"email" validate:"required,email,max=70"`
+		Language     string `json:"language" validate:"max=35"`
+		Timezone     string `json:"timezone" validate:"max=64"`
+		Phone        string `json:"phone" validate:"max=30"`
+		ClientID     string `json:"client_id" validate:"max=36"`
 		ClientSecret string `json:"client_secret"`
 		Token        string `json:"token"`
 	}
 
+	// GetAllReq.Cursor is the page of the cursor query param, the lists
+	// without it are paginated by Page
 	GetAllReq struct {
-		ID       []string `json:"id"`
-		UserName string   `json:"username"`
-		Limit    int      `json:"limit"`
-		Page     int      `json:"page"`
+		ID         []string          `json:"id"`
+		UserName   string            `json:"username"`
+		Attributes []AttributeFilter `json:"attributes"`
+		Limit      int               `json:"limit"`
+		Page       int               `json:"page"`
+		Cursor     *cursor.Cursor    `json:"-"`
 	}
 
 	GetReq struct {
 		Authorization string `json:"Authorization"`
 	}
 
+	// UpdateReq.Version is the version of the If-Match header, 0 updates
+	// any version
 	UpdateReq struct {
 		ID        string  `json:"id"`
-		FirstName *string `json:"firstname"`
-		LastName  *string `json:"lastname"`
-		Email     *string `json:"email"`
-		Language  *string `json:"language"`
-		Phone     *string `json:"phone"`
-		Photo     *string `json:"photo"`
+		Version   int     `json:"-"`
+		FirstName *string `json:"firstname" validate:"omitempty,min=1,max=30"`
+		LastName  *string `json:"lastname" validate:"omitempty,min=1,max=30"`
+		Email     *string `json:"email" validate:"omitempty,min=1,email,max=70"`
+		Language  *string `json:"language" validate:"omitempty,max=35"`
+		Timezone  *string `json:"timezone" validate:"omitempty,max=64"`
+		Phone     *string `json:"phone" validate:"omitempty,max=30"`
+		Photo     *string `json:"photo" validate:"omitempty,max=100"`
+
+		Authorization string `json:"-"`
 	}
 
 	UpdatePasswordReq struct {
-		ID          string `json:"id"`
-		OldPassword string `json:"old_password"`
-		NewPassword string `json:"new_password"`
+		ID            string `json:"id"`
+		OldPassword   string `json:"old_password" validate:"required"`
+		NewPassword   string `json:"new_password" validate:"required,min=8,max=72"`
+		Authorization string `json:"Authorization"`
+	}
+
+	DeleteReq struct {
+		ID            string `json:"id"`
+		Authorization string `json:"Authorization"`
 	}
 
+	// LoginReq.Login is the username or the email, UserName is kept for
+	// the clients that still send it
 	LoginReq struct {
+		Login    string `json:"login"`
 		UserName string `json:"username"`
 		Password string `json:"password"`
 	}
@@ -66,17 +90,82 @@ type (
 	}
 
 	AuthRes struct {
-		Authorization int32        `json:"authorization"`
-		User          *domain.User `json:"user"`
+		Authorization  int32        `json:"authorization"`
+		User           *domain.User `json:"user"`
+		Scopes         []string     `json:"scopes,omitempty"`
+		ImpersonatedBy string       `json:"impersonated_by,omitempty"`
+	}
+
+	// UserRes is the user of a token, ImpersonatedBy is the admin behind
+	// an impersonation token and Version is sent in the ETag header
+	UserRes struct {
+		*domain.User
+		Version        int    `json:"-"`
+		ImpersonatedBy string `json:"impersonated_by,omitempty"`
+		PendingEmail   string `json:"pending_email,omitempty"`
+		PhoneInfo      *Phone  `json:"phone_info,omitempty"`
+		Locale         *Locale `json:"locale,omitempty"`
+	}
+
+	UpdateRes struct {
+		PendingEmail string `json:"pending_email,omitempty"`
+	}
+
+	ServiceAccountReq struct {
+		Name          string `json:"name" validate:"required,max=30"`
+		Description   string `json:"description" validate:"max=255"`
+		Authorization string `json:"Authorization"`
 	}
 
+	ServiceAccountRes struct {
+		User         *domain.User `json:"user"`
+		ClientID     string       `json:"client_id"`
+		ClientSecret string       `json:"client_secret"`
+	}
+
+	ClientCredentialsReq struct {
+		ClientID     string `json:"client_id" validate:"required"`
+		ClientSecret string `json:"client_secret" validate:"required"`
+	}
+
+	// Config.EmailChanges confirms the email updates before applying
+	// them, when it's nil the email is updated directly. Transactions
+	// updates the user and opens the email change together, without it
+	// they don't run in a transaction. The admins of AdminApp manage the
+	// service accounts and delete the users, without Admins there aren't
+	// admins. Purger deletes the user with its roles, without it only the
+	// user is deleted
 	Config struct {
-		LimPageDef string
+		LimPageDef   string
+		EmailChanges EmailChanger
+		Transactions transaction.Manager
+		Admins       Admins
+		AdminApp     string
+		Purger       Purger
 	}
 )
 
 type Controller func(ctx context.Context, request interface{}) (interface{}, error)
 
+// EmailChanger keeps the email updates pending until the new address
+// confirms them
+type EmailChanger interface {
+	Request(ctx context.Context, userID, email string) error
+	Pending(ctx context.Context, userID string) (string, error)
+}
+
+// Admins tells if a user has an admin role in an app, it's the role
+// service
+type Admins interface {
+	IsAdmin(ctx context.Context, app, userID string) (bool, error)
+}
+
+// Purger deletes a user and its roles in a transaction, it's the role
+// service
+type Purger interface {
+	Purge(ctx context.Context, userID string) error
+}
+
 // Endpoints struct
 type Endpoints struct {
 	Get            Controller
@@ -87,58 +176,123 @@ type Endpoints struct {
 	Update         Controller
 	UpdatePassword Controller
 	Delete         Controller
+
+	CreateServiceAccount Controller
+	GetServiceAccounts   Controller
+	ClientCredentials    Controller
+
+	// Language returns the language of the token user, the request is
+	// the token. It's used to translate the errors
+	Language Controller
 }
 
 func MakeEndpoints(s Service, config Config) Endpoints {
 	return Endpoints{
-		Get:            makeGetEndpoint(s),
+		Get:            makeGetEndpoint(s, config),
 		GetAll:         makeGetAllEndpoint(s, config),
 		Store:          makeStoreEndpoint(s),
 		Login:          makeLoginEndpoint(s),
 		Token:          makeTokenEndpoint(s),
-		Update:         makeUpdateEndpoint(s),
+		Update:         makeUpdateEndpoint(s, config),
 		UpdatePassword: makeUpdatePasswordEndpoint(s),
-		Delete:         makeDeleteEndpoint(s),
+		Delete:         makeDeleteEndpoint(s, config),
+
+		CreateServiceAccount: makeCreateServiceAccountEndpoint(s, config),
+		GetServiceAccounts:   makeGetServiceAccountsEndpoint(s, config),
+		ClientCredentials:    makeClientCredentialsEndpoint(s),
+
+		Language: makeLanguageEndpoint(s),
 	}
 }
 
-func makeGetEndpoint(service Service) Controller {
+func makeGetEndpoint(service Service, config Config) Controller {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(GetReq)
-		fmt.Println(req)
-		user, err := service.GetByToken(ctx, req.Authorization)
+		info, err := service.CheckToken(ctx, req.Authorization)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidAuthentication) {
+				return nil, AuthorizationError(InvalidAuthentication)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		if !info.Allows(ScopeUsersRead) {
+			return nil, apierror.Forbidden(ErrForbidden)
+		}
+
+		// the version is read before the user, when they are updated in
+		// between the ETag is older than the user and the next update
+		// fails instead of overwriting the changes
+		version, err := service.GetVersion(ctx, info.UserID)
 		if err != nil {
 			if err == NotFound {
-				return nil, response.NotFound(err.Error())
+				return nil, apierror.NotFound(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		user, err := service.Get(ctx, info.UserID, "")
+		if err != nil {
+			if err == NotFound {
+				return nil, apierror.NotFound(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		res := UserRes{User: user, Version: version, ImpersonatedBy: info.ActorID}
+		if res.PhoneInfo, err = service.GetPhone(ctx, user.ID); err != nil {
+			return nil, apierror.InternalServerError(err)
+		}
+
+		if res.Locale, err = service.GetLocale(ctx, user.ID); err != nil {
+			return nil, apierror.InternalServerError(err)
+		}
+
+		if config.EmailChanges != nil {
+			if res.PendingEmail, err = config.EmailChanges.Pending(ctx, user.ID); err != nil {
+				return nil, apierror.InternalServerError(err)
 			}
-			return nil, response.InternalServerError(err.Error())
 		}
 
-		return response.OK("", user, nil), nil
+		return response.OK("", res, nil), nil
 	}
 }
 
 func makeGetAllEndpoint(service Service, config Config) Controller {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(GetAllReq)
-		filters := Filters{ID: req.ID, UserName: req.UserName}
+		filters := Filters{ID: req.ID, UserName: req.UserName, Kind: KindHuman, Attributes: req.Attributes}
+
+		if req.Cursor != nil {
+			limit, err := cursor.Limit(req.Limit, config.LimPageDef)
+			if err != nil {
+				return nil, apierror.InternalServerError(err)
+			}
+
+			users, meta, err := service.GetPage(ctx, filters, cursor.Page{Cursor: req.Cursor, Limit: limit})
+			if err != nil {
+				return nil, apierror.InternalServerError(err)
+			}
+
+			return cursor.OK("", users, meta), nil
+		}
 
 		count, err := service.Count(ctx, filters)
 		if err != nil {
-			return nil, response.InternalServerError(err.Error())
+			return nil, apierror.InternalServerError(err)
 		}
 
 		meta, err := meta.New(req.Page, req.Limit, count, config.LimPageDef)
 		if err != nil {
-			return nil, response.InternalServerError(err.Error())
+			return nil, apierror.InternalServerError(err)
 		}
 
 		users, err := service.GetAll(ctx, filters, meta.Offset(), meta.Limit(), "")
 		if err != nil {
-			return nil, response.InternalServerError(err.Error())
+			return nil, apierror.InternalServerError(err)
 		}
 
-		return response.OK("", users, meta), nil
+		return cursor.OK("", users, cursor.Offset(users, meta, Position)), nil
 	}
 }
 
@@ -146,13 +300,19 @@ func makeStoreEndpoint(service Service) Controller {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(StoreReq)
 
-		if req.UserName == "" || req.FirstName == "" || req.LastName == "" || req.Password == "" || req.Email == "" {
-			return nil, response.BadRequest("fields required")
+		if err := validation.Struct(req); err != nil {
+			return nil, err
 		}
 
-		user, err := service.Create(ctx, req.UserName, req.FirstName, req.LastName, req.Password, req.Email, req.Phone, req.ClientID, req.ClientSecret, req.Token, req.Language)
+		user, err := service.Create(ctx, req.UserName, req.FirstName, req.LastName, req.Password, req.Email, req.Phone, req.ClientID, req.ClientSecret, req.Token, req.Language, req.Timezone)
 		if err != nil {
-			return nil, response.InternalServerError(err.Error())
+			if errors.As(err, &ErrUserAlreadyExists{}) {
+				return nil, apierror.Conflict(err)
+			}
+			if errors.As(err, &ErrInvalidUserName{}) || errors.As(err, &ErrInvalidPhone{}) || errors.As(err, &ErrUnsupportedLanguage{}) || errors.As(err, &ErrInvalidTimezone{}) {
+				return nil, apierror.BadRequest(err)
+			}
+			return nil, apierror.InternalServerError(err)
 		}
 
 		return response.Created("", user, nil), nil
@@ -163,70 +323,126 @@ func makeLoginEndpoint(service Service) Controller {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(LoginReq)
 
-		users, err := service.GetAll(ctx, Filters{UserName: req.UserName}, 0, 0, "")
-		if len(users) != 1 {
-			return nil, response.Unauthorized(InvalidAuthentication.Error())
+		login := req.Login
+		if login == "" {
+			login = req.UserName
 		}
-		if err != nil {
-			return nil, response.InternalServerError(err.Error())
+
+		if login == "" {
+			return nil, apierror.Unauthorized(InvalidAuthentication)
 		}
 
-		token, err := service.Login(ctx, &users[0], req.Password)
+		user, token, err := service.Login(ctx, login, req.Password)
 		if err != nil {
 			if err == InvalidAuthentication {
-				return nil, response.Unauthorized(err.Error())
+				return nil, apierror.Unauthorized(err)
 			}
-			return nil, response.InternalServerError(err.Error())
+			return nil, apierror.InternalServerError(err)
 		}
 
-		return response.OK("", LoginRes{&users[0], token}, nil), nil
+		return response.OK("", LoginRes{user, token}, nil), nil
 	}
 }
 
 func makeTokenEndpoint(service Service) Controller {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(TokenReq)
-		user, err := service.TokenAccess(ctx, req.ID, req.Token)
+		user, info, err := service.TokenAccess(ctx, req.ID, req.Token)
 
 		if err != nil {
 			if err == NotFound {
-				return nil, response.NotFound(err.Error())
+				return nil, apierror.NotFound(err)
 			}
 
 			if err == InvalidAuthentication || err == auth.ErrInvalidAuthentication {
-				return nil, response.Unauthorized(err.Error())
+				return nil, apierror.Unauthorized(err)
 			}
 
-			return nil, response.InternalServerError(err.Error())
+			return nil, apierror.InternalServerError(err)
 		}
 
-		return response.OK("", AuthRes{Authorization: 1, User: user}, nil), nil
+		return response.OK("", AuthRes{Authorization: 1, User: user, Scopes: info.Scopes, ImpersonatedBy: info.ActorID}, nil), nil
 	}
 }
 
-func makeUpdateEndpoint(s Service) Controller {
+func makeUpdateEndpoint(s Service, config Config) Controller {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(UpdateReq)
 
-		if req.FirstName != nil && *req.FirstName == "" {
-			return nil, response.BadRequest(ErrFirstNameRequired.Error())
+		if _, err := s.Authorize(ctx, req.Authorization, req.ID, ScopeUsersWrite); err != nil {
+			return nil, AuthorizationError(err)
+		}
+
+		if err := validation.Struct(req); err != nil {
+			return nil, err
+		}
+
+		// the version is checked before the email change is requested, an
+		// update of an old version doesn't send the confirmation. The
+		// update checks it again with the write
+		if req.Version != 0 {
+			version, err := s.GetVersion(replica.Primary(ctx), req.ID)
+			if err != nil {
+				if err == NotFound {
+					return nil, apierror.NotFound(err)
+				}
+				return nil, apierror.InternalServerError(err)
+			}
+
+			if version != req.Version {
+				return nil, apierror.PreconditionFailed(ErrVersionConflict{req.ID})
+			}
 		}
 
-		if req.LastName != nil && *req.LastName == "" {
-			return nil, response.BadRequest(ErrLastNameRequired.Error())
+		// the other fields are validated and updated first and the email
+		// change is opened in the same transaction, its emails are sent
+		// when it's committed
+		email, pending := req.Email, config.EmailChanges != nil && req.Email != nil
+		update := !pending || req.FirstName != nil || req.LastName != nil || req.Phone != nil || req.Photo != nil || req.Language != nil || req.Timezone != nil
+		if pending {
+			email = nil
 		}
 
-		if req.Email != nil && *req.Email == "" {
-			return nil, response.BadRequest(ErrEmailRequired.Error())
+		tx := config.Transactions
+		if tx == nil {
+			tx = transaction.None
 		}
 
-		if err := s.Update(ctx, req.ID, req.FirstName, req.LastName, req.Email, req.Phone, req.Photo, req.Language); err != nil {
+		err := tx.Do(ctx, func(ctx context.Context) error {
+			if update {
+				if err := s.Update(ctx, req.ID, req.Version, req.FirstName, req.LastName, email, req.Phone, req.Photo, req.Language, req.Timezone); err != nil {
+					return err
+				}
+			}
+
+			if pending {
+				return config.EmailChanges.Request(ctx, req.ID, *req.Email)
+			}
+			return nil
+		})
+		if err != nil {
 
 			if errors.As(err, &ErrNotFound{}) {
-				return nil, response.NotFound(err.Error())
+				return nil, apierror.NotFound(err)
+			}
+
+			if errors.As(err, &ErrVersionConflict{}) {
+				return nil, apierror.PreconditionFailed(err)
+			}
+
+			if errors.As(err, &ErrUserAlreadyExists{}) {
+				return nil, apierror.Conflict(err)
+			}
+
+			if errors.As(err, &ErrInvalidPhone{}) || errors.As(err, &ErrUnsupportedLanguage{}) || errors.As(err, &ErrInvalidTimezone{}) || err == ErrSameEmail {
+				return nil, apierror.BadRequest(err)
 			}
 
-			return nil, response.InternalServerError(err.Error())
+			return nil, apierror.InternalServerError(err)
+		}
+
+		if pending {
+			return response.Accepted("", UpdateRes{*req.Email}, nil), nil
 		}
 
 		return response.OK("", nil, nil), nil
@@ -237,34 +453,211 @@ func makeUpdatePasswordEndpoint(s Service) Controller {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(UpdatePasswordReq)
 
-		if req.NewPassword == "" {
-			return nil, response.BadRequest(ErrNewPasswordRequired.Error())
+		if _, err := s.Authorize(ctx, req.Authorization, req.ID, ScopeUsersWrite); err != nil {
+			return nil, AuthorizationError(err)
 		}
 
-		if req.OldPassword == "" {
-			return nil, response.BadRequest(ErrOldPasswordRequired.Error())
+		if err := validation.Struct(req); err != nil {
+			return nil, err
 		}
 
 		if err := s.UpdatePassword(ctx, req.ID, req.NewPassword, req.OldPassword); err != nil {
 
 			if err == InvalidPassword {
-				return nil, response.BadRequest(err.Error())
+				return nil, apierror.BadRequest(err)
 			}
 
 			if errors.As(err, &ErrNotFound{}) {
-				return nil, response.NotFound(err.Error())
+				return nil, apierror.NotFound(err)
 			}
 
-			return nil, response.InternalServerError(err.Error())
+			return nil, apierror.InternalServerError(err)
 		}
 
 		return response.OK("", nil, nil), nil
 	}
 }
 
-func makeDeleteEndpoint(service Service) Controller {
+// makeDeleteEndpoint deletes the user, only the admins can delete users
+func makeDeleteEndpoint(s Service, config Config) Controller {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(DeleteReq)
+
+		admin, info, err := s.GetByToken(ctx, req.Authorization)
+		if err != nil {
+			return nil, apierror.Unauthorized(InvalidAuthentication)
+		}
+
+		if info.ActorID != "" {
+			return nil, apierror.Forbidden(ErrImpersonationNotAllowed)
+		}
+
+		if !info.Allows(ScopeUsersWrite) {
+			return nil, apierror.Forbidden(ErrForbidden)
+		}
+
+		ok, err := isAdmin(ctx, config, admin.ID)
+		if err != nil {
+			return nil, apierror.InternalServerError(err)
+		}
+
+		if !ok {
+			return nil, apierror.Forbidden(ErrDeleteNotAdmin)
+		}
+
+		purge := s.Delete
+		if config.Purger != nil {
+			purge = config.Purger.Purge
+		}
+
+		if err := purge(ctx, req.ID); err != nil {
+			if errors.As(err, &ErrNotFound{}) {
+				return nil, apierror.NotFound(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
 
 		return response.OK("", nil, nil), nil
 	}
 }
+
+func makeCreateServiceAccountEndpoint(s Service, config Config) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ServiceAccountReq)
+
+		owner, info, err := s.GetByToken(ctx, req.Authorization)
+		if err != nil {
+			return nil, apierror.Unauthorized(InvalidAuthentication)
+		}
+
+		if info.ActorID != "" {
+			return nil, apierror.Forbidden(ErrImpersonationNotAllowed)
+		}
+
+		if !info.Allows(ScopeUsersWrite) {
+			return nil, apierror.Forbidden(ErrForbidden)
+		}
+
+		admin, err := isAdmin(ctx, config, owner.ID)
+		if err != nil {
+			return nil, apierror.InternalServerError(err)
+		}
+
+		if !admin {
+			return nil, apierror.Forbidden(ErrNotAdmin)
+		}
+
+		if err := validation.Struct(req); err != nil {
+			return nil, err
+		}
+
+		user, secret, err := s.CreateServiceAccount(ctx, req.Name, req.Description, owner.ID)
+		if err != nil {
+			if errors.As(err, &ErrUserAlreadyExists{}) {
+				return nil, apierror.Conflict(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		clientID := user.ClientID
+		user.ClientSecret = ""
+		return response.Created("", ServiceAccountRes{user, clientID, secret}, nil), nil
+	}
+}
+
+// makeGetServiceAccountsEndpoint lists every service account to the
+// admins and the accounts created by the user to the other users
+func makeGetServiceAccountsEndpoint(s Service, config Config) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetReq)
+
+		owner, info, err := s.GetByToken(ctx, req.Authorization)
+		if err != nil {
+			return nil, apierror.Unauthorized(InvalidAuthentication)
+		}
+
+		if !info.Allows(ScopeUsersRead) {
+			return nil, apierror.Forbidden(ErrForbidden)
+		}
+
+		admin, err := isAdmin(ctx, config, owner.ID)
+		if err != nil {
+			return nil, apierror.InternalServerError(err)
+		}
+
+		f := Filters{Kind: KindService}
+		if !admin {
+			f.CreatedBy = owner.ID
+		}
+
+		users, err := s.GetAll(ctx, f, 0, 0, "")
+		if err != nil {
+			return nil, apierror.InternalServerError(err)
+		}
+
+		for i := range users {
+			users[i].ClientSecret = ""
+		}
+
+		return response.OK("", users, nil), nil
+	}
+}
+
+func makeClientCredentialsEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ClientCredentialsReq)
+
+		if err := validation.Struct(req); err != nil {
+			return nil, err
+		}
+
+		user, token, err := s.ClientCredentials(ctx, req.ClientID, req.ClientSecret)
+		if err != nil {
+			if err == InvalidAuthentication {
+				return nil, apierror.Unauthorized(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		user.ClientSecret = ""
+		return response.OK("", LoginRes{user, token}, nil), nil
+	}
+}
+
+func makeLanguageEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		token := request.(string)
+
+		u, _, err := s.GetByToken(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+
+		if locale, err := s.GetLocale(ctx, u.ID); err == nil && locale != nil {
+			return locale.Language, nil
+		}
+
+		return string(u.Language), nil
+	}
+}
+
+func isAdmin(ctx context.Context, config Config, userID string) (bool, error) {
+	if config.Admins == nil {
+		return false, nil
+	}
+	return config.Admins.IsAdmin(ctx, config.AdminApp, userID)
+}
+
+// AuthorizationError maps the Authorize errors to the response, it's shared
+// with the endpoints of the user subpackages
+func AuthorizationError(err error) error {
+	if err == ErrForbidden || err == ErrImpersonationNotAllowed {
+		return apierror.Forbidden(err)
+	}
+	return apierror.Unauthorized(err)
+}
+
+// GetVersion is the version of the ETag header
+func (r UserRes) GetVersion() int {
+	return r.Version
+}
@@ -1,20 +1,28 @@
 package user
 
 import (
-	"errors"
 	"fmt"
+	"github.com/ncostamagna/axul-user/pkg/i18n"
 )
 
-var NotFound = errors.New("Record not found")
-var FieldIsRequired = errors.New("Required values")
-var InvalidAuthentication = errors.New("Invalid authentication")
-var InvalidPassword = errors.New("Invalid password")
+var NotFound = i18n.NewError("RECORD_NOT_FOUND", "Record not found")
+var FieldIsRequired = i18n.NewError("REQUIRED_VALUES", "Required values")
+var InvalidAuthentication = i18n.NewError("INVALID_AUTHENTICATION", "invalid authentication")
+var InvalidPassword = i18n.NewError("INVALID_PASSWORD", "Invalid password")
+var ErrForbidden = i18n.NewError("USER_FORBIDDEN", "you don't have access to this user")
+var ErrImpersonationNotAllowed = i18n.NewError("IMPERSONATION_NOT_ALLOWED", "this operation isn't allowed while impersonating a user")
+var ErrNotAdmin = i18n.NewError("SERVICE_ACCOUNT_NOT_ADMIN", "only admins can create service accounts")
+var ErrDeleteNotAdmin = i18n.NewError("DELETE_NOT_ADMIN", "only admins can delete users")
 
-var ErrFirstNameRequired = errors.New("first name is required")
-var ErrLastNameRequired = errors.New("last name is required")
-var ErrEmailRequired = errors.New("email is required")
-var ErrNewPasswordRequired = errors.New("new password is required")
-var ErrOldPasswordRequired = errors.New("old password is required")
+var ErrFirstNameRequired = i18n.NewError("FIRST_NAME_REQUIRED", "first name is required")
+var ErrLastNameRequired = i18n.NewError("LAST_NAME_REQUIRED", "last name is required")
+var ErrEmailRequired = i18n.NewError("EMAIL_REQUIRED", "email is required")
+var ErrNewPasswordRequired = i18n.NewError("NEW_PASSWORD_REQUIRED", "new password is required")
+var ErrOldPasswordRequired = i18n.NewError("OLD_PASSWORD_REQUIRED", "old password is required")
+var ErrNameRequired = i18n.NewError("NAME_REQUIRED", "name is required")
+var ErrPhoneChanged = i18n.NewError("PHONE_CHANGED", "the phone changed after the code was sent")
+var ErrSameEmail = i18n.NewError("SAME_EMAIL", "the new email is the current one")
+var ErrClientCredentialsRequired = i18n.NewError("CLIENT_CREDENTIALS_REQUIRED", "client id and client secret are required")
 
 type ErrNotFound struct {
 	UserID string
@@ -23,3 +31,122 @@ type ErrNotFound struct {
 func (e ErrNotFound) Error() string {
 	return fmt.Sprintf("user '%s' doesn't exist", e.UserID)
 }
+
+func (e ErrNotFound) Code() string {
+	return "USER_NOT_FOUND"
+}
+
+func (e ErrNotFound) Args() []interface{} {
+	return []interface{}{e.UserID}
+}
+
+type ErrInvalidPhone struct {
+	Phone string
+}
+
+func (e ErrInvalidPhone) Error() string {
+	return fmt.Sprintf("phone '%s' isn't valid, it must be in international format like +5491112345678", e.Phone)
+}
+
+func (e ErrInvalidPhone) Code() string {
+	return "PHONE_INVALID"
+}
+
+func (e ErrInvalidPhone) Args() []interface{} {
+	return []interface{}{e.Phone}
+}
+
+// ErrInvalidUserName is returned for a username with an @, the logins with
+// an @ are looked up by email
+type ErrInvalidUserName struct {
+	UserName string
+}
+
+func (e ErrInvalidUserName) Error() string {
+	return fmt.Sprintf("username '%s' isn't valid, it can't have an @", e.UserName)
+}
+
+func (e ErrInvalidUserName) Code() string {
+	return "USERNAME_INVALID"
+}
+
+func (e ErrInvalidUserName) Args() []interface{} {
+	return []interface{}{e.UserName}
+}
+
+type ErrUnsupportedLanguage struct {
+	Language string
+}
+
+func (e ErrUnsupportedLanguage) Error() string {
+	return fmt.Sprintf("language '%s' isn't supported", e.Language)
+}
+
+func (e ErrUnsupportedLanguage) Code() string {
+	return "LANGUAGE_UNSUPPORTED"
+}
+
+func (e ErrUnsupportedLanguage) Args() []interface{} {
+	return []interface{}{e.Language}
+}
+
+type ErrInvalidTimezone struct {
+	Timezone string
+}
+
+func (e ErrInvalidTimezone) Error() string {
+	return fmt.Sprintf("timezone '%s' isn't a valid IANA timezone like America/Argentina/Buenos_Aires", e.Timezone)
+}
+
+func (e ErrInvalidTimezone) Code() string {
+	return "TIMEZONE_INVALID"
+}
+
+func (e ErrInvalidTimezone) Args() []interface{} {
+	return []interface{}{e.Timezone}
+}
+
+// ErrUserAlreadyExists is returned when the username or the email is taken,
+// Field is empty when the database rejected the insert
+type ErrUserAlreadyExists struct {
+	Field string
+}
+
+func (e ErrUserAlreadyExists) Error() string {
+	if e.Field == "" {
+		return "user already exists"
+	}
+	return fmt.Sprintf("a user with this %s already exists", e.Field)
+}
+
+func (e ErrUserAlreadyExists) Code() string {
+	switch e.Field {
+	case "username":
+		return "USERNAME_ALREADY_EXISTS"
+	case "email":
+		return "EMAIL_ALREADY_EXISTS"
+	}
+	return "USER_ALREADY_EXISTS"
+}
+
+func (e ErrUserAlreadyExists) Args() []interface{} {
+	return nil
+}
+
+// ErrVersionConflict is returned by the updates of an old version of the
+// user, another request updated it after it was read
+type ErrVersionConflict struct {
+	UserID string
+}
+
+func (e ErrVersionConflict) Error() string {
+	return fmt.Sprintf("user '%s' was updated by another request, get it again and retry", e.UserID)
+}
+
+func (e ErrVersionConflict) Code() string {
+	return "USER_VERSION_CONFLICT"
+}
+
+func (e ErrVersionConflict) Args() []interface{} {
+	return []interface{}{e.UserID}
+}
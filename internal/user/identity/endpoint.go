@@ -0,0 +1,159 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/apierror"
+	"github.com/ncostamagna/go-http-utils/response"
+)
+
+type (
+	RedirectReq struct {
+		Provider string `json:"provider"`
+	}
+
+	// CallbackReq.Nonce is the nonce of the cookie of the login
+	CallbackReq struct {
+		Provider string `json:"provider"`
+		Code     string `json:"code"`
+		State    string `json:"state"`
+		Nonce    string `json:"-"`
+	}
+
+	UserReq struct {
+		ID            string `json:"id"`
+		Provider      string `json:"provider"`
+		Authorization string `json:"Authorization"`
+	}
+
+	// Redirect is encoded as a 302 to the provider login page, Nonce is
+	// sent in an HttpOnly cookie for the callback
+	Redirect struct {
+		URL   string `json:"url"`
+		Nonce string `json:"-"`
+	}
+)
+
+type Controller func(ctx context.Context, request interface{}) (interface{}, error)
+
+// Endpoints struct
+type Endpoints struct {
+	Login    Controller
+	Callback Controller
+	GetAll   Controller
+	Link     Controller
+	Unlink   Controller
+}
+
+func MakeEndpoints(s Service, userSrv user.Service) Endpoints {
+	return Endpoints{
+		Login:    makeLoginEndpoint(s),
+		Callback: makeCallbackEndpoint(s),
+		GetAll:   makeGetAllEndpoint(s, userSrv),
+		Link:     makeLinkEndpoint(s, userSrv),
+		Unlink:   makeUnlinkEndpoint(s, userSrv),
+	}
+}
+
+func makeLoginEndpoint(service Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RedirectReq)
+
+		url, nonce, err := service.AuthURL(ctx, req.Provider, "")
+		if err != nil {
+			if errors.As(err, &ErrProviderNotFound{}) {
+				return nil, apierror.NotFound(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return Redirect{url, nonce}, nil
+	}
+}
+
+func makeCallbackEndpoint(service Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(CallbackReq)
+
+		if req.Code == "" || req.State == "" {
+			return nil, apierror.BadRequest(ErrCodeRequired)
+		}
+
+		u, token, err := service.Callback(ctx, req.Provider, req.Code, req.State, req.Nonce)
+		if err != nil {
+			switch {
+			case errors.As(err, &ErrProviderNotFound{}):
+				return nil, apierror.NotFound(err)
+			case err == ErrInvalidState, errors.As(err, &ErrExchange{}):
+				return nil, apierror.Unauthorized(err)
+			case err == ErrAlreadyLinked:
+				return nil, apierror.Conflict(err)
+			case errors.As(err, &user.ErrUserAlreadyExists{}):
+				return nil, apierror.Conflict(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", user.LoginRes{User: u, Token: token}, nil), nil
+	}
+}
+
+func makeGetAllEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UserReq)
+
+		if _, err := userSrv.Authorize(ctx, req.Authorization, req.ID, user.ScopeUsersRead); err != nil {
+			return nil, user.AuthorizationError(err)
+		}
+
+		identities, err := service.GetAll(ctx, req.ID)
+		if err != nil {
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", identities, nil), nil
+	}
+}
+
+func makeLinkEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UserReq)
+
+		if _, err := userSrv.Authorize(ctx, req.Authorization, req.ID, user.ScopeUsersWrite); err != nil {
+			return nil, user.AuthorizationError(err)
+		}
+
+		url, nonce, err := service.AuthURL(ctx, req.Provider, req.ID)
+		if err != nil {
+			if errors.As(err, &ErrProviderNotFound{}) {
+				return nil, apierror.NotFound(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", Redirect{url, nonce}, nil), nil
+	}
+}
+
+func makeUnlinkEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UserReq)
+
+		if _, err := userSrv.Authorize(ctx, req.Authorization, req.ID, user.ScopeUsersWrite); err != nil {
+			return nil, user.AuthorizationError(err)
+		}
+
+		if err := service.Unlink(ctx, req.ID, req.Provider); err != nil {
+			switch {
+			case errors.As(err, &ErrIdentityNotFound{}):
+				return nil, apierror.NotFound(err)
+			case err == ErrLastLoginMethod:
+				return nil, apierror.Conflict(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", nil, nil), nil
+	}
+}
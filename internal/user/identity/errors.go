@@ -0,0 +1,41 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidState = errors.New("invalid or expired state")
+var ErrCodeRequired = errors.New("code and state are required")
+var ErrAlreadyLinked = errors.New("the external account is linked to another user")
+var ErrLastLoginMethod = errors.New("the identity is the only login of the user, link another account before removing it")
+
+type ErrProviderNotFound struct {
+	Provider string
+}
+
+func (e ErrProviderNotFound) Error() string {
+	return fmt.Sprintf("provider '%s' doesn't exist", e.Provider)
+}
+
+type ErrIdentityNotFound struct {
+	UserID   string
+	Provider string
+}
+
+func (e ErrIdentityNotFound) Error() string {
+	return fmt.Sprintf("user '%s' doesn't have a '%s' identity", e.UserID, e.Provider)
+}
+
+type ErrExchange struct {
+	Provider string
+	Err      error
+}
+
+func (e ErrExchange) Error() string {
+	return fmt.Sprintf("'%s' exchange failed: %v", e.Provider, e.Err)
+}
+
+func (e ErrExchange) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,40 @@
+package identity
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Identity is an external account (Google, GitHub, OIDC) linked to a user,
+// CreatedUser is true when the identity created the user just in time and
+// the user doesn't know its random password
+type Identity struct {
+	ID          string    `json:"id" gorm:"type:char(36);not null;primary_key;unique_index"`
+	UserID      string    `json:"user_id" gorm:"type:char(36);not null;index"`
+	Provider    string    `json:"provider" gorm:"type:char(30);not null;uniqueIndex:idx_provider_subject"`
+	Subject     string    `json:"subject" gorm:"type:varchar(191);not null;uniqueIndex:idx_provider_subject"`
+	Email       string    `json:"email" gorm:"type:char(70)"`
+	CreatedUser bool      `json:"-" gorm:"not null;default:false"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"-"`
+}
+
+// State is the nonce of a state used by a callback, its primary key makes
+// each state work once
+type State struct {
+	NonceHash string    `gorm:"type:char(64);not null;primary_key"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+func (State) TableName() string {
+	return "identity_states"
+}
+
+func (i *Identity) BeforeCreate(tx *gorm.DB) (err error) {
+
+	if i.ID == "" {
+		i.ID = uuid.New().String()
+	}
+	return
+}
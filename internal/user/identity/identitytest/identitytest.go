@@ -0,0 +1,90 @@
+// Package identitytest has the fake OpenID Connect provider of the tests
+package identitytest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/ncostamagna/axul-user/internal/user/identity"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// IDP is an OpenID Connect provider that logs in Jane, the ID token of a
+// code has the nonce the test gives to the code
+type IDP struct {
+	*httptest.Server
+	mu     sync.Mutex
+	nonces map[string]string
+}
+
+// NewIDP starts the provider, it's closed with the test
+func NewIDP(t testing.TB) *IDP {
+	t.Helper()
+
+	p := &IDP{nonces: map[string]string{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/auth",
+			"token_endpoint":         p.URL + "/token",
+			"userinfo_endpoint":      p.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		nonce := p.nonces[r.FormValue("code")]
+		p.mu.Unlock()
+
+		claims, _ := json.Marshal(map[string]interface{}{
+			"iss":   p.URL,
+			"sub":   "1234",
+			"aud":   "client",
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"nonce": nonce,
+		})
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access",
+			"id_token":     "e30." + base64.RawURLEncoding.EncodeToString(claims) + ".sig",
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"sub":            "1234",
+			"email":          "jane@example.com",
+			"email_verified": true,
+			"given_name":     "Jane",
+		})
+	})
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// Provider is the identity.OIDC provider of the IdP, its client is
+// "client"
+func (p *IDP) Provider(t testing.TB) identity.Provider {
+	t.Helper()
+
+	provider, err := identity.NewOIDCProvider(context.Background(), identity.ProviderConfig{
+		Name:     identity.OIDC,
+		Issuer:   p.URL,
+		ClientID: "client",
+	}, p.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+// Code makes the ID token of code have nonce
+func (p *IDP) Code(code, nonce string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.nonces[code] = nonce
+}
@@ -0,0 +1,373 @@
+package identity
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Google = "google"
+	GitHub = "github"
+	OIDC   = "oidc"
+)
+
+// Claims are the user attributes returned by the identity provider
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	UserName      string
+	FirstName     string
+	LastName      string
+}
+
+// Provider is an external identity provider client, the OpenID Connect
+// providers send nonce in the ID token and Exchange checks it
+type Provider interface {
+	Name() string
+	AuthCodeURL(state, nonce string) string
+	Exchange(ctx context.Context, code, nonce string) (*Claims, error)
+}
+
+type ProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+type oauthEndpoints struct {
+	Issuer      string `json:"issuer"`
+	AuthURL     string `json:"authorization_endpoint"`
+	TokenURL    string `json:"token_endpoint"`
+	UserInfoURL string `json:"userinfo_endpoint"`
+}
+
+type oidcProvider struct {
+	config    ProviderConfig
+	endpoints oauthEndpoints
+	client    *http.Client
+}
+
+// NewOIDCProvider loads the discovery document of the issuer and
+// returns a generic OpenID Connect provider
+func NewOIDCProvider(ctx context.Context, config ProviderConfig, client *http.Client) (Provider, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	discovery := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &oidcProvider{config: config, client: client}
+	if err := doJSON(client, req, &p.endpoints); err != nil {
+		return nil, err
+	}
+
+	if p.endpoints.Issuer == "" || p.endpoints.AuthURL == "" || p.endpoints.TokenURL == "" || p.endpoints.UserInfoURL == "" {
+		return nil, fmt.Errorf("invalid discovery document for '%s'", config.Issuer)
+	}
+
+	return p, nil
+}
+
+// NewGoogleProvider is the OIDC provider for Google accounts
+func NewGoogleProvider(ctx context.Context, config ProviderConfig, client *http.Client) (Provider, error) {
+	config.Name = Google
+	config.Issuer = "https://accounts.google.com"
+	return NewOIDCProvider(ctx, config, client)
+}
+
+func (p *oidcProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *oidcProvider) AuthCodeURL(state, nonce string) string {
+	return authCodeURL(p.endpoints.AuthURL, p.config, url.Values{"state": {state}, "nonce": {nonce}})
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code, nonce string) (*Claims, error) {
+	token, err := exchangeCode(ctx, p.client, p.endpoints.TokenURL, p.config, code)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := p.verifyIDToken(token.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	var info struct {
+		Subject       string      `json:"sub"`
+		Email         string      `json:"email"`
+		EmailVerified interface{} `json:"email_verified"`
+		UserName      string      `json:"preferred_username"`
+		GivenName     string      `json:"given_name"`
+		FamilyName    string      `json:"family_name"`
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.endpoints.UserInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	if err := doJSON(p.client, req, &info); err != nil {
+		return nil, err
+	}
+
+	if info.Subject == "" || info.Subject != subject {
+		return nil, errors.New("the userinfo subject isn't the subject of the id token")
+	}
+
+	// some providers send email_verified as a string
+	verified := false
+	switch v := info.EmailVerified.(type) {
+	case bool:
+		verified = v
+	case string:
+		verified, _ = strconv.ParseBool(v)
+	}
+
+	return &Claims{
+		Subject:       info.Subject,
+		Email:         info.Email,
+		EmailVerified: verified,
+		UserName:      info.UserName,
+		FirstName:     info.GivenName,
+		LastName:      info.FamilyName,
+	}, nil
+}
+
+// verifyIDToken checks the claims of the ID token and returns its subject.
+// The token comes from the token endpoint over TLS, so the signature isn't
+// checked (OpenID Connect Core 3.1.3.7)
+func (p *oidcProvider) verifyIDToken(raw, nonce string) (string, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return "", errors.New("the token response doesn't have an id token")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", err
+	}
+
+	var claims struct {
+		Issuer   string   `json:"iss"`
+		Subject  string   `json:"sub"`
+		Audience audience `json:"aud"`
+		Expires  int64    `json:"exp"`
+		Nonce    string   `json:"nonce"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", err
+	}
+
+	switch {
+	case claims.Issuer != p.endpoints.Issuer:
+		return "", fmt.Errorf("id token issuer '%s' isn't '%s'", claims.Issuer, p.endpoints.Issuer)
+	case !slices.Contains(claims.Audience, p.config.ClientID):
+		return "", errors.New("the id token isn't for this client")
+	case time.Now().Unix() > claims.Expires:
+		return "", errors.New("the id token expired")
+	case nonce == "" || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1:
+		return "", errors.New("the id token nonce isn't the nonce of the state")
+	}
+
+	return claims.Subject, nil
+}
+
+// audience is the aud claim, a string or an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+type githubProvider struct {
+	config    ProviderConfig
+	endpoints oauthEndpoints
+	apiURL    string
+	client    *http.Client
+}
+
+// NewGitHubProvider returns the GitHub OAuth provider, GitHub doesn't
+// implement OIDC for user login so the claims come from its REST API
+func NewGitHubProvider(config ProviderConfig, client *http.Client) Provider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	config.Name = GitHub
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"read:user", "user:email"}
+	}
+
+	return &githubProvider{
+		config: config,
+		endpoints: oauthEndpoints{
+			AuthURL:  "https://github.com/login/oauth/authorize",
+			TokenURL: "https://github.com/login/oauth/access_token",
+		},
+		apiURL: "https://api.github.com",
+		client: client,
+	}
+}
+
+func (p *githubProvider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL ignores nonce, GitHub doesn't issue ID tokens and the
+// state cookie binds the login to the browser
+func (p *githubProvider) AuthCodeURL(state, _ string) string {
+	return authCodeURL(p.endpoints.AuthURL, p.config, url.Values{"state": {state}})
+}
+
+func (p *githubProvider) Exchange(ctx context.Context, code, _ string) (*Claims, error) {
+	token, err := exchangeCode(ctx, p.client, p.endpoints.TokenURL, p.config, code)
+	if err != nil {
+		return nil, err
+	}
+	accessToken := token.AccessToken
+
+	var u struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.api(ctx, accessToken, "/user", &u); err != nil {
+		return nil, err
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.api(ctx, accessToken, "/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{
+		Subject:  strconv.FormatInt(u.ID, 10),
+		UserName: u.Login,
+	}
+
+	claims.FirstName, claims.LastName, _ = strings.Cut(u.Name, " ")
+
+	for _, e := range emails {
+		if e.Primary {
+			claims.Email = e.Email
+			claims.EmailVerified = e.Verified
+		}
+	}
+
+	return claims, nil
+}
+
+func (p *githubProvider) api(ctx context.Context, accessToken, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	return doJSON(p.client, req, v)
+}
+
+// authCodeURL returns the login url with the params of the login, like
+// the state
+func authCodeURL(authURL string, config ProviderConfig, params url.Values) string {
+	v := url.Values{
+		"response_type": {"code"},
+		"client_id":     {config.ClientID},
+		"redirect_uri":  {config.RedirectURL},
+		"scope":         {strings.Join(config.Scopes, " ")},
+	}
+	for k, values := range params {
+		v[k] = values
+	}
+
+	if strings.Contains(authURL, "?") {
+		return authURL + "&" + v.Encode()
+	}
+	return authURL + "?" + v.Encode()
+}
+
+// tokenResponse is the response of the token endpoint, only the OpenID
+// Connect providers send IDToken
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+}
+
+func exchangeCode(ctx context.Context, client *http.Client, tokenURL string, config ProviderConfig, code string) (*tokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {config.RedirectURL},
+		"client_id":     {config.ClientID},
+		"client_secret": {config.ClientSecret},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var token tokenResponse
+	if err := doJSON(client, req, &token); err != nil {
+		return nil, err
+	}
+
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint error: '%s'", token.Error)
+	}
+
+	return &token, nil
+}
+
+func doJSON(client *http.Client, req *http.Request, v interface{}) error {
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s %s: status %d", req.Method, req.URL.Path, res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
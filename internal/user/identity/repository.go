@@ -0,0 +1,112 @@
+package identity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"gorm.io/gorm"
+	"time"
+)
+
+type Repository interface {
+	GetAll(ctx context.Context, filters Filters) ([]Identity, error)
+	Get(ctx context.Context, provider, subject string) (*Identity, error)
+	Create(ctx context.Context, identity *Identity) error
+	Delete(ctx context.Context, userID, provider string) error
+	ConsumeState(ctx context.Context, nonce string, expires time.Time) error
+}
+
+type repo struct {
+	db     *gorm.DB
+	logger loghub.Logger
+}
+
+func NewRepository(db *gorm.DB, logger loghub.Logger) Repository {
+	return &repo{db, logger}
+}
+
+func (r *repo) GetAll(ctx context.Context, filters Filters) ([]Identity, error) {
+	var identities []Identity
+
+	tx := transaction.DB(ctx, r.db).Model(&identities)
+	tx = applyFilters(tx, filters)
+	if err := tx.Order("created_at desc").Find(&identities).Error; err != nil {
+		r.logger.Error(err)
+		return nil, err
+	}
+
+	return identities, nil
+}
+
+// Get returns nil without error when the external account isn't linked
+func (r *repo) Get(ctx context.Context, provider, subject string) (*Identity, error) {
+	var identity Identity
+
+	result := transaction.DB(ctx, r.db).Where("provider = ? and subject = ?", provider, subject).First(&identity)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		r.logger.Error(result.Error)
+		return nil, result.Error
+	}
+
+	return &identity, nil
+}
+
+func (r *repo) Create(ctx context.Context, identity *Identity) error {
+	return transaction.DB(ctx, r.db).Create(identity).Error
+}
+
+func (r *repo) Delete(ctx context.Context, userID, provider string) error {
+	result := transaction.DB(ctx, r.db).Where("user_id = ? and provider = ?", userID, provider).Delete(&Identity{})
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrIdentityNotFound{userID, provider}
+	}
+
+	return nil
+}
+
+// ConsumeState stores the nonce of a state, it returns ErrInvalidState
+// when the state was already used. The states that expired a while ago
+// are deleted, the signature check already rejects them
+func (r *repo) ConsumeState(ctx context.Context, nonce string, expires time.Time) error {
+	db := transaction.DB(ctx, r.db)
+
+	if err := db.Where("expires_at < ?", time.Now().Add(-time.Minute)).Delete(&State{}).Error; err != nil {
+		r.logger.Error(err)
+		return err
+	}
+
+	sum := sha256.Sum256([]byte(nonce))
+	if err := db.Create(&State{NonceHash: hex.EncodeToString(sum[:]), ExpiresAt: expires}).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrInvalidState
+		}
+		r.logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func applyFilters(tx *gorm.DB, f Filters) *gorm.DB {
+
+	if f.UserID != nil {
+		tx = tx.Where("user_id in (?)", f.UserID)
+	}
+
+	if f.Provider != nil {
+		tx = tx.Where("provider in (?)", f.Provider)
+	}
+
+	return tx
+}
@@ -0,0 +1,246 @@
+package identity
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/ncostamagna/axul-user/internal/user"
+	domain "github.com/ncostamagna/axul_domain/domain/user"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"strings"
+	"time"
+)
+
+type Filters struct {
+	UserID   []string
+	Provider []string
+}
+
+type Service interface {
+	AuthURL(ctx context.Context, provider, userID string) (string, string, error)
+	Callback(ctx context.Context, provider, code, state, nonce string) (*domain.User, string, error)
+	GetAll(ctx context.Context, userID string) ([]Identity, error)
+	Unlink(ctx context.Context, userID, provider string) error
+}
+
+type service struct {
+	repo      Repository
+	userSrv   user.Service
+	providers map[string]Provider
+	stateKey  []byte
+	logger    loghub.Logger
+}
+
+// NewService is a service handler
+func NewService(repo Repository, userSrv user.Service, providers []Provider, stateKey string, logger loghub.Logger) Service {
+	p := make(map[string]Provider, len(providers))
+	for _, provider := range providers {
+		p[provider.Name()] = provider
+	}
+
+	return &service{
+		repo:      repo,
+		userSrv:   userSrv,
+		providers: p,
+		stateKey:  []byte(stateKey),
+		logger:    logger,
+	}
+}
+
+// AuthURL returns the provider login url and the nonce of its state, the
+// nonce must come back to the callback in a cookie. When userID isn't
+// empty the callback links the external account to that user
+func (s *service) AuthURL(ctx context.Context, provider, userID string) (string, string, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", "", ErrProviderNotFound{provider}
+	}
+
+	st, nonce, err := signState(s.stateKey, provider, userID)
+	if err != nil {
+		s.logger.Error(err)
+		return "", "", err
+	}
+
+	return p.AuthCodeURL(st, nonce), nonce, nil
+}
+
+// Callback logs in the user of the external account, nonce is the nonce of
+// the cookie and the state is consumed so it can't be replayed
+func (s *service) Callback(ctx context.Context, provider, code, rawState, nonce string) (*domain.User, string, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, "", ErrProviderNotFound{provider}
+	}
+
+	st, err := verifyState(s.stateKey, provider, rawState, nonce)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := s.repo.ConsumeState(ctx, st.Nonce, time.Unix(st.Expires, 0)); err != nil {
+		return nil, "", err
+	}
+
+	claims, err := p.Exchange(ctx, code, st.Nonce)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, "", ErrExchange{provider, err}
+	}
+
+	identity, err := s.repo.Get(ctx, provider, claims.Subject)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var u *domain.User
+	switch {
+	case identity != nil && st.UserID != "" && identity.UserID != st.UserID:
+		return nil, "", ErrAlreadyLinked
+
+	case identity != nil:
+		if u, err = s.userSrv.Get(ctx, identity.UserID, ""); err != nil {
+			return nil, "", err
+		}
+
+	case st.UserID != "":
+		if u, err = s.userSrv.Get(ctx, st.UserID, ""); err != nil {
+			return nil, "", err
+		}
+		if err := s.link(ctx, u.ID, provider, claims, false); err != nil {
+			return nil, "", err
+		}
+
+	default:
+		if u, err = s.createUser(ctx, claims); err != nil {
+			return nil, "", err
+		}
+		if err := s.link(ctx, u.ID, provider, claims, true); err != nil {
+			return nil, "", err
+		}
+	}
+
+	token, err := s.userSrv.IssueToken(ctx, u)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.logger.Info(fmt.Sprintf("Login %s User with %s", u.ID, provider))
+	return u, token, nil
+}
+
+func (s *service) GetAll(ctx context.Context, userID string) ([]Identity, error) {
+	return s.repo.GetAll(ctx, Filters{UserID: []string{userID}})
+}
+
+// Unlink removes the identity of the provider, the users created just in
+// time by an identity don't know their password so their last identity
+// can't be removed
+func (s *service) Unlink(ctx context.Context, userID, provider string) error {
+	identities, err := s.repo.GetAll(ctx, Filters{UserID: []string{userID}})
+	if err != nil {
+		return err
+	}
+
+	if len(identities) == 1 && identities[0].Provider == provider && !hasPassword(identities) {
+		return ErrLastLoginMethod
+	}
+
+	if err := s.repo.Delete(ctx, userID, provider); err != nil {
+		return err
+	}
+
+	s.logger.Info(fmt.Sprintf("Unlink %s from %s User", provider, userID))
+	return nil
+}
+
+// hasPassword is false when one of the identities of the user created it
+func hasPassword(identities []Identity) bool {
+	for _, i := range identities {
+		if i.CreatedUser {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *service) link(ctx context.Context, userID, provider string, claims *Claims, createdUser bool) error {
+	identity := Identity{
+		UserID:      userID,
+		Provider:    provider,
+		Subject:     claims.Subject,
+		Email:       claims.Email,
+		CreatedUser: createdUser,
+	}
+
+	if err := s.repo.Create(ctx, &identity); err != nil {
+		s.logger.Error(err)
+		return err
+	}
+
+	s.logger.Info(fmt.Sprintf("Link %s to %s User", provider, userID))
+	return nil
+}
+
+// createUser is the just-in-time account creation for a new external
+// account, the user gets a random password that nobody knows
+func (s *service) createUser(ctx context.Context, claims *Claims) (*domain.User, error) {
+	password, err := random(32)
+	if err != nil {
+		return nil, err
+	}
+
+	userName, err := s.userName(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	firstName, lastName := claims.FirstName, claims.LastName
+	if firstName == "" {
+		firstName = userName
+	}
+
+	email := ""
+	if claims.EmailVerified {
+		email = claims.Email
+	}
+
+	return s.userSrv.Create(ctx, userName, firstName, lastName, password, email, "", "", "", "", "", "")
+}
+
+func (s *service) userName(ctx context.Context, claims *Claims) (string, error) {
+	name := claims.UserName
+	if name == "" {
+		name = claims.Email
+	}
+	// the usernames can't have an @, the providers often use the email
+	name, _, _ = strings.Cut(name, "@")
+	if name == "" {
+		name = "user"
+	}
+
+	count, err := s.userSrv.Count(ctx, user.Filters{UserName: name})
+	if err != nil {
+		return "", err
+	}
+
+	if count == 0 {
+		return name, nil
+	}
+
+	suffix, err := random(3)
+	if err != nil {
+		return "", err
+	}
+
+	return name + "-" + suffix, nil
+}
+
+func random(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,87 @@
+package identity_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/ncostamagna/axul-user/internal/user/identity"
+	"github.com/ncostamagna/axul-user/internal/user/identity/identitytest"
+	"github.com/ncostamagna/axul-user/internal/user/usertest"
+	"github.com/ncostamagna/axul-user/pkg/dbtest"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+)
+
+// login starts a login and returns the state of the provider redirect
+// and the nonce of its cookie, code gets an ID token with nonce
+func login(t *testing.T, srv identity.Service, p *identitytest.IDP, code, nonce string) (string, string) {
+	t.Helper()
+
+	authURL, cookie, err := srv.AuthURL(context.Background(), identity.OIDC, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if nonce == "" {
+		nonce = u.Query().Get("nonce")
+	}
+	p.Code(code, nonce)
+
+	return u.Query().Get("state"), cookie
+}
+
+func newService(t *testing.T) (identity.Service, *identitytest.IDP) {
+	t.Helper()
+
+	p := identitytest.NewIDP(t)
+	userSrv, _ := usertest.NewService(t)
+	logger := loghub.New()
+	return identity.NewService(identity.NewRepository(dbtest.Open(t), logger), userSrv, []identity.Provider{p.Provider(t)}, "state-key", logger), p
+}
+
+// a state only works with the cookie of its browser, once, and the ID
+// token must have its nonce
+func TestCallbackState(t *testing.T) {
+	ctx := context.Background()
+	srv, p := newService(t)
+
+	state, cookie := login(t, srv, p, "other-browser", "")
+	if _, _, err := srv.Callback(ctx, identity.OIDC, "other-browser", state, "other-nonce"); err != identity.ErrInvalidState {
+		t.Errorf("callback with another cookie returned %v, want ErrInvalidState", err)
+	}
+
+	state, cookie = login(t, srv, p, "login", "")
+	if _, _, err := srv.Callback(ctx, identity.OIDC, "login", state, cookie); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := srv.Callback(ctx, identity.OIDC, "login", state, cookie); err != identity.ErrInvalidState {
+		t.Errorf("replayed callback returned %v, want ErrInvalidState", err)
+	}
+
+	state, cookie = login(t, srv, p, "wrong-nonce", "another-nonce")
+	if _, _, err := srv.Callback(ctx, identity.OIDC, "wrong-nonce", state, cookie); !errors.As(err, &identity.ErrExchange{}) {
+		t.Errorf("callback with another ID token nonce returned %v, want ErrExchange", err)
+	}
+}
+
+func TestUnlinkLastIdentity(t *testing.T) {
+	ctx := context.Background()
+	srv, p := newService(t)
+
+	state, cookie := login(t, srv, p, "login", "")
+	u, _, err := srv.Callback(ctx, identity.OIDC, "login", state, cookie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Unlink(ctx, u.ID, identity.OIDC); err != identity.ErrLastLoginMethod {
+		t.Errorf("unlink of the only identity returned %v, want ErrLastLoginMethod", err)
+	}
+}
@@ -0,0 +1,90 @@
+package identity
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// StateDuration is how long a login or a link can take in the provider,
+// the nonce cookie expires with the state
+const StateDuration = 10 * time.Minute
+
+// state travels through the provider redirect, it's signed so the
+// callback can trust the provider and the user we are linking. The nonce
+// is also sent in an HttpOnly cookie to bind the state to the browser
+// that started the login and it's consumed by the callback
+type state struct {
+	Provider string `json:"p"`
+	UserID   string `json:"u,omitempty"`
+	Nonce    string `json:"n"`
+	Expires  int64  `json:"e"`
+}
+
+// signState returns the state and its nonce
+func signState(key []byte, provider, userID string) (string, string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	nonce := hex.EncodeToString(b)
+
+	payload, err := json.Marshal(state{
+		Provider: provider,
+		UserID:   userID,
+		Nonce:    nonce,
+		Expires:  time.Now().Add(StateDuration).Unix(),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	p := base64.RawURLEncoding.EncodeToString(payload)
+	return p + "." + base64.RawURLEncoding.EncodeToString(stateMAC(key, p)), nonce, nil
+}
+
+// verifyState checks the signature of the state and that nonce, the value
+// of the cookie, is the nonce of the state
+func verifyState(key []byte, provider, raw, nonce string) (*state, error) {
+	p, sig, ok := strings.Cut(raw, ".")
+	if !ok {
+		return nil, ErrInvalidState
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, stateMAC(key, p)) {
+		return nil, ErrInvalidState
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(p)
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+
+	var st state
+	if err := json.Unmarshal(payload, &st); err != nil {
+		return nil, ErrInvalidState
+	}
+
+	if st.Provider != provider || time.Now().Unix() > st.Expires {
+		return nil, ErrInvalidState
+	}
+
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(nonce), []byte(st.Nonce)) != 1 {
+		return nil, ErrInvalidState
+	}
+
+	return &st, nil
+}
+
+func stateMAC(key []byte, payload string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
@@ -0,0 +1,133 @@
+package impersonation
+
+import (
+	"context"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/apierror"
+	"github.com/ncostamagna/go-http-utils/response"
+	"time"
+)
+
+type (
+	StartReq struct {
+		ID            string `json:"id"`
+		Reason        string `json:"reason"`
+		Authorization string `json:"Authorization"`
+	}
+
+	StartRes struct {
+		SessionID string    `json:"session_id"`
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	UserReq struct {
+		ID            string `json:"id"`
+		Authorization string `json:"Authorization"`
+	}
+)
+
+type Controller func(ctx context.Context, request interface{}) (interface{}, error)
+
+// Endpoints struct
+type Endpoints struct {
+	Start  Controller
+	Stop   Controller
+	GetAll Controller
+}
+
+func MakeEndpoints(s Service, userSrv user.Service) Endpoints {
+	return Endpoints{
+		Start:  makeStartEndpoint(s, userSrv),
+		Stop:   makeStopEndpoint(s, userSrv),
+		GetAll: makeGetAllEndpoint(s, userSrv),
+	}
+}
+
+func makeStartEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(StartReq)
+
+		info, err := actor(ctx, userSrv, req.Authorization)
+		if err != nil {
+			return nil, err
+		}
+
+		if req.Reason == "" {
+			return nil, apierror.BadRequest(ErrReasonRequired)
+		}
+
+		session, token, err := service.Start(ctx, info.UserID, req.ID, req.Reason)
+		if err != nil {
+			return nil, responseError(err)
+		}
+
+		return response.Created("", StartRes{session.ID, token, session.ExpiresAt}, nil), nil
+	}
+}
+
+func makeStopEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UserReq)
+
+		info, err := userSrv.CheckToken(ctx, req.Authorization)
+		if err != nil {
+			return nil, apierror.Unauthorized(user.InvalidAuthentication)
+		}
+
+		if err := service.Stop(ctx, info, req.ID); err != nil {
+			return nil, responseError(err)
+		}
+
+		return response.OK("", nil, nil), nil
+	}
+}
+
+func makeGetAllEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UserReq)
+
+		info, err := actor(ctx, userSrv, req.Authorization)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions, err := service.GetAll(ctx, info.UserID, req.ID)
+		if err != nil {
+			return nil, responseError(err)
+		}
+
+		return response.OK("", sessions, nil), nil
+	}
+}
+
+// actor returns the admin token info, impersonation tokens and access
+// tokens can't act as admins
+func actor(ctx context.Context, userSrv user.Service, token string) (*user.TokenInfo, error) {
+	info, err := userSrv.CheckToken(ctx, token)
+	if err != nil {
+		return nil, apierror.Unauthorized(user.InvalidAuthentication)
+	}
+
+	if info.ActorID != "" {
+		return nil, apierror.Forbidden(ErrNestedImpersonation)
+	}
+
+	if info.Scopes != nil {
+		return nil, apierror.Forbidden(ErrNotAdmin)
+	}
+
+	return info, nil
+}
+
+func responseError(err error) error {
+	switch err {
+	case ErrNotAdmin, ErrAdminImpersonation:
+		return apierror.Forbidden(err)
+	case ErrSelfImpersonation:
+		return apierror.BadRequest(err)
+	case ErrSessionNotFound, user.NotFound:
+		return apierror.NotFound(err)
+	}
+	return apierror.InternalServerError(err)
+}
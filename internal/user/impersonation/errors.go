@@ -0,0 +1,12 @@
+package impersonation
+
+import (
+	"errors"
+)
+
+var ErrReasonRequired = errors.New("reason is required")
+var ErrNotAdmin = errors.New("only admins can impersonate users")
+var ErrSelfImpersonation = errors.New("you can't impersonate yourself")
+var ErrAdminImpersonation = errors.New("admins can't be impersonated")
+var ErrNestedImpersonation = errors.New("you can't impersonate while impersonating")
+var ErrSessionNotFound = errors.New("there isn't an active impersonation session")
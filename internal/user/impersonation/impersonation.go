@@ -0,0 +1,59 @@
+package impersonation
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	EventStart = "start"
+	EventStop  = "stop"
+)
+
+// Session is an admin acting as another user
+type Session struct {
+	ID        string     `json:"id" gorm:"type:char(36);not null;primary_key;unique_index"`
+	ActorID   string     `json:"actor_id" gorm:"type:char(36);not null;index"`
+	UserID    string     `json:"user_id" gorm:"type:char(36);not null;index"`
+	Reason    string     `json:"reason" gorm:"type:varchar(255);not null"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+	Events    []Event    `json:"events" gorm:"foreignKey:SessionID"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"-"`
+}
+
+func (Session) TableName() string {
+	return "impersonation_sessions"
+}
+
+func (s *Session) BeforeCreate(tx *gorm.DB) (err error) {
+
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return
+}
+
+// Event is the audit trail of the sessions, ActorID is who started or
+// stopped the session
+type Event struct {
+	ID        string    `json:"id" gorm:"type:char(36);not null;primary_key;unique_index"`
+	SessionID string    `json:"session_id" gorm:"type:char(36);not null;index"`
+	Type      string    `json:"type" gorm:"type:char(10);not null"`
+	ActorID   string    `json:"actor_id" gorm:"type:char(36);not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Event) TableName() string {
+	return "impersonation_events"
+}
+
+func (e *Event) BeforeCreate(tx *gorm.DB) (err error) {
+
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return
+}
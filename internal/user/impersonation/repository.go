@@ -0,0 +1,125 @@
+package impersonation
+
+import (
+	"context"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"gorm.io/gorm"
+	"time"
+)
+
+type Repository interface {
+	GetAll(ctx context.Context, userID string) ([]Session, error)
+	Create(ctx context.Context, session *Session) error
+	Stop(ctx context.Context, filters Filters, actorID string) (int, error)
+	Active(ctx context.Context, sessionID string) (bool, error)
+}
+
+type repo struct {
+	db     *gorm.DB
+	logger loghub.Logger
+}
+
+// NewRepository returns the sessions repository, it's also the
+// user.ImpersonationChecker
+func NewRepository(db *gorm.DB, logger loghub.Logger) Repository {
+	return &repo{db, logger}
+}
+
+func (r *repo) GetAll(ctx context.Context, userID string) ([]Session, error) {
+	var sessions []Session
+
+	tx := transaction.DB(ctx, r.db).Preload("Events", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at")
+	})
+	if err := tx.Where("user_id = ?", userID).Order("created_at desc").Find(&sessions).Error; err != nil {
+		r.logger.Error(err)
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Create stores the session with its start event
+func (r *repo) Create(ctx context.Context, session *Session) error {
+	return transaction.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			r.logger.Error(err)
+			return err
+		}
+
+		event := Event{SessionID: session.ID, Type: EventStart, ActorID: session.ActorID}
+		if err := tx.Create(&event).Error; err != nil {
+			r.logger.Error(err)
+			return err
+		}
+
+		session.Events = []Event{event}
+		return nil
+	})
+}
+
+// Stop ends the active sessions that match the filters and records who
+// stopped them, it returns how many sessions were stopped
+func (r *repo) Stop(ctx context.Context, filters Filters, actorID string) (int, error) {
+	var stopped int
+
+	err := transaction.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var sessions []Session
+		q := applyFilters(tx.Where("ended_at is null and expires_at > ?", time.Now()), filters)
+		if err := q.Find(&sessions).Error; err != nil {
+			return err
+		}
+
+		for _, s := range sessions {
+			if err := tx.Model(&Session{}).Where("id = ?", s.ID).Update("ended_at", time.Now()).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Create(&Event{SessionID: s.ID, Type: EventStop, ActorID: actorID}).Error; err != nil {
+				return err
+			}
+		}
+
+		stopped = len(sessions)
+		return nil
+	})
+
+	if err != nil {
+		r.logger.Error(err)
+		return 0, err
+	}
+
+	return stopped, nil
+}
+
+func (r *repo) Active(ctx context.Context, sessionID string) (bool, error) {
+	var count int64
+
+	err := transaction.DB(ctx, r.db).Model(&Session{}).
+		Where("id = ? and ended_at is null and expires_at > ?", sessionID, time.Now()).
+		Count(&count).Error
+	if err != nil {
+		r.logger.Error(err)
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func applyFilters(tx *gorm.DB, f Filters) *gorm.DB {
+
+	if f.ID != "" {
+		tx = tx.Where("id = ?", f.ID)
+	}
+
+	if f.ActorID != "" {
+		tx = tx.Where("actor_id = ?", f.ActorID)
+	}
+
+	if f.UserID != "" {
+		tx = tx.Where("user_id = ?", f.UserID)
+	}
+
+	return tx
+}
@@ -0,0 +1,139 @@
+package impersonation
+
+import (
+	"context"
+	"fmt"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/internal/user/role"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"time"
+)
+
+type Filters struct {
+	ID      string
+	ActorID string
+	UserID  string
+}
+
+type Config struct {
+	AdminApp string
+	TTL      time.Duration
+}
+
+type Service interface {
+	Start(ctx context.Context, actorID, userID, reason string) (*Session, string, error)
+	Stop(ctx context.Context, info *user.TokenInfo, userID string) error
+	GetAll(ctx context.Context, actorID, userID string) ([]Session, error)
+}
+
+type service struct {
+	repo    Repository
+	userSrv user.Service
+	roleSrv role.Service
+	config  Config
+	logger  loghub.Logger
+}
+
+// NewService is a service handler
+func NewService(repo Repository, userSrv user.Service, roleSrv role.Service, config Config, logger loghub.Logger) Service {
+	if config.TTL <= 0 {
+		config.TTL = 15 * time.Minute
+	}
+
+	return &service{
+		repo:    repo,
+		userSrv: userSrv,
+		roleSrv: roleSrv,
+		config:  config,
+		logger:  logger,
+	}
+}
+
+// Start opens an impersonation session and returns the token of the user
+func (s *service) Start(ctx context.Context, actorID, userID, reason string) (*Session, string, error) {
+	if actorID == userID {
+		return nil, "", ErrSelfImpersonation
+	}
+
+	if err := s.admin(ctx, actorID); err != nil {
+		return nil, "", err
+	}
+
+	u, err := s.userSrv.Get(ctx, userID, "")
+	if err != nil {
+		return nil, "", err
+	}
+
+	if s.admin(ctx, userID) == nil {
+		return nil, "", ErrAdminImpersonation
+	}
+
+	session := Session{
+		ActorID:   actorID,
+		UserID:    userID,
+		Reason:    reason,
+		ExpiresAt: time.Now().Add(s.config.TTL),
+	}
+
+	if err := s.repo.Create(ctx, &session); err != nil {
+		return nil, "", err
+	}
+
+	token, err := s.userSrv.IssueImpersonationToken(ctx, u, actorID, session.ID, s.config.TTL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.logger.Info(fmt.Sprintf("Impersonation %s started by %s on %s User: %s", session.ID, actorID, userID, reason))
+	return &session, token, nil
+}
+
+// Stop ends the session of an impersonation token, with the admin's
+// own token it ends every session the admin has on the user
+func (s *service) Stop(ctx context.Context, info *user.TokenInfo, userID string) error {
+	f := Filters{UserID: userID}
+	actorID := info.ActorID
+
+	if info.SessionID != "" {
+		if info.UserID != userID {
+			return ErrSessionNotFound
+		}
+		f.ID = info.SessionID
+	} else {
+		actorID = info.UserID
+		f.ActorID = info.UserID
+	}
+
+	stopped, err := s.repo.Stop(ctx, f, actorID)
+	if err != nil {
+		return err
+	}
+
+	if stopped == 0 {
+		return ErrSessionNotFound
+	}
+
+	s.logger.Info(fmt.Sprintf("Impersonation of %s User stopped by %s", userID, actorID))
+	return nil
+}
+
+func (s *service) GetAll(ctx context.Context, actorID, userID string) ([]Session, error) {
+	if err := s.admin(ctx, actorID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetAll(ctx, userID)
+}
+
+func (s *service) admin(ctx context.Context, userID string) error {
+	admin, err := s.roleSrv.IsAdmin(ctx, s.config.AdminApp, userID)
+	if err != nil {
+		return err
+	}
+
+	if !admin {
+		return ErrNotAdmin
+	}
+
+	return nil
+}
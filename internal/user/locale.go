@@ -0,0 +1,102 @@
+package user
+
+import (
+	"golang.org/x/text/language"
+	"strings"
+	"time"
+)
+
+// Locale is the language tag and the IANA timezone of the user, the base
+// language is also kept in users.language for the other services
+type Locale struct {
+	UserID    string    `json:"-" gorm:"type:char(36);not null;primary_key"`
+	Language  string    `json:"language" gorm:"type:varchar(35)"`
+	Timezone  string    `json:"timezone" gorm:"type:varchar(64)"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+func (Locale) TableName() string {
+	return "user_locales"
+}
+
+// Locales is the registry of the supported languages, the first one is
+// the default
+type Locales struct {
+	tags      []language.Tag
+	supported map[string]bool
+}
+
+// NewLocales builds the registry from BCP 47 tags like "en", "es", "pt-BR"
+func NewLocales(tags ...string) (*Locales, error) {
+	l := &Locales{supported: map[string]bool{}}
+
+	for _, t := range tags {
+		tag, err := language.Parse(strings.TrimSpace(t))
+		if err != nil {
+			return nil, err
+		}
+		l.tags = append(l.tags, tag)
+		l.supported[tag.String()] = true
+	}
+
+	if len(l.tags) == 0 {
+		return NewLocales("en", "es")
+	}
+
+	return l, nil
+}
+
+// Default is the language of the users that don't choose one
+func (l *Locales) Default() string {
+	return l.tags[0].String()
+}
+
+// Resolve returns the supported tag of v, it falls back to the parents
+// of the tag so "pt-BR" is "pt" when only "pt" is supported
+func (l *Locales) Resolve(v string) (string, error) {
+	tag, err := language.Parse(v)
+	if err != nil {
+		return "", ErrUnsupportedLanguage{v}
+	}
+
+	for ; !tag.IsRoot(); tag = tag.Parent() {
+		if l.supported[tag.String()] {
+			return tag.String(), nil
+		}
+	}
+
+	return "", ErrUnsupportedLanguage{v}
+}
+
+// Match picks the supported language of an Accept-Language header
+func (l *Locales) Match(acceptLanguage string) string {
+	tags, _, _ := language.ParseAcceptLanguage(acceptLanguage)
+	for _, t := range tags {
+		if tag, err := l.Resolve(t.String()); err == nil {
+			return tag
+		}
+	}
+
+	return l.Default()
+}
+
+// Base is the two or three letter language of the tag
+func Base(tag string) string {
+	base, _ := language.Make(tag).Base()
+	return base.String()
+}
+
+// ValidTimezone checks the name against the tz database, the binary embeds
+// it so it doesn't depend on the host
+func ValidTimezone(name string) error {
+	if name == "" || name == "Local" {
+		return ErrInvalidTimezone{name}
+	}
+
+	if _, err := time.LoadLocation(name); err != nil {
+		return ErrInvalidTimezone{name}
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package user
+
+import (
+	"github.com/ncostamagna/axul-user/pkg/i18n"
+	auth "github.com/ncostamagna/axul_auth/auth"
+)
+
+// the catalog of errors.go, the English text must match the error
+func init() {
+	// the tokens of axul_auth fail with its own error
+	i18n.RegisterError(auth.ErrInvalidAuthentication, "INVALID_AUTHENTICATION")
+
+	i18n.Register(
+		i18n.Message{Code: "RECORD_NOT_FOUND", Text: map[string]string{
+			i18n.English: "Record not found",
+			i18n.Spanish: "Registro no encontrado",
+		}},
+		i18n.Message{Code: "REQUIRED_VALUES", Text: map[string]string{
+			i18n.English: "Required values",
+			i18n.Spanish: "Valores obligatorios",
+		}},
+		i18n.Message{Code: "INVALID_PASSWORD", Field: "old_password", Text: map[string]string{
+			i18n.English: "Invalid password",
+			i18n.Spanish: "Contraseña inválida",
+		}},
+		i18n.Message{Code: "USER_FORBIDDEN", Text: map[string]string{
+			i18n.English: "you don't have access to this user",
+			i18n.Spanish: "no tienes acceso a este usuario",
+		}},
+		i18n.Message{Code: "SERVICE_ACCOUNT_NOT_ADMIN", Text: map[string]string{
+			i18n.English: "only admins can create service accounts",
+			i18n.Spanish: "solo los administradores pueden crear cuentas de servicio",
+		}},
+		i18n.Message{Code: "DELETE_NOT_ADMIN", Text: map[string]string{
+			i18n.English: "only admins can delete users",
+			i18n.Spanish: "solo los administradores pueden eliminar usuarios",
+		}},
+		i18n.Message{Code: "IMPERSONATION_NOT_ALLOWED", Text: map[string]string{
+			i18n.English: "this operation isn't allowed while impersonating a user",
+			i18n.Spanish: "esta operación no está permitida mientras se suplanta a un usuario",
+		}},
+		i18n.Message{Code: "FIRST_NAME_REQUIRED", Field: "firstname", Text: map[string]string{
+			i18n.English: "first name is required",
+			i18n.Spanish: "el nombre es obligatorio",
+		}},
+		i18n.Message{Code: "LAST_NAME_REQUIRED", Field: "lastname", Text: map[string]string{
+			i18n.English: "last name is required",
+			i18n.Spanish: "el apellido es obligatorio",
+		}},
+		i18n.Message{Code: "EMAIL_REQUIRED", Field: "email", Text: map[string]string{
+			i18n.English: "email is required",
+			i18n.Spanish: "el email es obligatorio",
+		}},
+		i18n.Message{Code: "NEW_PASSWORD_REQUIRED", Field: "new_password", Text: map[string]string{
+			i18n.English: "new password is required",
+			i18n.Spanish: "la nueva contraseña es obligatoria",
+		}},
+		i18n.Message{Code: "OLD_PASSWORD_REQUIRED", Field: "old_password", Text: map[string]string{
+			i18n.English: "old password is required",
+			i18n.Spanish: "la contraseña anterior es obligatoria",
+		}},
+		i18n.Message{Code: "NAME_REQUIRED", Field: "name", Text: map[string]string{
+			i18n.English: "name is required",
+			i18n.Spanish: "el nombre es obligatorio",
+		}},
+		i18n.Message{Code: "PHONE_CHANGED", Field: "phone", Text: map[string]string{
+			i18n.English: "the phone changed after the code was sent",
+			i18n.Spanish: "el teléfono cambió después de enviar el código",
+		}},
+		i18n.Message{Code: "SAME_EMAIL", Field: "email", Text: map[string]string{
+			i18n.English: "the new email is the current one",
+			i18n.Spanish: "el nuevo email es el actual",
+		}},
+		i18n.Message{Code: "CLIENT_CREDENTIALS_REQUIRED", Field: "client_id", Text: map[string]string{
+			i18n.English: "client id and client secret are required",
+			i18n.Spanish: "el client id y el client secret son obligatorios",
+		}},
+		i18n.Message{Code: "USER_NOT_FOUND", Text: map[string]string{
+			i18n.English: "user '%s' doesn't exist",
+			i18n.Spanish: "el usuario '%s' no existe",
+		}},
+		i18n.Message{Code: "USERNAME_INVALID", Field: "username", Text: map[string]string{
+			i18n.English: "username '%s' isn't valid, it can't have an @",
+			i18n.Spanish: "el nombre de usuario '%s' no es válido, no puede tener una @",
+		}},
+		i18n.Message{Code: "PHONE_INVALID", Field: "phone", Text: map[string]string{
+			i18n.English: "phone '%s' isn't valid, it must be in international format like +5491112345678",
+			i18n.Spanish: "el teléfono '%s' no es válido, debe estar en formato internacional como +5491112345678",
+		}},
+		i18n.Message{Code: "LANGUAGE_UNSUPPORTED", Field: "language", Text: map[string]string{
+			i18n.English: "language '%s' isn't supported",
+			i18n.Spanish: "el idioma '%s' no está soportado",
+		}},
+		i18n.Message{Code: "TIMEZONE_INVALID", Field: "timezone", Text: map[string]string{
+			i18n.English: "timezone '%s' isn't a valid IANA timezone like America/Argentina/Buenos_Aires",
+			i18n.Spanish: "la zona horaria '%s' no es una zona IANA válida como America/Argentina/Buenos_Aires",
+		}},
+		i18n.Message{Code: "USER_ALREADY_EXISTS", Text: map[string]string{
+			i18n.English: "user already exists",
+			i18n.Spanish: "el usuario ya existe",
+		}},
+		i18n.Message{Code: "USERNAME_ALREADY_EXISTS", Field: "username", Text: map[string]string{
+			i18n.English: "a user with this username already exists",
+			i18n.Spanish: "ya existe un usuario con este nombre de usuario",
+		}},
+		i18n.Message{Code: "EMAIL_ALREADY_EXISTS", Field: "email", Text: map[string]string{
+			i18n.English: "a user with this email already exists",
+			i18n.Spanish: "ya existe un usuario con este email",
+		}},
+		i18n.Message{Code: "USER_VERSION_CONFLICT", Text: map[string]string{
+			i18n.English: "user '%s' was updated by another request, get it again and retry",
+			i18n.Spanish: "el usuario '%s' fue actualizado por otra solicitud, vuelve a obtenerlo y reintenta",
+		}},
+	)
+}
@@ -0,0 +1,60 @@
+package user_test
+
+import (
+	"testing"
+
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/internal/user/role"
+	"github.com/ncostamagna/axul-user/pkg/cursor"
+	"github.com/ncostamagna/axul-user/pkg/i18n"
+	"github.com/ncostamagna/axul-user/pkg/validation"
+)
+
+// the responses are rendered from the catalog by code, the English text of
+// the catalog must be the text of the error
+func TestMessages(t *testing.T) {
+	errs := []i18n.Coded{
+		user.NotFound,
+		user.FieldIsRequired,
+		user.InvalidAuthentication,
+		user.InvalidPassword,
+		user.ErrForbidden,
+		user.ErrImpersonationNotAllowed,
+		user.ErrNotAdmin,
+		user.ErrFirstNameRequired,
+		user.ErrLastNameRequired,
+		user.ErrEmailRequired,
+		user.ErrNewPasswordRequired,
+		user.ErrOldPasswordRequired,
+		user.ErrNameRequired,
+		user.ErrPhoneChanged,
+		user.ErrClientCredentialsRequired,
+		user.ErrNotFound{UserID: "1"},
+		user.ErrInvalidUserName{UserName: "john@example.com"},
+		user.ErrInvalidPhone{Phone: "12"},
+		user.ErrUnsupportedLanguage{Language: "xx"},
+		user.ErrInvalidTimezone{Timezone: "Mars/Olympus"},
+		user.ErrUserAlreadyExists{},
+		user.ErrUserAlreadyExists{Field: "username"},
+		user.ErrUserAlreadyExists{Field: "email"},
+		user.ErrVersionConflict{UserID: "1"},
+		role.ErrUserIDAndAppAreRequired,
+		role.ErrNotAdmin,
+		role.ErrUserAppNotFound{UserID: "1", App: "crm"},
+		role.ErrVersionConflict{UserID: "1", App: "crm"},
+		role.InvalidRole{Role: "root"},
+		cursor.ErrInvalid,
+		validation.Field("email", validation.Required, ""),
+	}
+
+	for _, err := range errs {
+		if _, ok := i18n.Get(err.Code()); !ok {
+			t.Errorf("%s isn't in the catalog", err.Code())
+			continue
+		}
+
+		if got := i18n.Render(err.Code(), i18n.English, err.Args()...); got != err.Error() {
+			t.Errorf("%s: the catalog renders %q, the error is %q", err.Code(), got, err.Error())
+		}
+	}
+}
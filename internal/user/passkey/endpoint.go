@@ -0,0 +1,205 @@
+package passkey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/apierror"
+	"github.com/ncostamagna/go-http-utils/response"
+)
+
+type (
+	UserReq struct {
+		ID            string `json:"id"`
+		Passkey       string `json:"passkey"`
+		Authorization string `json:"Authorization"`
+	}
+
+	RegisterReq struct {
+		ID            string          `json:"id"`
+		SessionID     string          `json:"session_id"`
+		Name          string          `json:"name"`
+		Credential    json.RawMessage `json:"credential"`
+		Authorization string          `json:"Authorization"`
+	}
+
+	RenameReq struct {
+		ID            string `json:"id"`
+		Passkey       string `json:"passkey"`
+		Name          string `json:"name"`
+		Authorization string `json:"Authorization"`
+	}
+
+	LoginReq struct {
+		SessionID  string          `json:"session_id"`
+		Credential json.RawMessage `json:"credential"`
+	}
+
+	CreationRes struct {
+		SessionID string                       `json:"session_id"`
+		Options   *protocol.CredentialCreation `json:"options"`
+	}
+
+	AssertionRes struct {
+		SessionID string                        `json:"session_id"`
+		Options   *protocol.CredentialAssertion `json:"options"`
+	}
+)
+
+type Controller func(ctx context.Context, request interface{}) (interface{}, error)
+
+// Endpoints struct
+type Endpoints struct {
+	BeginRegistration  Controller
+	FinishRegistration Controller
+	GetAll             Controller
+	Rename             Controller
+	Delete             Controller
+	BeginLogin         Controller
+	FinishLogin        Controller
+}
+
+func MakeEndpoints(s Service, userSrv user.Service) Endpoints {
+	return Endpoints{
+		BeginRegistration:  makeBeginRegistrationEndpoint(s, userSrv),
+		FinishRegistration: makeFinishRegistrationEndpoint(s, userSrv),
+		GetAll:             makeGetAllEndpoint(s, userSrv),
+		Rename:             makeRenameEndpoint(s, userSrv),
+		Delete:             makeDeleteEndpoint(s, userSrv),
+		BeginLogin:         makeBeginLoginEndpoint(s),
+		FinishLogin:        makeFinishLoginEndpoint(s),
+	}
+}
+
+func makeBeginRegistrationEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UserReq)
+
+		if _, err := userSrv.Authorize(ctx, req.Authorization, req.ID, user.ScopeUsersWrite); err != nil {
+			return nil, user.AuthorizationError(err)
+		}
+
+		creation, id, err := service.BeginRegistration(ctx, req.ID)
+		if err != nil {
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", CreationRes{id, creation}, nil), nil
+	}
+}
+
+func makeFinishRegistrationEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RegisterReq)
+
+		if _, err := userSrv.Authorize(ctx, req.Authorization, req.ID, user.ScopeUsersWrite); err != nil {
+			return nil, user.AuthorizationError(err)
+		}
+
+		if req.SessionID == "" || len(req.Credential) == 0 {
+			return nil, apierror.BadRequest(ErrSessionRequired)
+		}
+
+		credential, err := service.FinishRegistration(ctx, req.ID, req.SessionID, req.Name, req.Credential)
+		if err != nil {
+			if err == ErrInvalidSession || err == ErrInvalidCredential {
+				return nil, apierror.BadRequest(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.Created("", credential, nil), nil
+	}
+}
+
+func makeGetAllEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UserReq)
+
+		if _, err := userSrv.Authorize(ctx, req.Authorization, req.ID, user.ScopeUsersRead); err != nil {
+			return nil, user.AuthorizationError(err)
+		}
+
+		credentials, err := service.GetAll(ctx, req.ID)
+		if err != nil {
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", credentials, nil), nil
+	}
+}
+
+func makeRenameEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RenameReq)
+
+		if _, err := userSrv.Authorize(ctx, req.Authorization, req.ID, user.ScopeUsersWrite); err != nil {
+			return nil, user.AuthorizationError(err)
+		}
+
+		if req.Name == "" {
+			return nil, apierror.BadRequest(ErrNameRequired)
+		}
+
+		if err := service.Rename(ctx, req.ID, req.Passkey, req.Name); err != nil {
+			if errors.As(err, &ErrNotFound{}) {
+				return nil, apierror.NotFound(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", nil, nil), nil
+	}
+}
+
+func makeDeleteEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UserReq)
+
+		if _, err := userSrv.Authorize(ctx, req.Authorization, req.ID, user.ScopeUsersWrite); err != nil {
+			return nil, user.AuthorizationError(err)
+		}
+
+		if err := service.Delete(ctx, req.ID, req.Passkey); err != nil {
+			if errors.As(err, &ErrNotFound{}) {
+				return nil, apierror.NotFound(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", nil, nil), nil
+	}
+}
+
+func makeBeginLoginEndpoint(service Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		assertion, id, err := service.BeginLogin(ctx)
+		if err != nil {
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", AssertionRes{id, assertion}, nil), nil
+	}
+}
+
+func makeFinishLoginEndpoint(service Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(LoginReq)
+
+		if req.SessionID == "" || len(req.Credential) == 0 {
+			return nil, apierror.BadRequest(ErrSessionRequired)
+		}
+
+		u, token, err := service.FinishLogin(ctx, req.SessionID, req.Credential)
+		if err != nil {
+			if err == ErrInvalidSession || err == ErrInvalidCredential || err == ErrCloneWarning {
+				return nil, apierror.Unauthorized(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", user.LoginRes{User: u, Token: token}, nil), nil
+	}
+}
@@ -0,0 +1,21 @@
+package passkey
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrSessionRequired = errors.New("session id and credential are required")
+var ErrNameRequired = errors.New("name is required")
+var ErrInvalidSession = errors.New("invalid or expired passkey session")
+var ErrInvalidCredential = errors.New("invalid passkey")
+var ErrCloneWarning = errors.New("the passkey authenticator may be cloned")
+
+type ErrNotFound struct {
+	UserID  string
+	Passkey string
+}
+
+func (e ErrNotFound) Error() string {
+	return fmt.Sprintf("passkey '%s' of user '%s' doesn't exist", e.Passkey, e.UserID)
+}
@@ -0,0 +1,146 @@
+package passkey
+
+import (
+	"encoding/base64"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	domain "github.com/ncostamagna/axul_domain/domain/user"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+const (
+	sessionRegistration = "registration"
+	sessionLogin        = "login"
+)
+
+// Credential is a passkey registered by a user
+type Credential struct {
+	ID              string     `json:"id" gorm:"type:char(36);not null;primary_key;unique_index"`
+	UserID          string     `json:"user_id" gorm:"type:char(36);not null;index"`
+	Name            string     `json:"name" gorm:"type:char(70);not null"`
+	CredentialID    string     `json:"credential_id" gorm:"type:varchar(255);not null;uniqueIndex"`
+	PublicKey       []byte     `json:"-" gorm:"not null"`
+	AttestationType string     `json:"-" gorm:"type:char(30)"`
+	AAGUID          []byte     `json:"-"`
+	SignCount       uint32     `json:"-"`
+	Transports      string     `json:"transports" gorm:"type:varchar(100)"`
+	BackupEligible  bool       `json:"backup_eligible"`
+	BackupState     bool       `json:"backup_state"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"-"`
+}
+
+func (Credential) TableName() string {
+	return "passkeys"
+}
+
+func (c *Credential) BeforeCreate(tx *gorm.DB) (err error) {
+
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return
+}
+
+func newCredential(userID, name string, c *webauthn.Credential) Credential {
+	transports := make([]string, 0, len(c.Transport))
+	for _, t := range c.Transport {
+		transports = append(transports, string(t))
+	}
+
+	return Credential{
+		UserID:          userID,
+		Name:            name,
+		CredentialID:    base64.RawURLEncoding.EncodeToString(c.ID),
+		PublicKey:       c.PublicKey,
+		AttestationType: c.AttestationType,
+		AAGUID:          c.Authenticator.AAGUID,
+		SignCount:       c.Authenticator.SignCount,
+		Transports:      strings.Join(transports, ","),
+		BackupEligible:  c.Flags.BackupEligible,
+		BackupState:     c.Flags.BackupState,
+	}
+}
+
+func (c Credential) webauthn() webauthn.Credential {
+	id, _ := base64.RawURLEncoding.DecodeString(c.CredentialID)
+
+	var transports []protocol.AuthenticatorTransport
+	if c.Transports != "" {
+		for _, t := range strings.Split(c.Transports, ",") {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+	}
+
+	return webauthn.Credential{
+		ID:              id,
+		PublicKey:       c.PublicKey,
+		AttestationType: c.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: c.BackupEligible,
+			BackupState:    c.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    c.AAGUID,
+			SignCount: c.SignCount,
+		},
+	}
+}
+
+// Session keeps the webauthn challenge between the begin and finish
+// requests of a ceremony
+type Session struct {
+	ID        string    `gorm:"type:char(36);not null;primary_key;unique_index"`
+	UserID    string    `gorm:"type:char(36)"`
+	Kind      string    `gorm:"type:char(20);not null"`
+	Data      string    `gorm:"type:text;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+func (Session) TableName() string {
+	return "passkey_sessions"
+}
+
+func (s *Session) BeforeCreate(tx *gorm.DB) (err error) {
+
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return
+}
+
+// webauthnUser adapts a domain user and its passkeys to webauthn.User
+type webauthnUser struct {
+	*domain.User
+	credentials []Credential
+}
+
+func (u *webauthnUser) WebAuthnID() []byte {
+	return []byte(u.ID)
+}
+
+func (u *webauthnUser) WebAuthnName() string {
+	return u.UserName
+}
+
+func (u *webauthnUser) WebAuthnDisplayName() string {
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
+
+func (u *webauthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *webauthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, c := range u.credentials {
+		credentials = append(credentials, c.webauthn())
+	}
+	return credentials
+}
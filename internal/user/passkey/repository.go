@@ -0,0 +1,138 @@
+package passkey
+
+import (
+	"context"
+	"errors"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"gorm.io/gorm"
+	"time"
+)
+
+type Repository interface {
+	GetAll(ctx context.Context, userID string) ([]Credential, error)
+	GetByCredentialID(ctx context.Context, credentialID string) (*Credential, error)
+	Create(ctx context.Context, credential *Credential) error
+	Update(ctx context.Context, userID, id string, name *string) error
+	Used(ctx context.Context, id string, signCount uint32, backupState bool) error
+	Delete(ctx context.Context, userID, id string) error
+	CreateSession(ctx context.Context, session *Session) error
+	TakeSession(ctx context.Context, id, kind string) (*Session, error)
+}
+
+type repo struct {
+	db     *gorm.DB
+	logger loghub.Logger
+}
+
+func NewRepository(db *gorm.DB, logger loghub.Logger) Repository {
+	return &repo{db, logger}
+}
+
+func (r *repo) GetAll(ctx context.Context, userID string) ([]Credential, error) {
+	var credentials []Credential
+
+	if err := transaction.DB(ctx, r.db).Where("user_id = ?", userID).Order("created_at desc").Find(&credentials).Error; err != nil {
+		r.logger.Error(err)
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+func (r *repo) GetByCredentialID(ctx context.Context, credentialID string) (*Credential, error) {
+	var credential Credential
+
+	if err := transaction.DB(ctx, r.db).Where("credential_id = ?", credentialID).First(&credential).Error; err != nil {
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+func (r *repo) Create(ctx context.Context, credential *Credential) error {
+	return transaction.DB(ctx, r.db).Create(credential).Error
+}
+
+func (r *repo) Update(ctx context.Context, userID, id string, name *string) error {
+	values := make(map[string]interface{})
+
+	if name != nil {
+		values["name"] = *name
+	}
+
+	result := transaction.DB(ctx, r.db).Model(&Credential{}).Where("id = ? and user_id = ?", id, userID).Updates(values)
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound{userID, id}
+	}
+
+	return nil
+}
+
+func (r *repo) Used(ctx context.Context, id string, signCount uint32, backupState bool) error {
+	result := transaction.DB(ctx, r.db).Model(&Credential{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"sign_count":   signCount,
+		"backup_state": backupState,
+		"last_used_at": time.Now(),
+	})
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+func (r *repo) Delete(ctx context.Context, userID, id string) error {
+	result := transaction.DB(ctx, r.db).Where("id = ? and user_id = ?", id, userID).Delete(&Credential{})
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound{userID, id}
+	}
+
+	return nil
+}
+
+// CreateSession also removes the expired sessions of abandoned ceremonies
+func (r *repo) CreateSession(ctx context.Context, session *Session) error {
+	if err := transaction.DB(ctx, r.db).Where("expires_at < ?", time.Now()).Delete(&Session{}).Error; err != nil {
+		r.logger.Error(err)
+	}
+
+	return transaction.DB(ctx, r.db).Create(session).Error
+}
+
+// TakeSession returns the session and deletes it, so every ceremony
+// can be finished only once
+func (r *repo) TakeSession(ctx context.Context, id, kind string) (*Session, error) {
+	var session Session
+
+	if err := transaction.DB(ctx, r.db).Where("id = ? and kind = ?", id, kind).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidSession
+		}
+		r.logger.Error(err)
+		return nil, err
+	}
+
+	result := transaction.DB(ctx, r.db).Where("id = ?", id).Delete(&Session{})
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidSession
+	}
+
+	return &session, nil
+}
@@ -0,0 +1,247 @@
+package passkey
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/ncostamagna/axul-user/internal/user"
+	domain "github.com/ncostamagna/axul_domain/domain/user"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"time"
+)
+
+const sessionDuration = 5 * time.Minute
+
+type Service interface {
+	BeginRegistration(ctx context.Context, userID string) (*protocol.CredentialCreation, string, error)
+	FinishRegistration(ctx context.Context, userID, sessionID, name string, body []byte) (*Credential, error)
+	BeginLogin(ctx context.Context) (*protocol.CredentialAssertion, string, error)
+	FinishLogin(ctx context.Context, sessionID string, body []byte) (*domain.User, string, error)
+	GetAll(ctx context.Context, userID string) ([]Credential, error)
+	Rename(ctx context.Context, userID, id, name string) error
+	Delete(ctx context.Context, userID, id string) error
+}
+
+type service struct {
+	repo     Repository
+	userSrv  user.Service
+	webauthn *webauthn.WebAuthn
+	logger   loghub.Logger
+}
+
+// NewService is a service handler
+func NewService(repo Repository, userSrv user.Service, w *webauthn.WebAuthn, logger loghub.Logger) Service {
+	return &service{
+		repo:     repo,
+		userSrv:  userSrv,
+		webauthn: w,
+		logger:   logger,
+	}
+}
+
+func (s *service) BeginRegistration(ctx context.Context, userID string) (*protocol.CredentialCreation, string, error) {
+	u, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(u.credentials))
+	for _, c := range u.WebAuthnCredentials() {
+		exclusions = append(exclusions, c.Descriptor())
+	}
+
+	creation, data, err := s.webauthn.BeginRegistration(u,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, "", err
+	}
+
+	id, err := s.saveSession(ctx, userID, sessionRegistration, data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return creation, id, nil
+}
+
+func (s *service) FinishRegistration(ctx context.Context, userID, sessionID, name string, body []byte) (*Credential, error) {
+	data, err := s.session(ctx, sessionID, sessionRegistration)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(data.UserID) != userID {
+		return nil, ErrInvalidSession
+	}
+
+	u, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(body))
+	if err != nil {
+		s.logger.Warn(err)
+		return nil, ErrInvalidCredential
+	}
+
+	c, err := s.webauthn.CreateCredential(u, *data, parsed)
+	if err != nil {
+		s.logger.Warn(err)
+		return nil, ErrInvalidCredential
+	}
+
+	if name == "" {
+		name = fmt.Sprintf("Passkey %d", len(u.credentials)+1)
+	}
+
+	credential := newCredential(userID, name, c)
+	if err := s.repo.Create(ctx, &credential); err != nil {
+		s.logger.Error(err)
+		return nil, err
+	}
+
+	s.logger.Info(fmt.Sprintf("Register passkey %s for %s User", credential.ID, userID))
+	return &credential, nil
+}
+
+// BeginLogin starts a discoverable login, the authenticator chooses the
+// passkey so the user doesn't need to type the username
+func (s *service) BeginLogin(ctx context.Context) (*protocol.CredentialAssertion, string, error) {
+	assertion, data, err := s.webauthn.BeginDiscoverableLogin()
+	if err != nil {
+		s.logger.Error(err)
+		return nil, "", err
+	}
+
+	id, err := s.saveSession(ctx, "", sessionLogin, data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return assertion, id, nil
+}
+
+func (s *service) FinishLogin(ctx context.Context, sessionID string, body []byte) (*domain.User, string, error) {
+	data, err := s.session(ctx, sessionID, sessionLogin)
+	if err != nil {
+		return nil, "", err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(body))
+	if err != nil {
+		s.logger.Warn(err)
+		return nil, "", ErrInvalidCredential
+	}
+
+	var u *webauthnUser
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		u, err = s.user(ctx, string(userHandle))
+		return u, err
+	}
+
+	c, err := s.webauthn.ValidateDiscoverableLogin(handler, *data, parsed)
+	if err != nil {
+		s.logger.Warn(err)
+		return nil, "", ErrInvalidCredential
+	}
+
+	if c.Authenticator.CloneWarning {
+		s.logger.Warn(ErrCloneWarning)
+		return nil, "", ErrCloneWarning
+	}
+
+	stored, err := s.repo.GetByCredentialID(ctx, base64.RawURLEncoding.EncodeToString(c.ID))
+	if err != nil || stored.UserID != u.ID {
+		return nil, "", ErrInvalidCredential
+	}
+
+	if err := s.repo.Used(ctx, stored.ID, c.Authenticator.SignCount, c.Flags.BackupState); err != nil {
+		return nil, "", err
+	}
+
+	token, err := s.userSrv.IssueToken(ctx, u.User)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.logger.Info(fmt.Sprintf("Login %s User with passkey %s", u.ID, stored.ID))
+	return u.User, token, nil
+}
+
+func (s *service) GetAll(ctx context.Context, userID string) ([]Credential, error) {
+	return s.repo.GetAll(ctx, userID)
+}
+
+func (s *service) Rename(ctx context.Context, userID, id, name string) error {
+	return s.repo.Update(ctx, userID, id, &name)
+}
+
+func (s *service) Delete(ctx context.Context, userID, id string) error {
+	if err := s.repo.Delete(ctx, userID, id); err != nil {
+		return err
+	}
+
+	s.logger.Info(fmt.Sprintf("Delete passkey %s of %s User", id, userID))
+	return nil
+}
+
+func (s *service) user(ctx context.Context, userID string) (*webauthnUser, error) {
+	u, err := s.userSrv.Get(ctx, userID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.repo.GetAll(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webauthnUser{u, credentials}, nil
+}
+
+func (s *service) saveSession(ctx context.Context, userID, kind string, data *webauthn.SessionData) (string, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	session := Session{
+		UserID:    userID,
+		Kind:      kind,
+		Data:      string(b),
+		ExpiresAt: time.Now().Add(sessionDuration),
+	}
+
+	if err := s.repo.CreateSession(ctx, &session); err != nil {
+		s.logger.Error(err)
+		return "", err
+	}
+
+	return session.ID, nil
+}
+
+func (s *service) session(ctx context.Context, id, kind string) (*webauthn.SessionData, error) {
+	session, err := s.repo.TakeSession(ctx, id, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrInvalidSession
+	}
+
+	var data webauthn.SessionData
+	if err := json.Unmarshal([]byte(session.Data), &data); err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
@@ -0,0 +1,39 @@
+package passwordless
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	MethodLink = "link"
+	MethodCode = "code"
+)
+
+// Challenge is a single-use login sent by email, only the hashes of the
+// secret and the device are stored
+type Challenge struct {
+	ID         string     `json:"id" gorm:"type:char(36);not null;primary_key;unique_index"`
+	UserID     string     `json:"user_id" gorm:"type:char(36);not null;index"`
+	Method     string     `json:"method" gorm:"type:char(10);not null"`
+	SecretHash string     `json:"-" gorm:"type:char(64);not null"`
+	DeviceHash string     `json:"-" gorm:"type:char(64);not null"`
+	Attempts   int        `json:"attempts" gorm:"not null;default:0"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	UsedAt     *time.Time `json:"used_at"`
+	CreatedAt  time.Time  `json:"-"`
+	UpdatedAt  time.Time  `json:"-"`
+}
+
+func (Challenge) TableName() string {
+	return "magic_challenges"
+}
+
+func (c *Challenge) BeforeCreate(tx *gorm.DB) (err error) {
+
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return
+}
@@ -0,0 +1,101 @@
+package passwordless
+
+import (
+	"context"
+	"errors"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/apierror"
+	"github.com/ncostamagna/go-http-utils/response"
+	"strings"
+)
+
+type (
+	MagicReq struct {
+		UserName string `json:"username"`
+		Method   string `json:"method"`
+		Device   string `json:"device_id"`
+	}
+
+	MagicRes struct {
+		ChallengeID string `json:"challenge_id"`
+	}
+
+	VerifyReq struct {
+		Token       string `json:"token"`
+		ChallengeID string `json:"challenge_id"`
+		Code        string `json:"code"`
+		Device      string `json:"device_id"`
+	}
+)
+
+type Controller func(ctx context.Context, request interface{}) (interface{}, error)
+
+// Endpoints struct
+type Endpoints struct {
+	Request Controller
+	Verify  Controller
+}
+
+func MakeEndpoints(s Service) Endpoints {
+	return Endpoints{
+		Request: makeRequestEndpoint(s),
+		Verify:  makeVerifyEndpoint(s),
+	}
+}
+
+func makeRequestEndpoint(service Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(MagicReq)
+
+		if req.UserName == "" {
+			return nil, apierror.BadRequest(ErrUserNameRequired)
+		}
+
+		if req.Device == "" {
+			return nil, apierror.BadRequest(ErrDeviceRequired)
+		}
+
+		if req.Method == "" {
+			req.Method = MethodLink
+		}
+
+		id, err := service.Request(ctx, req.UserName, req.Method, req.Device)
+		if err != nil {
+			if errors.As(err, &ErrInvalidMethod{}) {
+				return nil, apierror.BadRequest(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.Accepted("", MagicRes{id}, nil), nil
+	}
+}
+
+func makeVerifyEndpoint(service Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(VerifyReq)
+
+		if req.Device == "" {
+			return nil, apierror.BadRequest(ErrDeviceRequired)
+		}
+
+		id, secret := req.ChallengeID, req.Code
+		if req.Token != "" {
+			id, secret, _ = strings.Cut(req.Token, ".")
+		}
+
+		if id == "" || secret == "" {
+			return nil, apierror.BadRequest(ErrSecretRequired)
+		}
+
+		u, token, err := service.Verify(ctx, id, secret, req.Device)
+		if err != nil {
+			if err == ErrInvalidChallenge || err == ErrTooManyAttempts {
+				return nil, apierror.Unauthorized(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", user.LoginRes{User: u, Token: token}, nil), nil
+	}
+}
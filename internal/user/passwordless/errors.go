@@ -0,0 +1,20 @@
+package passwordless
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUserNameRequired = errors.New("username is required")
+var ErrDeviceRequired = errors.New("device is required")
+var ErrSecretRequired = errors.New("token or challenge id and code are required")
+var ErrInvalidChallenge = errors.New("invalid or expired login")
+var ErrTooManyAttempts = errors.New("too many attempts, request a new login")
+
+type ErrInvalidMethod struct {
+	Method string
+}
+
+func (e ErrInvalidMethod) Error() string {
+	return fmt.Sprintf("method '%s' isn't valid, it must be 'link' or 'code'", e.Method)
+}
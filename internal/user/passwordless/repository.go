@@ -0,0 +1,100 @@
+package passwordless
+
+import (
+	"context"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"gorm.io/gorm"
+	"time"
+)
+
+type Repository interface {
+	Get(ctx context.Context, id string) (*Challenge, error)
+	Create(ctx context.Context, challenge *Challenge) error
+	AddAttempt(ctx context.Context, id string, max int) error
+	CountSince(ctx context.Context, userID string, since time.Time) (int, error)
+	Use(ctx context.Context, id string) error
+	Invalidate(ctx context.Context, userID string) error
+}
+
+type repo struct {
+	db     *gorm.DB
+	logger loghub.Logger
+}
+
+func NewRepository(db *gorm.DB, logger loghub.Logger) Repository {
+	return &repo{db, logger}
+}
+
+func (r *repo) Get(ctx context.Context, id string) (*Challenge, error) {
+	var challenge Challenge
+
+	if err := transaction.DB(ctx, r.db).Where("id = ?", id).First(&challenge).Error; err != nil {
+		return nil, err
+	}
+
+	return &challenge, nil
+}
+
+func (r *repo) Create(ctx context.Context, challenge *Challenge) error {
+	return transaction.DB(ctx, r.db).Create(challenge).Error
+}
+
+// AddAttempt counts an attempt of the challenge, it fails with
+// ErrTooManyAttempts when it already has max attempts. The check and the
+// increment are a single update so concurrent attempts can't pass max
+func (r *repo) AddAttempt(ctx context.Context, id string, max int) error {
+	result := transaction.DB(ctx, r.db).Model(&Challenge{}).Where("id = ? and attempts < ?", id, max).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrTooManyAttempts
+	}
+
+	return nil
+}
+
+// CountSince returns the challenges requested by the user since a time
+func (r *repo) CountSince(ctx context.Context, userID string, since time.Time) (int, error) {
+	var count int64
+	err := transaction.DB(ctx, r.db).Model(&Challenge{}).Where("user_id = ? and created_at > ?", userID, since).
+		Count(&count).Error
+	if err != nil {
+		r.logger.Error(err)
+		return 0, err
+	}
+
+	return int(count), nil
+}
+
+// Use marks the challenge as used, it fails when another request used it first
+func (r *repo) Use(ctx context.Context, id string) error {
+	result := transaction.DB(ctx, r.db).Model(&Challenge{}).Where("id = ? and used_at is null", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrInvalidChallenge
+	}
+
+	return nil
+}
+
+// Invalidate marks every pending challenge of the user as used
+func (r *repo) Invalidate(ctx context.Context, userID string) error {
+	result := transaction.DB(ctx, r.db).Model(&Challenge{}).Where("user_id = ? and used_at is null", userID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+		return result.Error
+	}
+
+	return nil
+}
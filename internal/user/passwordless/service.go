@@ -0,0 +1,220 @@
+package passwordless
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/mailer"
+	"github.com/ncostamagna/axul-user/pkg/replica"
+	domain "github.com/ncostamagna/axul_domain/domain/user"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"math/big"
+	"net/url"
+	"time"
+)
+
+// Config.MaxRequests is the number of logins a user can request in a TTL
+type Config struct {
+	LinkURL     string
+	TTL         time.Duration
+	MaxAttempts int
+	MaxRequests int
+}
+
+type Service interface {
+	Request(ctx context.Context, login, method, device string) (string, error)
+	Verify(ctx context.Context, challengeID, secret, device string) (*domain.User, string, error)
+}
+
+type service struct {
+	repo    Repository
+	userSrv user.Service
+	mailer  mailer.Mailer
+	config  Config
+	logger  loghub.Logger
+}
+
+// NewService is a service handler
+func NewService(repo Repository, userSrv user.Service, m mailer.Mailer, config Config, logger loghub.Logger) Service {
+	if config.TTL <= 0 {
+		config.TTL = 15 * time.Minute
+	}
+
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+
+	if config.MaxRequests <= 0 {
+		config.MaxRequests = 5
+	}
+
+	return &service{
+		repo:    repo,
+		userSrv: userSrv,
+		mailer:  m,
+		config:  config,
+		logger:  logger,
+	}
+}
+
+// Request emails a login link or code and returns the challenge id, an
+// unknown user gets a random id so the response doesn't reveal accounts.
+// A user that requested MaxRequests logins in the last TTL also gets a
+// random id and no email, until the oldest request expires
+func (s *service) Request(ctx context.Context, login, method, device string) (string, error) {
+	if method != MethodLink && method != MethodCode {
+		return "", ErrInvalidMethod{method}
+	}
+
+	u, err := s.userSrv.GetByLogin(ctx, login)
+	if err != nil || u.Email == "" {
+		s.logger.Info(fmt.Sprintf("Magic login requested for unknown user %s", login))
+		return uuid.New().String(), nil
+	}
+
+	requests, err := s.repo.CountSince(replica.Primary(ctx), u.ID, time.Now().Add(-s.config.TTL))
+	if err != nil {
+		return "", err
+	}
+
+	if requests >= s.config.MaxRequests {
+		s.logger.Info(fmt.Sprintf("Magic login requests of %s User throttled", u.ID))
+		return uuid.New().String(), nil
+	}
+
+	var secret string
+	if method == MethodLink {
+		secret, err = randomToken()
+	} else {
+		secret, err = randomCode()
+	}
+	if err != nil {
+		s.logger.Error(err)
+		return "", err
+	}
+
+	if err := s.repo.Invalidate(ctx, u.ID); err != nil {
+		return "", err
+	}
+
+	challenge := Challenge{
+		UserID:     u.ID,
+		Method:     method,
+		SecretHash: hash(secret),
+		DeviceHash: hash(device),
+		ExpiresAt:  time.Now().Add(s.config.TTL),
+	}
+
+	if err := s.repo.Create(ctx, &challenge); err != nil {
+		s.logger.Error(err)
+		return "", err
+	}
+
+	// the email is sent in the background and its errors are only logged,
+	// the response of a user takes as long as the one of an unknown user
+	msg := s.message(u, &challenge, secret)
+	go func() {
+		if err := s.mailer.Send(context.WithoutCancel(ctx), msg); err != nil {
+			s.logger.Error(err)
+			return
+		}
+		s.logger.Info(fmt.Sprintf("Magic %s sent to %s User", method, u.ID))
+	}()
+
+	return challenge.ID, nil
+}
+
+// Verify checks the link token or the code from the same device that
+// requested it and issues the session token
+func (s *service) Verify(ctx context.Context, challengeID, secret, device string) (*domain.User, string, error) {
+	if _, err := uuid.Parse(challengeID); err != nil {
+		return nil, "", ErrInvalidChallenge
+	}
+
+	// the challenge is read from the primary and the attempt is counted
+	// before the secret is compared, a replica behind or concurrent
+	// guesses can't go over MaxAttempts
+	ctx = replica.Primary(ctx)
+	challenge, err := s.repo.Get(ctx, challengeID)
+	if err != nil {
+		return nil, "", ErrInvalidChallenge
+	}
+
+	if challenge.UsedAt != nil || time.Now().After(challenge.ExpiresAt) {
+		return nil, "", ErrInvalidChallenge
+	}
+
+	if err := s.repo.AddAttempt(ctx, challenge.ID, s.config.MaxAttempts); err != nil {
+		return nil, "", err
+	}
+
+	if !equal(challenge.SecretHash, hash(secret)) || !equal(challenge.DeviceHash, hash(device)) {
+		return nil, "", ErrInvalidChallenge
+	}
+
+	if err := s.repo.Use(ctx, challenge.ID); err != nil {
+		return nil, "", err
+	}
+
+	u, err := s.userSrv.Get(ctx, challenge.UserID, "")
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := s.userSrv.IssueToken(ctx, u)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.logger.Info(fmt.Sprintf("Magic login for %s User", u.ID))
+	return u, token, nil
+}
+
+func (s *service) message(u *domain.User, c *Challenge, secret string) mailer.Message {
+	minutes := int(s.config.TTL.Minutes())
+
+	if c.Method == MethodLink {
+		link := fmt.Sprintf("%s?token=%s", s.config.LinkURL, url.QueryEscape(c.ID+"."+secret))
+		return mailer.Message{
+			To:      u.Email,
+			Subject: "Your login link",
+			Body:    fmt.Sprintf("Hi %s,\n\nUse this link to log in, it expires in %d minutes:\n\n%s\n", u.FirstName, minutes, link),
+		}
+	}
+
+	return mailer.Message{
+		To:      u.Email,
+		Subject: "Your login code",
+		Body:    fmt.Sprintf("Hi %s,\n\nYour login code is %s, it expires in %d minutes.\n", u.FirstName, secret, minutes),
+	}
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func randomCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func hash(v string) string {
+	h := sha256.Sum256([]byte(v))
+	return hex.EncodeToString(h[:])
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
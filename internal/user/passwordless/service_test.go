@@ -0,0 +1,72 @@
+package passwordless_test
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/ncostamagna/axul-user/internal/user/passwordless"
+	"github.com/ncostamagna/axul-user/internal/user/usertest"
+	"github.com/ncostamagna/axul-user/pkg/dbtest"
+	"github.com/ncostamagna/axul-user/pkg/mailer"
+	"github.com/ncostamagna/axul-user/pkg/mailer/mailertest"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+)
+
+var code = regexp.MustCompile(`code is (\d{6})`)
+
+func newService(t *testing.T, m mailer.Mailer, config passwordless.Config) passwordless.Service {
+	t.Helper()
+
+	userSrv, _ := usertest.NewService(t)
+	logger := loghub.New()
+	return passwordless.NewService(passwordless.NewRepository(dbtest.Open(t), logger), userSrv, m, config, logger)
+}
+
+// concurrent wrong codes can't go over the attempts, the right code fails
+// after them
+func TestVerifyAttempts(t *testing.T) {
+	ctx := context.Background()
+	m := &mailertest.Inbox{}
+	srv := newService(t, m, passwordless.Config{MaxAttempts: 3})
+
+	id, err := srv.Request(ctx, "john", passwordless.MethodCode, "device")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := code.FindStringSubmatch(m.Wait(t, 1)[0].Body)[1]
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := srv.Verify(ctx, id, "000000x", "device"); err == nil {
+				t.Error("a wrong code was accepted")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, _, err := srv.Verify(ctx, id, secret, "device"); err != passwordless.ErrTooManyAttempts {
+		t.Errorf("verify after the attempts returned %v, want ErrTooManyAttempts", err)
+	}
+}
+
+func TestRequestThrottle(t *testing.T) {
+	ctx := context.Background()
+	m := &mailertest.Inbox{}
+	srv := newService(t, m, passwordless.Config{MaxRequests: 2})
+
+	for i := 0; i < 4; i++ {
+		if _, err := srv.Request(ctx, "john", passwordless.MethodCode, "device"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m.Wait(t, 2)
+	if n := m.Len(); n != 2 {
+		t.Errorf("%d codes were sent, want 2", n)
+	}
+}
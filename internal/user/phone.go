@@ -0,0 +1,37 @@
+package user
+
+import (
+	"github.com/nyaruka/phonenumbers"
+	"time"
+)
+
+// Phone is the E.164 number of the user with its country, VerifiedAt is
+// set when the user typed the code sent by SMS and cleared when it changes
+type Phone struct {
+	UserID      string     `json:"-" gorm:"type:char(36);not null;primary_key"`
+	Number      string     `json:"number" gorm:"type:char(20);not null"`
+	Country     string     `json:"country" gorm:"type:char(2)"`
+	CallingCode int32      `json:"calling_code"`
+	VerifiedAt  *time.Time `json:"verified_at"`
+	CreatedAt   time.Time  `json:"-"`
+	UpdatedAt   time.Time  `json:"-"`
+}
+
+func (Phone) TableName() string {
+	return "user_phones"
+}
+
+// ParsePhone validates a number in international format, "+54 9 11 1234-5678"
+// is stored as "+5491112345678" with country AR
+func ParsePhone(v string) (*Phone, error) {
+	n, err := phonenumbers.Parse(v, "")
+	if err != nil || !phonenumbers.IsValidNumber(n) {
+		return nil, ErrInvalidPhone{v}
+	}
+
+	return &Phone{
+		Number:      phonenumbers.Format(n, phonenumbers.E164),
+		Country:     phonenumbers.GetRegionCodeForNumber(n),
+		CallingCode: n.GetCountryCode(),
+	}, nil
+}
@@ -0,0 +1,89 @@
+package phoneverify
+
+import (
+	"context"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/apierror"
+	"github.com/ncostamagna/go-http-utils/response"
+	"time"
+)
+
+type (
+	UserReq struct {
+		ID            string `json:"id"`
+		Authorization string `json:"Authorization"`
+	}
+
+	VerifyReq struct {
+		ID            string `json:"id"`
+		Code          string `json:"code"`
+		Authorization string `json:"Authorization"`
+	}
+
+	SendRes struct {
+		Number    string    `json:"number"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+)
+
+type Controller func(ctx context.Context, request interface{}) (interface{}, error)
+
+// Endpoints struct
+type Endpoints struct {
+	Send   Controller
+	Verify Controller
+}
+
+func MakeEndpoints(s Service, userSrv user.Service) Endpoints {
+	return Endpoints{
+		Send:   makeSendEndpoint(s, userSrv),
+		Verify: makeVerifyEndpoint(s, userSrv),
+	}
+}
+
+func makeSendEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UserReq)
+
+		if _, err := userSrv.Authorize(ctx, req.Authorization, req.ID, user.ScopeUsersWrite); err != nil {
+			return nil, user.AuthorizationError(err)
+		}
+
+		verification, err := service.Send(ctx, req.ID)
+		if err != nil {
+			if err == ErrPhoneRequired || err == ErrAlreadyVerified {
+				return nil, apierror.BadRequest(err)
+			}
+			if err == ErrTooManyCodes {
+				return nil, apierror.TooManyRequests(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.Accepted("", SendRes{verification.Number, verification.ExpiresAt}, nil), nil
+	}
+}
+
+func makeVerifyEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(VerifyReq)
+
+		if _, err := userSrv.Authorize(ctx, req.Authorization, req.ID, user.ScopeUsersWrite); err != nil {
+			return nil, user.AuthorizationError(err)
+		}
+
+		if req.Code == "" {
+			return nil, apierror.BadRequest(ErrCodeRequired)
+		}
+
+		if err := service.Verify(ctx, req.ID, req.Code); err != nil {
+			switch err {
+			case ErrInvalidCode, ErrTooManyAttempts, user.ErrPhoneChanged:
+				return nil, apierror.BadRequest(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", nil, nil), nil
+	}
+}
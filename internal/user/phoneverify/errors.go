@@ -0,0 +1,10 @@
+package phoneverify
+
+import "errors"
+
+var ErrPhoneRequired = errors.New("the user doesn't have a phone")
+var ErrAlreadyVerified = errors.New("the phone is already verified")
+var ErrCodeRequired = errors.New("code is required")
+var ErrInvalidCode = errors.New("invalid or expired code")
+var ErrTooManyAttempts = errors.New("too many attempts, request a new code")
+var ErrTooManyCodes = errors.New("too many codes were sent, wait before requesting a new one")
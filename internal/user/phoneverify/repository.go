@@ -0,0 +1,103 @@
+package phoneverify
+
+import (
+	"context"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"gorm.io/gorm"
+	"time"
+)
+
+type Repository interface {
+	GetPending(ctx context.Context, userID string) (*Verification, error)
+	Create(ctx context.Context, verification *Verification) error
+	AddAttempt(ctx context.Context, id string, max int) error
+	CountSince(ctx context.Context, userID string, since time.Time) (int, error)
+	Use(ctx context.Context, id string) error
+}
+
+type repo struct {
+	db     *gorm.DB
+	logger loghub.Logger
+}
+
+func NewRepository(db *gorm.DB, logger loghub.Logger) Repository {
+	return &repo{db, logger}
+}
+
+// GetPending returns the last unused code of the user
+func (r *repo) GetPending(ctx context.Context, userID string) (*Verification, error) {
+	var verification Verification
+
+	if err := transaction.DB(ctx, r.db).Where("user_id = ? and used_at is null", userID).
+		Order("created_at desc").First(&verification).Error; err != nil {
+		return nil, err
+	}
+
+	return &verification, nil
+}
+
+// Create invalidates the previous codes of the user and stores the new one
+func (r *repo) Create(ctx context.Context, verification *Verification) error {
+	return transaction.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Verification{}).Where("user_id = ? and used_at is null", verification.UserID).
+			Update("used_at", time.Now()).Error; err != nil {
+			r.logger.Error(err)
+			return err
+		}
+
+		if err := tx.Create(verification).Error; err != nil {
+			r.logger.Error(err)
+			return err
+		}
+
+		return nil
+	})
+}
+
+// AddAttempt counts an attempt of the code, it fails with
+// ErrTooManyAttempts when it already has max attempts. The check and the
+// increment are a single update so concurrent attempts can't pass max
+func (r *repo) AddAttempt(ctx context.Context, id string, max int) error {
+	result := transaction.DB(ctx, r.db).Model(&Verification{}).Where("id = ? and attempts < ?", id, max).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrTooManyAttempts
+	}
+
+	return nil
+}
+
+// CountSince returns the codes sent to the user since a time
+func (r *repo) CountSince(ctx context.Context, userID string, since time.Time) (int, error) {
+	var count int64
+	err := transaction.DB(ctx, r.db).Model(&Verification{}).Where("user_id = ? and created_at > ?", userID, since).
+		Count(&count).Error
+	if err != nil {
+		r.logger.Error(err)
+		return 0, err
+	}
+
+	return int(count), nil
+}
+
+// Use marks the code as used, it fails when another request used it first
+func (r *repo) Use(ctx context.Context, id string) error {
+	result := transaction.DB(ctx, r.db).Model(&Verification{}).Where("id = ? and used_at is null", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrInvalidCode
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package phoneverify
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/replica"
+	"github.com/ncostamagna/axul-user/pkg/sms"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"math/big"
+	"time"
+)
+
+// Config.MaxSends is the number of codes a user can get in a TTL
+type Config struct {
+	TTL         time.Duration
+	MaxAttempts int
+	MaxSends    int
+}
+
+type Service interface {
+	Send(ctx context.Context, userID string) (*Verification, error)
+	Verify(ctx context.Context, userID, code string) error
+}
+
+type service struct {
+	repo    Repository
+	userSrv user.Service
+	sender  sms.Sender
+	config  Config
+	logger  loghub.Logger
+}
+
+// NewService is a service handler
+func NewService(repo Repository, userSrv user.Service, sender sms.Sender, config Config, logger loghub.Logger) Service {
+	if config.TTL <= 0 {
+		config.TTL = 10 * time.Minute
+	}
+
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+
+	if config.MaxSends <= 0 {
+		config.MaxSends = 3
+	}
+
+	return &service{
+		repo:    repo,
+		userSrv: userSrv,
+		sender:  sender,
+		config:  config,
+		logger:  logger,
+	}
+}
+
+// Send texts a verification code to the current phone of the user
+func (s *service) Send(ctx context.Context, userID string) (*Verification, error) {
+	phone, err := s.userSrv.GetPhone(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if phone == nil {
+		return nil, ErrPhoneRequired
+	}
+
+	if phone.VerifiedAt != nil {
+		return nil, ErrAlreadyVerified
+	}
+
+	sent, err := s.repo.CountSince(replica.Primary(ctx), userID, time.Now().Add(-s.config.TTL))
+	if err != nil {
+		return nil, err
+	}
+
+	if sent >= s.config.MaxSends {
+		return nil, ErrTooManyCodes
+	}
+
+	code, err := randomCode()
+	if err != nil {
+		s.logger.Error(err)
+		return nil, err
+	}
+
+	verification := Verification{
+		UserID:    userID,
+		Number:    phone.Number,
+		CodeHash:  hash(code),
+		ExpiresAt: time.Now().Add(s.config.TTL),
+	}
+
+	if err := s.repo.Create(ctx, &verification); err != nil {
+		return nil, err
+	}
+
+	body := fmt.Sprintf("Your verification code is %s, it expires in %d minutes.", code, int(s.config.TTL.Minutes()))
+	if err := s.sender.Send(ctx, phone.Number, body); err != nil {
+		s.logger.Error(err)
+		return nil, err
+	}
+
+	s.logger.Info(fmt.Sprintf("Phone verification sent to %s User", userID))
+	return &verification, nil
+}
+
+// Verify checks the last code sent to the user and marks the phone as
+// verified. The code is read from the primary and the attempt is counted
+// before the code is compared, like the magic login codes
+func (s *service) Verify(ctx context.Context, userID, code string) error {
+	ctx = replica.Primary(ctx)
+	verification, err := s.repo.GetPending(ctx, userID)
+	if err != nil {
+		return ErrInvalidCode
+	}
+
+	if time.Now().After(verification.ExpiresAt) {
+		return ErrInvalidCode
+	}
+
+	if err := s.repo.AddAttempt(ctx, verification.ID, s.config.MaxAttempts); err != nil {
+		return err
+	}
+
+	if !equal(verification.CodeHash, hash(code)) {
+		return ErrInvalidCode
+	}
+
+	if err := s.repo.Use(ctx, verification.ID); err != nil {
+		return err
+	}
+
+	return s.userSrv.VerifyPhone(ctx, userID, verification.Number)
+}
+
+func randomCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func hash(v string) string {
+	h := sha256.Sum256([]byte(v))
+	return hex.EncodeToString(h[:])
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
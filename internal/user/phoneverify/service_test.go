@@ -0,0 +1,71 @@
+package phoneverify_test
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/ncostamagna/axul-user/internal/user/phoneverify"
+	"github.com/ncostamagna/axul-user/internal/user/usertest"
+	"github.com/ncostamagna/axul-user/pkg/dbtest"
+	"github.com/ncostamagna/axul-user/pkg/sms/smstest"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+)
+
+var code = regexp.MustCompile(`code is (\d{6})`)
+
+func newService(t *testing.T, sender *smstest.Phone, config phoneverify.Config) (phoneverify.Service, string) {
+	t.Helper()
+
+	userSrv, u := usertest.NewService(t)
+	logger := loghub.New()
+	return phoneverify.NewService(phoneverify.NewRepository(dbtest.Open(t), logger), userSrv, sender, config, logger), u.ID
+}
+
+func TestVerifyAttempts(t *testing.T) {
+	ctx := context.Background()
+	sender := &smstest.Phone{}
+	srv, id := newService(t, sender, phoneverify.Config{MaxAttempts: 3})
+
+	if _, err := srv.Send(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	secret := code.FindStringSubmatch(sender.Messages()[0])[1]
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Verify(ctx, id, "000000x"); err == nil {
+				t.Error("a wrong code was accepted")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := srv.Verify(ctx, id, secret); err != phoneverify.ErrTooManyAttempts {
+		t.Errorf("verify after the attempts returned %v, want ErrTooManyAttempts", err)
+	}
+}
+
+func TestSendThrottle(t *testing.T) {
+	ctx := context.Background()
+	sender := &smstest.Phone{}
+	srv, id := newService(t, sender, phoneverify.Config{MaxSends: 2})
+
+	for i := 0; i < 2; i++ {
+		if _, err := srv.Send(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := srv.Send(ctx, id); err != phoneverify.ErrTooManyCodes {
+		t.Errorf("send after the limit returned %v, want ErrTooManyCodes", err)
+	}
+
+	if len(sender.Messages()) != 2 {
+		t.Errorf("%d codes were sent, want 2", len(sender.Messages()))
+	}
+}
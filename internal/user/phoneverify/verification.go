@@ -0,0 +1,33 @@
+package phoneverify
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Verification is a code sent by SMS to the phone of the user, only the
+// hash of the code is stored
+type Verification struct {
+	ID        string     `json:"id" gorm:"type:char(36);not null;primary_key;unique_index"`
+	UserID    string     `json:"user_id" gorm:"type:char(36);not null;index"`
+	Number    string     `json:"number" gorm:"type:char(20);not null"`
+	CodeHash  string     `json:"-" gorm:"type:char(64);not null"`
+	Attempts  int        `json:"attempts" gorm:"not null;default:0"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"-"`
+	UpdatedAt time.Time  `json:"-"`
+}
+
+func (Verification) TableName() string {
+	return "phone_verifications"
+}
+
+func (v *Verification) BeforeCreate(tx *gorm.DB) (err error) {
+
+	if v.ID == "" {
+		v.ID = uuid.New().String()
+	}
+	return
+}
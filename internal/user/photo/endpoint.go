@@ -0,0 +1,89 @@
+package photo
+
+import (
+	"context"
+	"errors"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/apierror"
+	"github.com/ncostamagna/go-http-utils/response"
+)
+
+type (
+	UploadReq struct {
+		ID            string `json:"id"`
+		Data          []byte `json:"-"`
+		Authorization string `json:"Authorization"`
+	}
+
+	UploadRes struct {
+		Photo string `json:"photo"`
+	}
+
+	GetReq struct {
+		ID      string `json:"id"`
+		Version string `json:"v"`
+		Size    string `json:"size"`
+	}
+)
+
+type Controller func(ctx context.Context, request interface{}) (interface{}, error)
+
+// Endpoints struct
+type Endpoints struct {
+	Upload Controller
+	Get    Controller
+}
+
+func MakeEndpoints(s Service, userSrv user.Service) Endpoints {
+	return Endpoints{
+		Upload: makeUploadEndpoint(s, userSrv),
+		Get:    makeGetEndpoint(s),
+	}
+}
+
+func makeUploadEndpoint(service Service, userSrv user.Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UploadReq)
+
+		if _, err := userSrv.Authorize(ctx, req.Authorization, req.ID, user.ScopeUsersWrite); err != nil {
+			return nil, user.AuthorizationError(err)
+		}
+
+		if len(req.Data) == 0 {
+			return nil, apierror.BadRequest(ErrPhotoRequired)
+		}
+
+		url, err := service.Upload(ctx, req.ID, req.Data)
+		if err != nil {
+			switch {
+			case err == ErrInvalidImage, errors.As(err, &ErrInvalidType{}), errors.As(err, &ErrTooLarge{}):
+				return nil, apierror.BadRequest(err)
+			case errors.As(err, &user.ErrNotFound{}):
+				return nil, apierror.NotFound(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return response.OK("", UploadRes{url}, nil), nil
+	}
+}
+
+// makeGetEndpoint returns the blob, the handler writes it as the body
+func makeGetEndpoint(service Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetReq)
+
+		b, err := service.Get(ctx, req.ID, req.Version, req.Size)
+		if err != nil {
+			if errors.As(err, &ErrInvalidSize{}) {
+				return nil, apierror.BadRequest(err)
+			}
+			if err == ErrPhotoNotFound {
+				return nil, apierror.NotFound(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return b, nil
+	}
+}
@@ -0,0 +1,34 @@
+package photo
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrPhotoRequired = errors.New("photo is required")
+var ErrInvalidImage = errors.New("the photo isn't a valid image")
+var ErrPhotoNotFound = errors.New("the user doesn't have a photo")
+
+type ErrInvalidType struct {
+	ContentType string
+}
+
+func (e ErrInvalidType) Error() string {
+	return fmt.Sprintf("photo type '%s' isn't supported, it must be jpeg, png or gif", e.ContentType)
+}
+
+type ErrTooLarge struct {
+	MaxSize int64
+}
+
+func (e ErrTooLarge) Error() string {
+	return fmt.Sprintf("photo is larger than %d bytes", e.MaxSize)
+}
+
+type ErrInvalidSize struct {
+	Size string
+}
+
+func (e ErrInvalidSize) Error() string {
+	return fmt.Sprintf("size '%s' isn't valid, it must be original, small, medium or large", e.Size)
+}
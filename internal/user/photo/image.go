@@ -0,0 +1,84 @@
+package photo
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	SizeOriginal = "original"
+	SizeSmall    = "small"
+	SizeMedium   = "medium"
+	SizeLarge    = "large"
+)
+
+// thumbnails are the square sizes generated on upload, in pixels
+var thumbnails = map[string]int{
+	SizeSmall:  64,
+	SizeMedium: 256,
+	SizeLarge:  512,
+}
+
+// maxPixels protects the decoder from huge images in small files, the
+// largest thumbnail is 512px and a 12 megapixel camera photo (4032x3024)
+// is the largest source accepted, about 50MB decoded
+const maxPixels = 4096 * 3072
+
+var contentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+func decode(data []byte) (image.Image, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width*cfg.Height > maxPixels {
+		return nil, "", ErrInvalidImage
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrInvalidImage
+	}
+
+	return img, format, nil
+}
+
+// thumbnail crops the center square of the image and scales it to size,
+// the png and gif photos are kept as png to preserve the transparency
+func thumbnail(img image.Image, format string, size int) ([]byte, error) {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+
+	x := b.Min.X + (b.Dx()-side)/2
+	y := b.Min.Y + (b.Dy()-side)/2
+	src := image.Rect(x, y, x+side, y+side)
+
+	if side < size {
+		size = side
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, src, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
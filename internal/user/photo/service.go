@@ -0,0 +1,165 @@
+package photo
+
+import (
+	"context"
+	"fmt"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/blob"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"image"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// MaxUploadSize is the hard limit of the request body, Config.MaxSize
+// can only be lower
+const MaxUploadSize = 10 << 20
+
+type Config struct {
+	BaseURL string
+	MaxSize int64
+}
+
+type Service interface {
+	Upload(ctx context.Context, userID string, data []byte) (string, error)
+	Get(ctx context.Context, userID, version, size string) (*blob.Blob, error)
+}
+
+type service struct {
+	userSrv user.Service
+	store   blob.Store
+	config  Config
+	logger  loghub.Logger
+}
+
+// NewService is a service handler
+func NewService(userSrv user.Service, store blob.Store, config Config, logger loghub.Logger) Service {
+	if config.MaxSize <= 0 || config.MaxSize > MaxUploadSize {
+		config.MaxSize = 5 << 20
+	}
+
+	return &service{
+		userSrv: userSrv,
+		store:   store,
+		config:  config,
+		logger:  logger,
+	}
+}
+
+// Upload validates the image, stores it with its thumbnails under a new
+// version and points the photo of the user to it once every file is
+// stored, the readers keep getting the previous version until then
+func (s *service) Upload(ctx context.Context, userID string, data []byte) (string, error) {
+	if int64(len(data)) > s.config.MaxSize {
+		return "", ErrTooLarge{s.config.MaxSize}
+	}
+
+	if ct := http.DetectContentType(data); !contentTypes[ct] {
+		return "", ErrInvalidType{ct}
+	}
+
+	img, format, err := decode(data)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := s.userSrv.Get(ctx, userID, "")
+	if err != nil {
+		return "", user.ErrNotFound{UserID: userID}
+	}
+
+	version := strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := s.put(ctx, userID, version, img, format, data); err != nil {
+		s.logger.Error(err)
+		s.delete(ctx, userID, version)
+		return "", err
+	}
+
+	// the version query busts the caches of the previous photo
+	photoURL := fmt.Sprintf("%s/users/%s/photo?v=%s", s.config.BaseURL, userID, version)
+	if err := s.userSrv.Update(ctx, userID, 0, nil, nil, nil, nil, &photoURL, nil, nil); err != nil {
+		s.delete(ctx, userID, version)
+		return "", err
+	}
+
+	if previous := photoVersion(u.Photo); previous != "" {
+		s.delete(ctx, userID, previous)
+	}
+
+	s.logger.Info(fmt.Sprintf("Upload photo of %s User", userID))
+	return photoURL, nil
+}
+
+// put stores the thumbnails and the original of a version
+func (s *service) put(ctx context.Context, userID, version string, img image.Image, format string, data []byte) error {
+	for size, px := range thumbnails {
+		b, err := thumbnail(img, format, px)
+		if err != nil {
+			return err
+		}
+
+		if err := s.store.Put(ctx, key(userID, version, size), b); err != nil {
+			return err
+		}
+	}
+
+	return s.store.Put(ctx, key(userID, version, SizeOriginal), data)
+}
+
+// delete removes the files of a version that isn't the photo of the user,
+// a failure only leaves unused files
+func (s *service) delete(ctx context.Context, userID, version string) {
+	if err := s.store.Delete(ctx, fmt.Sprintf("photos/%s/%s", userID, version)); err != nil {
+		s.logger.Error(err)
+	}
+}
+
+// Get returns a size of a version of the photo, the current photo of the
+// user when version is empty
+func (s *service) Get(ctx context.Context, userID, version, size string) (*blob.Blob, error) {
+	if size == "" {
+		size = SizeOriginal
+	}
+
+	if _, ok := thumbnails[size]; !ok && size != SizeOriginal {
+		return nil, ErrInvalidSize{size}
+	}
+
+	if version == "" {
+		u, err := s.userSrv.Get(ctx, userID, "")
+		if err != nil {
+			return nil, ErrPhotoNotFound
+		}
+
+		if version = photoVersion(u.Photo); version == "" {
+			return nil, ErrPhotoNotFound
+		}
+	}
+
+	b, err := s.store.Get(ctx, key(userID, version, size))
+	if err != nil {
+		if err == blob.ErrNotFound {
+			return nil, ErrPhotoNotFound
+		}
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// photoVersion is the version of a photo URL of Upload, empty for the
+// users without photo or with an external one
+func photoVersion(photo string) string {
+	u, err := url.Parse(photo)
+	if err != nil {
+		return ""
+	}
+
+	return u.Query().Get("v")
+}
+
+func key(userID, version, size string) string {
+	return fmt.Sprintf("photos/%s/%s/%s", userID, version, size)
+}
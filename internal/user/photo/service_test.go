@@ -0,0 +1,81 @@
+package photo_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/internal/user/photo"
+	"github.com/ncostamagna/axul-user/internal/user/usertest"
+	"github.com/ncostamagna/axul-user/pkg/blob"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+)
+
+func newService(t *testing.T) (photo.Service, user.Service, string) {
+	t.Helper()
+
+	store, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userSrv, u := usertest.NewService(t)
+	return photo.NewService(userSrv, store, photo.Config{BaseURL: "http://localhost"}, loghub.New()), userSrv, u.ID
+}
+
+func encode(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// a new photo is stored under a new version, the previous version is
+// served until the user points to the new one and is deleted after it
+func TestUploadVersions(t *testing.T) {
+	ctx := context.Background()
+	srv, userSrv, id := newService(t)
+
+	first, err := srv.Upload(ctx, id, encode(t, 100, 80))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := srv.Upload(ctx, id, encode(t, 120, 90))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u, _ := userSrv.Get(ctx, id, ""); u == nil || u.Photo != second {
+		t.Errorf("the photo of the user is %+v, want %s", u, second)
+	}
+
+	if _, err := srv.Get(ctx, id, "", photo.SizeSmall); err != nil {
+		t.Errorf("get the current photo: %v", err)
+	}
+
+	_, version, _ := strings.Cut(second, "?v=")
+	if _, err := srv.Get(ctx, id, version, photo.SizeOriginal); err != nil {
+		t.Errorf("get the version %s: %v", version, err)
+	}
+
+	_, version, _ = strings.Cut(first, "?v=")
+	if _, err := srv.Get(ctx, id, version, photo.SizeOriginal); err != photo.ErrPhotoNotFound {
+		t.Errorf("get the previous version returned %v, want ErrPhotoNotFound", err)
+	}
+}
+
+func TestUploadTooManyPixels(t *testing.T) {
+	srv, _, id := newService(t)
+
+	if _, err := srv.Upload(context.Background(), id, encode(t, 5000, 4000)); err != photo.ErrInvalidImage {
+		t.Errorf("upload of 20 megapixels returned %v, want ErrInvalidImage", err)
+	}
+}
@@ -2,19 +2,35 @@ package user
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
+	"github.com/ncostamagna/axul-user/pkg/cursor"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
 	domain "github.com/ncostamagna/axul_domain/domain/user"
 	"github.com/ncostamagna/go-logger-hub/loghub"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"strings"
+	"time"
 )
 
 type Repository interface {
 	GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.User, error)
+	GetPage(ctx context.Context, filters Filters, page cursor.Page) ([]domain.User, error)
 	Get(ctx context.Context, id string) (*domain.User, error)
 	//GetByUserName(ctx context.Context, username string) (*domain.User, error)
-	Create(ctx context.Context, user *domain.User) error
-	Update(ctx context.Context, id string, firstname, lastname, email, phone, photo, language, password *string) error
+	GetByLogin(ctx context.Context, login string) (*domain.User, error)
+	GetPhone(ctx context.Context, userID string) (*Phone, error)
+	GetLocale(ctx context.Context, userID string) (*Locale, error)
+	VerifyPhone(ctx context.Context, userID, number string) error
+	Create(ctx context.Context, user *domain.User, locale *Locale) error
+	CreateServiceAccount(ctx context.Context, user *domain.User, account *ServiceAccount) error
+	GetServiceAccount(ctx context.Context, clientID string) (*domain.User, error)
+	Update(ctx context.Context, id string, version int, firstname, lastname, email, phone, photo, language, timezone, password *string) error
+	GetVersion(ctx context.Context, id string) (int, error)
 	Delete(ctx context.Context, id string) error
 	Count(ctx context.Context, filters Filters) (int, error)
 }
@@ -31,9 +47,12 @@ func NewRepository(db *gorm.DB, logger loghub.Logger) Repository {
 func (r *repo) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.User, error) {
 	var user []domain.User
 
-	tx := r.db.WithContext(ctx).Model(&user)
+	tx := transaction.DB(ctx, r.db).Model(&user)
 	applyFilters(tx, filters)
-	result := tx.Order("created_at desc").Find(&user)
+	if limit > 0 {
+		tx = tx.Offset(offset).Limit(limit)
+	}
+	result := tx.Order("created_at desc, id desc").Find(&user)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -42,9 +61,27 @@ func (r *repo) GetAll(ctx context.Context, filters Filters, offset, limit int) (
 	return user, nil
 }
 
+// GetPage returns the users of the page and one more when there is a next
+// page, cursor.New trims it
+func (r *repo) GetPage(ctx context.Context, filters Filters, page cursor.Page) ([]domain.User, error) {
+	var users []domain.User
+
+	tx := applyFilters(transaction.DB(ctx, r.db).Model(&users), filters)
+	if err := page.Scope(tx).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// Position is the cursor of a user in the lists
+func Position(u domain.User) cursor.Cursor {
+	return cursor.Cursor{CreatedAt: u.CreatedAt, ID: u.ID}
+}
+
 func (r *repo) Get(ctx context.Context, id string) (*domain.User, error) {
 	var user domain.User
-	tx := r.db.WithContext(ctx).Model(&user)
+	tx := transaction.DB(ctx, r.db).Model(&user)
 
 	result := tx.Where("id = ?", id).First(&user)
 
@@ -69,14 +106,138 @@ func (r *repo) GetByUserName(ctx context.Context, username string) (*domain.User
 	return &user, nil
 }*/
 
-func (r *repo) Create(ctx context.Context, user *domain.User) error {
+// GetByLogin looks up a human user by username first and then by email,
+// login must be normalized
+func (r *repo) GetByLogin(ctx context.Context, login string) (*domain.User, error) {
+	for _, column := range []string{"user_name", "email"} {
+		var user domain.User
+		tx := transaction.DB(ctx, r.db).Model(&user)
+
+		result := tx.Where(fmt.Sprintf("%s_norm = ? and id not in (?)", column), login, serviceAccounts(tx)).Limit(1).Find(&user)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+
+		if result.RowsAffected == 1 {
+			return &user, nil
+		}
+	}
+
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *repo) Create(ctx context.Context, user *domain.User, locale *Locale) error {
 	user.ID = uuid.New().String()
-	return r.db.Create(&user).Error
+
+	return transaction.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return duplicated(err)
+		}
+
+		if err := setLogins(tx, user); err != nil {
+			return duplicated(err)
+		}
+
+		if err := setPhone(tx, user.ID, user.Phone); err != nil {
+			return err
+		}
+
+		if locale == nil {
+			return nil
+		}
+		locale.UserID = user.ID
+		return tx.Create(locale).Error
+	})
 }
 
-func (r *repo) Update(ctx context.Context, id string, firstname, lastname, email, phone, photo, language, password *string) error {
+func (r *repo) GetLocale(ctx context.Context, userID string) (*Locale, error) {
+	var locales []Locale
 
-	values := make(map[string]interface{})
+	if err := transaction.DB(ctx, r.db).Where("user_id = ?", userID).Limit(1).Find(&locales).Error; err != nil {
+		r.logger.Error(err)
+		return nil, err
+	}
+
+	if len(locales) == 0 {
+		return nil, nil
+	}
+
+	return &locales[0], nil
+}
+
+func (r *repo) GetPhone(ctx context.Context, userID string) (*Phone, error) {
+	var phones []Phone
+
+	if err := transaction.DB(ctx, r.db).Where("user_id = ?", userID).Limit(1).Find(&phones).Error; err != nil {
+		r.logger.Error(err)
+		return nil, err
+	}
+
+	if len(phones) == 0 {
+		return nil, nil
+	}
+
+	return &phones[0], nil
+}
+
+func (r *repo) VerifyPhone(ctx context.Context, userID, number string) error {
+	result := transaction.DB(ctx, r.db).Model(&Phone{}).Where("user_id = ? and number = ?", userID, number).
+		Update("verified_at", time.Now())
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrPhoneChanged
+	}
+
+	return nil
+}
+
+// CreateServiceAccount stores the user and its service account mark in the same transaction
+func (r *repo) CreateServiceAccount(ctx context.Context, user *domain.User, account *ServiceAccount) error {
+	user.ID = uuid.New().String()
+	account.UserID = user.ID
+
+	return transaction.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			r.logger.Error(err)
+			return duplicated(err)
+		}
+
+		if err := setLogins(tx, user); err != nil {
+			r.logger.Error(err)
+			return duplicated(err)
+		}
+
+		if err := tx.Create(account).Error; err != nil {
+			r.logger.Error(err)
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (r *repo) GetServiceAccount(ctx context.Context, clientID string) (*domain.User, error) {
+	var user domain.User
+	tx := transaction.DB(ctx, r.db).Model(&user)
+
+	result := tx.Where("client_id = ? and id in (?)", clientID, serviceAccounts(tx)).First(&user)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &user, nil
+}
+
+// Update changes the fields that aren't nil and increases the version, it
+// returns ErrVersionConflict when version isn't 0 and the user has
+// another version
+func (r *repo) Update(ctx context.Context, id string, version int, firstname, lastname, email, phone, photo, language, timezone, password *string) error {
+
+	values := map[string]interface{}{"version": gorm.Expr("version + 1")}
 
 	if firstname != nil {
 		values["first_name"] = *firstname
@@ -88,6 +249,7 @@ func (r *repo) Update(ctx context.Context, id string, firstname, lastname, email
 
 	if email != nil {
 		values["email"] = *email
+		values["email_norm"] = normalized(*email)
 	}
 
 	if phone != nil {
@@ -99,14 +261,58 @@ func (r *repo) Update(ctx context.Context, id string, firstname, lastname, email
 	}
 
 	if language != nil {
-		values["language"] = *language
+		values["language"] = Base(*language)
 	}
 
 	if password != nil {
 		values["password"] = *password
 	}
 
-	result := r.db.WithContext(ctx).Model(&domain.User{}).Where("id = ?", id).Updates(values)
+	return transaction.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		update := tx.Model(&domain.User{}).Where("id = ?", id)
+		if version > 0 {
+			update = update.Where("version = ?", version)
+		}
+
+		result := update.Updates(values)
+		if result.Error != nil {
+			r.logger.Error(result.Error)
+			return duplicated(result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			if _, err := r.version(tx, id); err == nil {
+				return ErrVersionConflict{id}
+			}
+			return ErrNotFound{id}
+		}
+
+		if phone != nil {
+			if err := setPhone(tx, id, *phone); err != nil {
+				return err
+			}
+		}
+
+		return setLocale(tx, id, language, timezone)
+	})
+}
+
+func (r *repo) GetVersion(ctx context.Context, id string) (int, error) {
+	return r.version(transaction.DB(ctx, r.db), id)
+}
+
+func (r *repo) version(tx *gorm.DB, id string) (int, error) {
+	var version int
+	result := tx.Model(&domain.User{}).Select("version").Where("id = ?", id).Take(&version)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return version, nil
+}
+
+func (r *repo) Delete(ctx context.Context, id string) error {
+	result := transaction.DB(ctx, r.db).Where("id = ?", id).Delete(&domain.User{})
 	if result.Error != nil {
 		r.logger.Error(result.Error)
 		return result.Error
@@ -119,13 +325,9 @@ func (r *repo) Update(ctx context.Context, id string, firstname, lastname, email
 	return nil
 }
 
-func (r *repo) Delete(ctx context.Context, id string) error {
-	return nil
-}
-
 func (r *repo) Count(ctx context.Context, filters Filters) (int, error) {
 	var count int64
-	tx := r.db.WithContext(ctx).Model(domain.User{})
+	tx := transaction.DB(ctx, r.db).Model(domain.User{})
 	tx = applyFilters(tx, filters)
 	if err := tx.Count(&count).Error; err != nil {
 		r.logger.Error(err)
@@ -137,9 +339,198 @@ func (r *repo) Count(ctx context.Context, filters Filters) (int, error) {
 
 func applyFilters(tx *gorm.DB, f Filters) *gorm.DB {
 
+	if f.ID != nil {
+		tx = tx.Where("id in (?)", f.ID)
+	}
+
 	if f.UserName != "" {
-		tx = tx.Where("lower(user_name) = ?", strings.ToLower(f.UserName))
+		tx = tx.Where("user_name_norm = ?", Normalize(f.UserName))
+	}
+
+	if f.Email != "" {
+		tx = tx.Where("email_norm = ?", Normalize(f.Email))
+	}
+
+	for _, a := range f.Attributes {
+		attributes := tx.Session(&gorm.Session{NewDB: true}).Table("user_attributes").Select("user_id").
+			Where("app = ? and ? = ?", a.App, jsonValue(tx, "data", a.Key), a.Value)
+		tx = tx.Where("id in (?)", attributes)
+	}
+
+	switch f.Kind {
+	case KindHuman:
+		tx = tx.Where("id not in (?)", serviceAccounts(tx))
+	case KindService:
+		tx = tx.Where("id in (?)", serviceAccounts(tx))
+	}
+
+	if f.CreatedBy != "" {
+		tx = tx.Where("id in (?)", serviceAccounts(tx).Where("created_by = ?", f.CreatedBy))
 	}
 
 	return tx
 }
+
+// jsonValue is the value of a key of a JSON column as text, the JSON
+// functions are different in each database. The booleans are "true" and
+// "false" and a null is NULL in every database, the numbers are compared
+// as text in the form of the database (1.50 is "1.50" in postgres and
+// "1.5" in mysql and sqlite) so only the integers match in all of them
+func jsonValue(tx *gorm.DB, column, key string) clause.Expr {
+	switch tx.Dialector.Name() {
+	case "postgres":
+		return gorm.Expr(fmt.Sprintf("cast(%s as jsonb) ->> ?", column), key)
+	case "sqlite":
+		// json_extract returns 1 and 0 for the booleans
+		return gorm.Expr(fmt.Sprintf("case json_type(%[1]s, ?) when 'true' then 'true' when 'false' then 'false' else cast(json_extract(%[1]s, ?) as text) end", column), "$."+key, "$."+key)
+	}
+
+	// json_unquote returns "null" for a null
+	return gorm.Expr(fmt.Sprintf("case json_type(json_extract(%[1]s, ?)) when 'NULL' then null else json_unquote(json_extract(%[1]s, ?)) end", column), "$."+key, "$."+key)
+}
+
+func serviceAccounts(tx *gorm.DB) *gorm.DB {
+	return tx.Session(&gorm.Session{NewDB: true}).Model(&ServiceAccount{}).Select("user_id")
+}
+
+// setPhone keeps the phone metadata of the user, the verification is lost
+// only when the number changes
+func setPhone(tx *gorm.DB, userID, number string) error {
+	if err := tx.Where("user_id = ? and number <> ?", userID, number).Delete(&Phone{}).Error; err != nil {
+		return err
+	}
+
+	if number == "" {
+		return nil
+	}
+
+	phone, err := ParsePhone(number)
+	if err != nil {
+		return err
+	}
+	phone.UserID = userID
+
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(phone).Error
+}
+
+// setLocale updates the given fields of the locale of the user, creating it
+// for the users that don't have one
+func setLocale(tx *gorm.DB, userID string, language, timezone *string) error {
+	locale := Locale{UserID: userID}
+	var columns []string
+
+	if language != nil {
+		locale.Language = *language
+		columns = append(columns, "language")
+	}
+
+	if timezone != nil {
+		locale.Timezone = *timezone
+		columns = append(columns, "timezone")
+	}
+
+	if len(columns) == 0 {
+		return nil
+	}
+
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns(append(columns, "updated_at")),
+	}).Create(&locale).Error
+}
+
+// Normalize is the form of the usernames and emails used by the lookups,
+// NFKC and case folding so equivalent spellings match the same user
+func Normalize(v string) string {
+	return cases.Fold().String(norm.NFKC.String(strings.TrimSpace(v)))
+}
+
+// setLogins stores the normalized username and email of a new user, the
+// logins are looked up and kept unique on them
+func setLogins(tx *gorm.DB, user *domain.User) error {
+	return tx.Model(&domain.User{}).Where("id = ?", user.ID).UpdateColumns(map[string]interface{}{
+		"user_name_norm": normalized(user.UserName),
+		"email_norm":     normalized(user.Email),
+	}).Error
+}
+
+// normalized is the value of a normalized column, NULL for an empty login
+// so the users without email don't collide
+func normalized(v string) interface{} {
+	if v = Normalize(v); v == "" {
+		return nil
+	}
+	return v
+}
+
+// NormalizeLogins fills the normalized username and email of the users
+// created before the columns, from the oldest to the newest. A user whose
+// username is taken by an older user is renamed to the username with the
+// start of its id and a user whose email is taken loses it, like the
+// migration of the unique indexes. It returns the number of users filled
+func NormalizeLogins(ctx context.Context, db *gorm.DB, logger loghub.Logger) (int, error) {
+	var ids []string
+	if err := db.WithContext(ctx).Unscoped().Model(&domain.User{}).
+		Where("user_name_norm is null and user_name <> ''").Order("created_at, id").Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	for i, id := range ids {
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var u domain.User
+			if err := tx.Unscoped().Select("id", "user_name", "email").Where("id = ?", id).Take(&u).Error; err != nil {
+				return err
+			}
+
+			values := map[string]interface{}{"user_name_norm": Normalize(u.UserName), "email_norm": normalized(u.Email)}
+			count, err := others(tx, "user_name_norm", values["user_name_norm"], id)
+			if err != nil {
+				return err
+			}
+
+			if count > 0 {
+				name := []rune(u.UserName)
+				if len(name) > 61 {
+					name = name[:61]
+				}
+				renamed := string(name) + "-" + id[:8]
+				values["user_name"], values["user_name_norm"] = renamed, Normalize(renamed)
+				logger.Info(fmt.Sprintf("User %s renamed from %s to %s, the username is taken", id, u.UserName, renamed))
+			}
+
+			if values["email_norm"] != nil {
+				if count, err = others(tx, "email_norm", values["email_norm"], id); err != nil {
+					return err
+				}
+
+				if count > 0 {
+					values["email"], values["email_norm"] = "", nil
+					logger.Info(fmt.Sprintf("User %s lost the email %s, it's taken", id, u.Email))
+				}
+			}
+
+			return tx.Unscoped().Model(&domain.User{}).Where("id = ?", id).UpdateColumns(values).Error
+		})
+		if err != nil {
+			logger.Error(err)
+			return i, err
+		}
+	}
+
+	return len(ids), nil
+}
+
+// others counts the other users with the value in a normalized column
+func others(tx *gorm.DB, column string, value interface{}, id string) (int64, error) {
+	var count int64
+	err := tx.Unscoped().Model(&domain.User{}).Where(fmt.Sprintf("%s = ? and id <> ?", column), value, id).Count(&count).Error
+	return count, err
+}
+
+// duplicated translates the unique index violations of the users table
+func duplicated(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrUserAlreadyExists{}
+	}
+	return err
+}
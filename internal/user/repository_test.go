@@ -0,0 +1,179 @@
+package user_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/internal/user/usertest"
+	"github.com/ncostamagna/axul-user/pkg/cache"
+	"github.com/ncostamagna/axul-user/pkg/dbtest"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	domain "github.com/ncostamagna/axul_domain/domain/user"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+)
+
+func TestRepository(t *testing.T) {
+	usertest.RepositoryContract(t, func(t *testing.T) user.Repository {
+		return user.NewRepository(dbtest.Open(t), loghub.New())
+	})
+}
+
+func TestFakeRepository(t *testing.T) {
+	usertest.RepositoryContract(t, func(t *testing.T) user.Repository {
+		return usertest.NewRepository()
+	})
+}
+
+func newStore() *cache.Store {
+	return cache.NewStore("users", cache.NewLRU(100), time.Minute, loghub.New())
+}
+
+func TestCachedRepository(t *testing.T) {
+	usertest.RepositoryContract(t, func(t *testing.T) user.Repository {
+		return user.NewCachedRepository(user.NewRepository(dbtest.Open(t), loghub.New()), newStore())
+	})
+}
+
+func TestCachedFakeRepository(t *testing.T) {
+	usertest.RepositoryContract(t, func(t *testing.T) user.Repository {
+		return user.NewCachedRepository(usertest.NewRepository(), newStore())
+	})
+}
+
+func TestCachedRepositoryTransaction(t *testing.T) {
+	ctx := context.Background()
+	db, store := dbtest.Open(t), newStore()
+	repo := user.NewCachedRepository(user.NewRepository(db, loghub.New()), store)
+
+	john := &domain.User{UserName: "john", FirstName: "John", Email: "john@example.com", Language: "en"}
+	if err := repo.Create(ctx, john, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := repo.Get(ctx, john.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if st := store.Stats(); st.Hits != 1 || st.Misses != 1 {
+		t.Fatalf("the stats of two gets are %+v, want a miss and a hit", st)
+	}
+
+	old, name := *john, "Johnny"
+	err := transaction.NewManager(db).Do(ctx, func(ctx context.Context) error {
+		if err := repo.Update(ctx, john.ID, 0, &name, nil, nil, nil, nil, nil, nil, nil); err != nil {
+			return err
+		}
+
+		// a read out of the transaction can't see the update, it fills
+		// the cache with the old user until the commit
+		if _, err := cache.Load(context.Background(), store, john.ID, func(context.Context) (*domain.User, error) {
+			return &old, nil
+		}); err != nil {
+			t.Error(err)
+		}
+
+		if u, err := repo.Get(ctx, john.ID); err != nil || u.FirstName != name {
+			t.Errorf("get in the transaction returned %+v, %v", u, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u, err := repo.Get(ctx, john.ID); err != nil || u.FirstName != name {
+		t.Errorf("get after the commit returned %+v, %v", u, err)
+	}
+}
+
+// the users created before the normalized columns get them, the newer user
+// of a taken username or email is renamed or loses the email
+func TestNormalizeLogins(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.Open(t)
+
+	now := time.Now()
+	users := []struct {
+		id, userName, email string
+		createdAt           time.Time
+	}{
+		{"bbbbbbbb-0000-0000-0000-000000000000", "STRASSE", "JOSE\u0301@example.com", now},
+		{"aaaaaaaa-0000-0000-0000-000000000000", "Straße", "josé@example.com", now.Add(-time.Hour)},
+		{"cccccccc-0000-0000-0000-000000000000", "jane", "", now},
+	}
+	for _, u := range users {
+		if err := db.Exec("INSERT INTO users (id, user_name, email, language, created_at) VALUES (?, ?, ?, 'en', ?)", u.id, u.userName, u.email, u.createdAt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := user.NormalizeLogins(ctx, db, loghub.New())
+	if err != nil || n != 3 {
+		t.Fatalf("normalize returned %d, %v, want 3", n, err)
+	}
+
+	repo := user.NewRepository(db, loghub.New())
+	for login, want := range map[string]string{
+		"strasse":          "aaaaaaaa-0000-0000-0000-000000000000",
+		"josé@example.com": "aaaaaaaa-0000-0000-0000-000000000000",
+		"strasse-bbbbbbbb": "bbbbbbbb-0000-0000-0000-000000000000",
+		"JANE":             "cccccccc-0000-0000-0000-000000000000",
+	} {
+		got, err := repo.GetByLogin(ctx, user.Normalize(login))
+		if err != nil || got.ID != want {
+			t.Errorf("get by login %s returned %v, %v, want %s", login, got, err, want)
+		}
+	}
+
+	if got, _ := repo.Get(ctx, "bbbbbbbb-0000-0000-0000-000000000000"); got == nil || got.Email != "" {
+		t.Errorf("the newer user with a taken email is %+v", got)
+	}
+
+	if n, err := user.NormalizeLogins(ctx, db, loghub.New()); err != nil || n != 0 {
+		t.Errorf("normalize again returned %d, %v, want 0", n, err)
+	}
+}
+
+// the attribute filters compare the JSON values as text in the same way on
+// every driver, TEST_DATABASE_DRIVER runs them on mysql and postgres
+func TestRepositoryAttributeFilters(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.Open(t)
+	repo := user.NewRepository(db, loghub.New())
+
+	john := &domain.User{UserName: "john", Email: "john@example.com", Language: "en"}
+	if err := repo.Create(ctx, john, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	data := `{"active": true, "beta": false, "age": 30, "plan": "pro", "note": null}`
+	if err := db.Exec("INSERT INTO user_attributes (user_id, app, data) VALUES (?, 'crm', ?)", john.ID, data).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		key, value string
+		want       int
+	}{
+		{"active", "true", 1},
+		{"active", "1", 0},
+		{"beta", "false", 1},
+		{"age", "30", 1},
+		{"plan", "pro", 1},
+		{"plan", `"pro"`, 0},
+		{"note", "null", 0},
+		{"missing", "", 0},
+	}
+
+	for _, c := range cases {
+		filters := user.Filters{Attributes: []user.AttributeFilter{{App: "crm", Key: c.key, Value: c.value}}}
+		count, err := repo.Count(ctx, filters)
+		if err != nil || count != c.want {
+			t.Errorf("%s = %s: count returned %d, %v, want %d", c.key, c.value, count, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package role
+
+import (
+	"context"
+	"github.com/ncostamagna/axul-user/pkg/cache"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	domain "github.com/ncostamagna/axul_domain/domain/user"
+)
+
+// cachedRepo caches the roles of a user in an app and their version, the
+// lookups of GET /users/:id/apps/:app. The writes delete the keys of the
+// apps they change, again after the commit when they are in a transaction,
+// and the calls in a transaction don't use the cache
+type cachedRepo struct {
+	Repository
+	store *cache.Store
+}
+
+// NewCachedRepository decorates repo with the cache of store
+func NewCachedRepository(repo Repository, store *cache.Store) Repository {
+	return &cachedRepo{repo, store}
+}
+
+// GetAll is cached when it's the full list of a user in an app, the other
+// filters go to the repository
+func (r *cachedRepo) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Role, error) {
+	if transaction.Active(ctx) || len(filters.UserID) != 1 || len(filters.App) != 1 || offset != 0 || limit != 0 {
+		return r.Repository.GetAll(ctx, filters, offset, limit)
+	}
+
+	return cache.Load(ctx, r.store, key(filters.UserID[0], filters.App[0]), func(ctx context.Context) ([]domain.Role, error) {
+		return r.Repository.GetAll(ctx, filters, offset, limit)
+	})
+}
+
+func (r *cachedRepo) GetVersion(ctx context.Context, userID, app string) (int, error) {
+	if transaction.Active(ctx) {
+		return r.Repository.GetVersion(ctx, userID, app)
+	}
+
+	return cache.Load(ctx, r.store, "version:"+key(userID, app), func(ctx context.Context) (int, error) {
+		return r.Repository.GetVersion(ctx, userID, app)
+	})
+}
+
+func (r *cachedRepo) Create(ctx context.Context, role *domain.Role) error {
+	defer r.invalidate(ctx, role.UserID, role.App)
+	return r.Repository.Create(ctx, role)
+}
+
+func (r *cachedRepo) Update(ctx context.Context, userID, app string, version int, role *uint64) error {
+	defer r.invalidate(ctx, userID, app)
+	return r.Repository.Update(ctx, userID, app, version, role)
+}
+
+// DeleteByUser reads the apps of the user first to delete their keys
+func (r *cachedRepo) DeleteByUser(ctx context.Context, userID string) error {
+	roles, err := r.Repository.GetAll(ctx, Filters{UserID: []string{userID}}, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	apps := make([]string, 0, len(roles))
+	for _, rl := range roles {
+		apps = append(apps, rl.App)
+	}
+
+	defer r.invalidate(ctx, userID, apps...)
+	return r.Repository.DeleteByUser(ctx, userID)
+}
+
+// invalidate deletes the keys now and after the commit, a request that
+// reads the roles before the commit can't keep the old values
+func (r *cachedRepo) invalidate(ctx context.Context, userID string, apps ...string) {
+	keys := make([]string, 0, 2*len(apps))
+	for _, app := range apps {
+		keys = append(keys, key(userID, app), "version:"+key(userID, app))
+	}
+
+	r.store.Delete(ctx, keys...)
+	if transaction.Active(ctx) {
+		transaction.AfterCommit(ctx, func() { r.store.Delete(ctx, keys...) })
+	}
+}
+
+func key(userID, app string) string {
+	return userID + ":" + app
+}
@@ -2,28 +2,55 @@ package role
 
 import (
 	"context"
+	"github.com/ncostamagna/axul-user/pkg/apierror"
 	//domain "github.com/ncostamagna/axul_domain/domain/user"
 	"errors"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/cursor"
+	"github.com/ncostamagna/axul-user/pkg/validation"
+	domain "github.com/ncostamagna/axul_domain/domain/user"
+	"github.com/ncostamagna/go-http-utils/meta"
 	"github.com/ncostamagna/go-http-utils/response"
 	// auth "github.com/ncostamagna/axul_auth/auth"
 )
 
 type (
 	AppReq struct {
-		ID  string `json:"id"`
-		App string `json:"app"`
+		ID            string `json:"id" validate:"required"`
+		App           string `json:"app" validate:"required,max=36"`
+		Authorization string `json:"-"`
 	}
 
+	// AddRoles.Version is the version of the If-Match header, 0 updates
+	// any version
 	AddRoles struct {
-		ID    string   `json:"id"`
-		App   string   `json:"app"`
-		Roles []string `json:"roles"`
+		ID            string   `json:"id" validate:"required"`
+		App           string   `json:"app" validate:"required,max=36"`
+		Version       int      `json:"-"`
+		Roles         []string `json:"roles" validate:"dive,oneof=read write update delete admin_r admin_rw owner"`
+		Authorization string   `json:"-"`
+	}
+
+	// RoleRes is the role of a user in an app, Version is sent in the ETag
+	// header
+	RoleRes struct {
+		*domain.Role
+		Version int `json:"-"`
+	}
+
+	// GetAllReq lists the roles of a user in every app, by Cursor or by
+	// Page like the users
+	GetAllReq struct {
+		ID     string         `json:"id" validate:"required"`
+		Limit  int            `json:"limit"`
+		Page   int            `json:"page"`
+		Cursor *cursor.Cursor `json:"-"`
 	}
 
 	CreateRole struct {
-		ID    string   `json:"id"`
-		Apps  []string `json:"apps"`
-		Roles []string `json:"roles"`
+		ID    string   `json:"id" validate:"required"`
+		Apps  []string `json:"apps" validate:"required,dive,required,max=36"`
+		Roles []string `json:"roles" validate:"dive,oneof=read write update delete admin_r admin_rw owner"`
 	}
 )
 
@@ -34,45 +61,69 @@ type Endpoints struct {
 	Create   Controller
 	AddRoles Controller
 	GetRole  Controller
+	GetAll   Controller
+}
+
+// Config of the endpoints, LimPageDef is the page size of the lists
+// without limit and the admins of AdminApp grant the roles
+type Config struct {
+	LimPageDef string
+	AdminApp   string
 }
 
-func MakeEndpoints(s Service) Endpoints {
+func MakeEndpoints(s Service, config Config) Endpoints {
 	return Endpoints{
-		Create:   makeCreateEndpoint(s),
-		AddRoles: makeAddRolesEndpoint(s),
+		Create:   makeCreateEndpoint(s, config),
+		AddRoles: makeAddRolesEndpoint(s, config),
 		GetRole:  makeGetRolesEndpoint(s),
+		GetAll:   makeGetAllEndpoint(s, config),
 	}
 }
 
-func makeCreateEndpoint(service Service) Controller {
+func makeCreateEndpoint(service Service, config Config) Controller {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(AppReq)
 
-		if req.App == "" || req.ID == "" {
-			return nil, response.BadRequest(ErrUserIDAndAppAreRequired.Error())
+		if _, err := service.Authorize(ctx, req.Authorization, config.AdminApp); err != nil {
+			return nil, authorizationError(err)
+		}
+
+		if err := validation.Struct(req); err != nil {
+			return nil, err
 		}
 
 		role, err := service.Create(ctx, req.ID, req.App)
 		if err != nil {
-			return nil, response.InternalServerError(err.Error())
+			return nil, apierror.InternalServerError(err)
 		}
 
 		return response.Created("", role, nil), nil
 	}
 }
 
-func makeAddRolesEndpoint(service Service) Controller {
+func makeAddRolesEndpoint(service Service, config Config) Controller {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(AddRoles)
-		if req.App == "" || req.ID == "" {
-			return nil, response.BadRequest(ErrUserIDAndAppAreRequired.Error())
+
+		if _, err := service.Authorize(ctx, req.Authorization, config.AdminApp); err != nil {
+			return nil, authorizationError(err)
 		}
 
-		if err := service.AddRole(ctx, req.ID, req.App, req.Roles); err != nil {
+		if err := validation.Struct(req); err != nil {
+			return nil, err
+		}
+
+		if err := service.AddRole(ctx, req.ID, req.App, req.Version, req.Roles); err != nil {
 			if errors.As(err, &InvalidRole{}) {
-				return nil, response.BadRequest(err.Error())
+				return nil, apierror.BadRequest(err)
+			}
+			if errors.As(err, &ErrUserAppNotFound{}) {
+				return nil, apierror.NotFound(err)
 			}
-			return nil, response.InternalServerError(err.Error())
+			if errors.As(err, &ErrVersionConflict{}) {
+				return nil, apierror.PreconditionFailed(err)
+			}
+			return nil, apierror.InternalServerError(err)
 		}
 
 		return response.OK("", req, nil), nil
@@ -83,8 +134,8 @@ func makeGetRolesEndpoint(service Service) Controller {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(AppReq)
 
-		if req.App == "" || req.ID == "" {
-			return nil, response.BadRequest(ErrUserIDAndAppAreRequired.Error())
+		if err := validation.Struct(req); err != nil {
+			return nil, err
 		}
 
 		f := Filters{
@@ -92,16 +143,85 @@ func makeGetRolesEndpoint(service Service) Controller {
 			App:    []string{req.App},
 		}
 
+		// the version is read before the role, like the version of the
+		// user, a concurrent update makes the ETag older and not newer
+		version, err := service.GetVersion(ctx, req.ID, req.App)
+		if err != nil {
+			if errors.As(err, &ErrUserAppNotFound{}) {
+				return nil, apierror.NotFound(err)
+			}
+			return nil, apierror.InternalServerError(err)
+		}
+
 		roles, err := service.GetAll(ctx, f, 0, 0, "")
 		if err != nil {
-			return nil, response.InternalServerError(err.Error())
+			return nil, apierror.InternalServerError(err)
 		}
 		if len(roles) < 1 {
-			return nil, response.NotFound(ErrUserAppNotFound{
+			return nil, apierror.NotFound(ErrUserAppNotFound{
 				req.ID, req.App,
-			}.Error())
+			})
 		}
 
-		return response.OK("", roles[0], nil), nil
+		return response.OK("", RoleRes{&roles[0], version}, nil), nil
+	}
+}
+
+// GetVersion is the version of the ETag header
+func (r RoleRes) GetVersion() int {
+	return r.Version
+}
+
+func makeGetAllEndpoint(service Service, config Config) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetAllReq)
+
+		if err := validation.Struct(req); err != nil {
+			return nil, err
+		}
+
+		filters := Filters{UserID: []string{req.ID}}
+
+		if req.Cursor != nil {
+			limit, err := cursor.Limit(req.Limit, config.LimPageDef)
+			if err != nil {
+				return nil, apierror.InternalServerError(err)
+			}
+
+			roles, meta, err := service.GetPage(ctx, filters, cursor.Page{Cursor: req.Cursor, Limit: limit})
+			if err != nil {
+				return nil, apierror.InternalServerError(err)
+			}
+
+			return cursor.OK("", roles, meta), nil
+		}
+
+		count, err := service.Count(ctx, filters)
+		if err != nil {
+			return nil, apierror.InternalServerError(err)
+		}
+
+		meta, err := meta.New(req.Page, req.Limit, count, config.LimPageDef)
+		if err != nil {
+			return nil, apierror.InternalServerError(err)
+		}
+
+		roles, err := service.GetAll(ctx, filters, meta.Offset(), meta.Limit(), "")
+		if err != nil {
+			return nil, apierror.InternalServerError(err)
+		}
+
+		return cursor.OK("", roles, cursor.Offset(roles, meta, Position)), nil
+	}
+}
+
+// authorizationError maps the Authorize errors to the response
+func authorizationError(err error) error {
+	switch err {
+	case ErrNotAdmin:
+		return apierror.Forbidden(err)
+	case user.InvalidAuthentication, user.ErrForbidden, user.ErrImpersonationNotAllowed:
+		return user.AuthorizationError(err)
 	}
+	return apierror.InternalServerError(err)
 }
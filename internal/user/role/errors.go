@@ -1,11 +1,14 @@
 package role
 
 import (
-	"errors"
 	"fmt"
+	"github.com/ncostamagna/axul-user/pkg/i18n"
 )
 
-var ErrUserIDAndAppAreRequired = errors.New("user id and app are required")
+var ErrUserIDAndAppAreRequired = i18n.NewError("ROLE_USER_ID_AND_APP_REQUIRED", "user id and app are required")
+
+// ErrNotAdmin is returned when a user without an admin role grants roles
+var ErrNotAdmin = i18n.NewError("ROLE_NOT_ADMIN", "only admins can grant roles")
 
 /*var FieldIsRequired = errors.New("Required values")
 var InvalidAuthentication = errors.New("Invalid authentication")
@@ -26,6 +29,33 @@ func (e ErrUserAppNotFound) Error() string {
 	return fmt.Sprintf("user '%s' with '%s' app doesn't exist", e.UserID, e.App)
 }
 
+func (e ErrUserAppNotFound) Code() string {
+	return "ROLE_NOT_FOUND"
+}
+
+func (e ErrUserAppNotFound) Args() []interface{} {
+	return []interface{}{e.UserID, e.App}
+}
+
+// ErrVersionConflict is returned by the updates of an old version of the
+// role, another request updated it after it was read
+type ErrVersionConflict struct {
+	UserID string
+	App    string
+}
+
+func (e ErrVersionConflict) Error() string {
+	return fmt.Sprintf("the roles of user '%s' in '%s' app were updated by another request, get them again and retry", e.UserID, e.App)
+}
+
+func (e ErrVersionConflict) Code() string {
+	return "ROLE_VERSION_CONFLICT"
+}
+
+func (e ErrVersionConflict) Args() []interface{} {
+	return []interface{}{e.UserID, e.App}
+}
+
 type InvalidRole struct {
 	Role string
 }
@@ -33,3 +63,11 @@ type InvalidRole struct {
 func (e InvalidRole) Error() string {
 	return fmt.Sprintf("the '%s' isn't valid", e.Role)
 }
+
+func (e InvalidRole) Code() string {
+	return "ROLE_INVALID"
+}
+
+func (e InvalidRole) Args() []interface{} {
+	return []interface{}{e.Role}
+}
@@ -0,0 +1,29 @@
+package role
+
+import "github.com/ncostamagna/axul-user/pkg/i18n"
+
+// the catalog of errors.go, the English text must match the error
+func init() {
+	i18n.Register(
+		i18n.Message{Code: "ROLE_USER_ID_AND_APP_REQUIRED", Field: "app", Text: map[string]string{
+			i18n.English: "user id and app are required",
+			i18n.Spanish: "el id de usuario y la app son obligatorios",
+		}},
+		i18n.Message{Code: "ROLE_NOT_FOUND", Text: map[string]string{
+			i18n.English: "user '%s' with '%s' app doesn't exist",
+			i18n.Spanish: "el usuario '%s' con la app '%s' no existe",
+		}},
+		i18n.Message{Code: "ROLE_INVALID", Field: "roles", Text: map[string]string{
+			i18n.English: "the '%s' isn't valid",
+			i18n.Spanish: "el rol '%s' no es válido",
+		}},
+		i18n.Message{Code: "ROLE_NOT_ADMIN", Text: map[string]string{
+			i18n.English: "only admins can grant roles",
+			i18n.Spanish: "solo los administradores pueden otorgar roles",
+		}},
+		i18n.Message{Code: "ROLE_VERSION_CONFLICT", Text: map[string]string{
+			i18n.English: "the roles of user '%s' in '%s' app were updated by another request, get them again and retry",
+			i18n.Spanish: "los roles del usuario '%s' en la app '%s' fueron actualizados por otra solicitud, vuelve a obtenerlos y reintenta",
+		}},
+	)
+}
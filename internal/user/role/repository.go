@@ -2,6 +2,8 @@ package role
 
 import (
 	"context"
+	"github.com/ncostamagna/axul-user/pkg/cursor"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
 	domain "github.com/ncostamagna/axul_domain/domain/user"
 	"github.com/ncostamagna/go-logger-hub/loghub"
 	"gorm.io/gorm"
@@ -9,10 +11,13 @@ import (
 
 type Repository interface {
 	GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Role, error)
+	GetPage(ctx context.Context, filters Filters, page cursor.Page) ([]domain.Role, error)
 	//Get(ctx context.Context, id string) (*domain.User, error)
 	Create(ctx context.Context, role *domain.Role) error
-	Update(ctx context.Context, userID, app string, role *uint64) error
+	Update(ctx context.Context, userID, app string, version int, role *uint64) error
+	GetVersion(ctx context.Context, userID, app string) (int, error)
 	//Delete(ctx context.Context, id string) error
+	DeleteByUser(ctx context.Context, userID string) error
 	Count(ctx context.Context, filters Filters) (int, error)
 }
 
@@ -26,35 +31,68 @@ func NewRepository(db *gorm.DB, log loghub.Logger) Repository {
 }
 
 func (r *repo) Create(ctx context.Context, role *domain.Role) error {
-	return r.db.Create(&role).Error
+	return transaction.DB(ctx, r.db).Create(role).Error
 }
 
-func (r *repo) Update(ctx context.Context, userID, app string, role *uint64) error {
-	values := make(map[string]interface{})
+// Update changes the role and increases the version, it returns
+// ErrVersionConflict when version isn't 0 and the role has another version
+func (r *repo) Update(ctx context.Context, userID, app string, version int, role *uint64) error {
+	values := map[string]interface{}{"version": gorm.Expr("version + 1")}
 
 	if role != nil {
 		values["role"] = *role
 	}
 
-	result := r.db.WithContext(ctx).Model(&domain.Role{}).Where("user_id = ? and app = ?", userID, app).Updates(values)
+	tx := transaction.DB(ctx, r.db).Model(&domain.Role{}).Where("user_id = ? and app = ?", userID, app)
+	if version > 0 {
+		tx = tx.Where("version = ?", version)
+	}
+
+	result := tx.Updates(values)
 	if err := result.Error; err != nil {
 		r.logger.Error(err)
 		return err
 	}
 
 	if result.RowsAffected == 0 {
+		if _, err := r.GetVersion(ctx, userID, app); err == nil {
+			return ErrVersionConflict{userID, app}
+		}
 		return ErrUserAppNotFound{userID, app}
 	}
 
 	return nil
 }
 
+func (r *repo) GetVersion(ctx context.Context, userID, app string) (int, error) {
+	var version int
+	result := transaction.DB(ctx, r.db).Model(&domain.Role{}).Select("version").Where("user_id = ? and app = ?", userID, app).Take(&version)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return version, nil
+}
+
+// DeleteByUser deletes every role of the user
+func (r *repo) DeleteByUser(ctx context.Context, userID string) error {
+	if err := transaction.DB(ctx, r.db).Where("user_id = ?", userID).Delete(&domain.Role{}).Error; err != nil {
+		r.logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
 func (r *repo) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Role, error) {
 	var role []domain.Role
 
-	tx := r.db.WithContext(ctx).Model(&role)
+	tx := transaction.DB(ctx, r.db).Model(&role)
 	applyFilters(tx, filters)
-	result := tx.Order("created_at desc").Find(&role)
+	if limit > 0 {
+		tx = tx.Offset(offset).Limit(limit)
+	}
+	result := tx.Order("created_at desc, id desc").Find(&role)
 
 	if err := result.Error; err != nil {
 		r.logger.Error(err)
@@ -64,9 +102,28 @@ func (r *repo) GetAll(ctx context.Context, filters Filters, offset, limit int) (
 	return role, nil
 }
 
+// GetPage returns the roles of the page and one more when there is a next
+// page, cursor.New trims it
+func (r *repo) GetPage(ctx context.Context, filters Filters, page cursor.Page) ([]domain.Role, error) {
+	var roles []domain.Role
+
+	tx := applyFilters(transaction.DB(ctx, r.db).Model(&roles), filters)
+	if err := page.Scope(tx).Find(&roles).Error; err != nil {
+		r.logger.Error(err)
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// Position is the cursor of a role in the lists
+func Position(r domain.Role) cursor.Cursor {
+	return cursor.Cursor{CreatedAt: r.CreatedAt, ID: r.ID}
+}
+
 func (r *repo) Count(ctx context.Context, filters Filters) (int, error) {
 	var count int64
-	tx := r.db.WithContext(ctx).Model(domain.Role{})
+	tx := transaction.DB(ctx, r.db).Model(domain.Role{})
 	tx = applyFilters(tx, filters)
 	if err := tx.Count(&count).Error; err != nil {
 		r.logger.Error(err)
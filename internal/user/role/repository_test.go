@@ -0,0 +1,34 @@
+package role_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/internal/user/role"
+	"github.com/ncostamagna/axul-user/internal/user/role/roletest"
+	"github.com/ncostamagna/axul-user/internal/user/usertest"
+	"github.com/ncostamagna/axul-user/pkg/cache"
+	"github.com/ncostamagna/axul-user/pkg/dbtest"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+)
+
+func TestRepository(t *testing.T) {
+	roletest.RepositoryContract(t, func(t *testing.T) (role.Repository, user.Repository) {
+		db := dbtest.Open(t)
+		return role.NewRepository(db, loghub.New()), user.NewRepository(db, loghub.New())
+	})
+}
+
+func TestFakeRepository(t *testing.T) {
+	roletest.RepositoryContract(t, func(t *testing.T) (role.Repository, user.Repository) {
+		return roletest.NewRepository(), usertest.NewRepository()
+	})
+}
+
+func TestCachedRepository(t *testing.T) {
+	roletest.RepositoryContract(t, func(t *testing.T) (role.Repository, user.Repository) {
+		db, store := dbtest.Open(t), cache.NewStore("roles", cache.NewLRU(100), time.Minute, loghub.New())
+		return role.NewCachedRepository(role.NewRepository(db, loghub.New()), store), user.NewRepository(db, loghub.New())
+	})
+}
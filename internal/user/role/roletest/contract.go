@@ -0,0 +1,292 @@
+package roletest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/internal/user/role"
+	"github.com/ncostamagna/axul-user/pkg/cursor"
+	domain "github.com/ncostamagna/axul_domain/domain/user"
+	"gorm.io/gorm"
+	"testing"
+)
+
+// RepositoryContract is the behavior every role.Repository must have,
+// newRepos returns an empty role repository and the user repository of
+// the owners of the roles
+func RepositoryContract(t *testing.T, newRepos func(t *testing.T) (role.Repository, user.Repository)) {
+	ctx := context.Background()
+
+	t.Run("create and get", func(t *testing.T) {
+		roles, users := newRepos(t)
+		john := createUser(t, users, "john")
+
+		r := &domain.Role{UserID: john.ID, App: "crm", Role: 3}
+		if err := roles.Create(ctx, r); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+
+		if r.ID == "" {
+			t.Fatal("create didn't set the id")
+		}
+
+		got, err := roles.GetAll(ctx, role.Filters{UserID: []string{john.ID}}, 0, 0)
+		if err != nil {
+			t.Fatalf("get all: %v", err)
+		}
+
+		if len(got) != 1 || got[0].ID != r.ID || got[0].App != "crm" || got[0].Role != 3 {
+			t.Errorf("get all returned %+v", got)
+		}
+
+		if err := roles.Create(ctx, &domain.Role{UserID: john.ID, App: "crm"}); !errors.Is(err, gorm.ErrDuplicatedKey) {
+			t.Errorf("create the same app twice: want ErrDuplicatedKey, got %v", err)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		roles, users := newRepos(t)
+		john := createUser(t, users, "john")
+		createRole(t, roles, john.ID, "crm")
+
+		value := uint64(5)
+		if err := roles.Update(ctx, john.ID, "crm", 0, &value); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+
+		got, err := roles.GetAll(ctx, role.Filters{UserID: []string{john.ID}, App: []string{"crm"}}, 0, 0)
+		if err != nil || len(got) != 1 || got[0].Role != 5 {
+			t.Errorf("get all after update returned %+v, %v", got, err)
+		}
+	})
+
+	t.Run("update not found", func(t *testing.T) {
+		roles, users := newRepos(t)
+		john := createUser(t, users, "john")
+		createRole(t, roles, john.ID, "crm")
+
+		value := uint64(5)
+		err := roles.Update(ctx, john.ID, "erp", 0, &value)
+		if !errors.As(err, &role.ErrUserAppNotFound{}) {
+			t.Errorf("want ErrUserAppNotFound, got %v", err)
+		}
+	})
+
+	t.Run("version", func(t *testing.T) {
+		roles, users := newRepos(t)
+		john := createUser(t, users, "john")
+		createRole(t, roles, john.ID, "crm")
+
+		if version, err := roles.GetVersion(ctx, john.ID, "crm"); err != nil || version != 1 {
+			t.Fatalf("get version of a new role returned %d, %v, want 1", version, err)
+		}
+
+		value := uint64(5)
+		if err := roles.Update(ctx, john.ID, "crm", 1, &value); err != nil {
+			t.Fatalf("update version 1: %v", err)
+		}
+
+		other := uint64(1)
+		if err := roles.Update(ctx, john.ID, "crm", 1, &other); !errors.As(err, &role.ErrVersionConflict{}) {
+			t.Errorf("update an old version: want ErrVersionConflict, got %v", err)
+		}
+
+		got, err := roles.GetAll(ctx, role.Filters{UserID: []string{john.ID}, App: []string{"crm"}}, 0, 0)
+		if err != nil || len(got) != 1 || got[0].Role != 5 {
+			t.Errorf("the update of an old version changed the role: %+v, %v", got, err)
+		}
+
+		if version, err := roles.GetVersion(ctx, john.ID, "crm"); err != nil || version != 2 {
+			t.Errorf("get version after an update returned %d, %v, want 2", version, err)
+		}
+
+		if err := roles.Update(ctx, john.ID, "erp", 1, &value); !errors.As(err, &role.ErrUserAppNotFound{}) {
+			t.Errorf("update an unknown app with version: want ErrUserAppNotFound, got %v", err)
+		}
+
+		if _, err := roles.GetVersion(ctx, john.ID, "erp"); err == nil {
+			t.Error("get version of an unknown app didn't fail")
+		}
+	})
+
+	t.Run("delete by user", func(t *testing.T) {
+		roles, users := newRepos(t)
+		john := createUser(t, users, "john")
+		jane := createUser(t, users, "jane")
+		createRole(t, roles, john.ID, "crm")
+		createRole(t, roles, john.ID, "erp")
+		janeCRM := createRole(t, roles, jane.ID, "crm")
+
+		if err := roles.DeleteByUser(ctx, john.ID); err != nil {
+			t.Fatalf("delete by user: %v", err)
+		}
+
+		got, err := roles.GetAll(ctx, role.Filters{}, 0, 0)
+		if err != nil || !sameIDs(got, []string{janeCRM.ID}) {
+			t.Errorf("get all after delete returned %v, %v, want %v", ids(got), err, []string{janeCRM.ID})
+		}
+
+		if err := roles.DeleteByUser(ctx, john.ID); err != nil {
+			t.Errorf("delete a user without roles: %v", err)
+		}
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		roles, users := newRepos(t)
+		john := createUser(t, users, "john")
+		jane := createUser(t, users, "jane")
+		johnCRM := createRole(t, roles, john.ID, "crm")
+		johnERP := createRole(t, roles, john.ID, "erp")
+		janeCRM := createRole(t, roles, jane.ID, "crm")
+
+		cases := []struct {
+			name    string
+			filters role.Filters
+			want    []string
+		}{
+			{"none", role.Filters{}, []string{johnCRM.ID, johnERP.ID, janeCRM.ID}},
+			{"user", role.Filters{UserID: []string{john.ID}}, []string{johnCRM.ID, johnERP.ID}},
+			{"app", role.Filters{App: []string{"crm"}}, []string{johnCRM.ID, janeCRM.ID}},
+			{"apps", role.Filters{App: []string{"crm", "erp"}}, []string{johnCRM.ID, johnERP.ID, janeCRM.ID}},
+			{"user and app", role.Filters{UserID: []string{jane.ID}, App: []string{"erp"}}, nil},
+		}
+
+		for _, c := range cases {
+			got, err := roles.GetAll(ctx, c.filters, 0, 0)
+			if err != nil {
+				t.Fatalf("%s: get all: %v", c.name, err)
+			}
+
+			if !sameIDs(got, c.want) {
+				t.Errorf("%s: got %v, want %v", c.name, ids(got), c.want)
+			}
+
+			count, err := roles.Count(ctx, c.filters)
+			if err != nil || count != len(c.want) {
+				t.Errorf("%s: count returned %d, %v, want %d", c.name, count, err, len(c.want))
+			}
+		}
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		roles, users := newRepos(t)
+		john := createUser(t, users, "john")
+
+		var all []string
+		for i := 0; i < 5; i++ {
+			all = append(all, createRole(t, roles, john.ID, fmt.Sprintf("app-%d", i)).ID)
+		}
+
+		var pages []domain.Role
+		for offset := 0; offset < 6; offset += 2 {
+			page, err := roles.GetAll(ctx, role.Filters{UserID: []string{john.ID}}, offset, 2)
+			if err != nil {
+				t.Fatalf("get all: %v", err)
+			}
+
+			if want := min(2, 5-offset); len(page) != want {
+				t.Errorf("page at %d has %d roles, want %d", offset, len(page), want)
+			}
+			pages = append(pages, page...)
+		}
+
+		if !sameIDs(pages, all) {
+			t.Errorf("pages returned %v, want every role once %v", ids(pages), all)
+		}
+	})
+
+	t.Run("cursor pagination", func(t *testing.T) {
+		roles, users := newRepos(t)
+		john := createUser(t, users, "john")
+
+		var all []string
+		for i := 0; i < 5; i++ {
+			all = append([]string{createRole(t, roles, john.ID, fmt.Sprintf("app-%d", i)).ID}, all...)
+		}
+
+		filters := role.Filters{UserID: []string{john.ID}}
+		scroll := func(page cursor.Page, prev bool) ([]domain.Role, *cursor.Meta) {
+			var got []domain.Role
+			for {
+				rows, err := roles.GetPage(ctx, filters, page)
+				if err != nil {
+					t.Fatalf("get page: %v", err)
+				}
+
+				list, meta := cursor.New(rows, page, role.Position)
+				next := meta.Next
+				if prev {
+					got, next = append(list, got...), meta.Prev
+				} else {
+					got = append(got, list...)
+				}
+
+				if next == "" {
+					return got, meta
+				}
+				page.Cursor, _ = cursor.Parse(next)
+			}
+		}
+
+		pages, last := scroll(cursor.Page{Limit: 2}, false)
+		if got := ids(pages); fmt.Sprint(got) != fmt.Sprint(all) {
+			t.Errorf("the next cursors returned %v, want %v", got, all)
+		}
+
+		c, _ := cursor.Parse(last.Prev)
+		if back, _ := scroll(cursor.Page{Cursor: c, Limit: 2}, true); fmt.Sprint(ids(back)) != fmt.Sprint(all[:4]) {
+			t.Errorf("the prev cursors returned %v, want %v", ids(back), all[:4])
+		}
+	})
+}
+
+func createUser(t *testing.T, users user.Repository, userName string) *domain.User {
+	t.Helper()
+
+	u := &domain.User{UserName: userName, Email: userName + "@example.com", Language: "en"}
+	if err := users.Create(context.Background(), u, nil); err != nil {
+		t.Fatalf("create user %s: %v", userName, err)
+	}
+
+	return u
+}
+
+func createRole(t *testing.T, roles role.Repository, userID, app string) *domain.Role {
+	t.Helper()
+
+	r := &domain.Role{UserID: userID, App: app}
+	if err := roles.Create(context.Background(), r); err != nil {
+		t.Fatalf("create role %s: %v", app, err)
+	}
+
+	return r
+}
+
+func ids(roles []domain.Role) []string {
+	ids := make([]string, 0, len(roles))
+	for _, r := range roles {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func sameIDs(roles []domain.Role, want []string) bool {
+	if len(roles) != len(want) {
+		return false
+	}
+
+	seen := map[string]int{}
+	for _, id := range want {
+		seen[id]++
+	}
+
+	for _, r := range roles {
+		if seen[r.ID] == 0 {
+			return false
+		}
+		seen[r.ID]--
+	}
+
+	return true
+}
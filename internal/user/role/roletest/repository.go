@@ -0,0 +1,163 @@
+package roletest
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/ncostamagna/axul-user/internal/user/role"
+	"github.com/ncostamagna/axul-user/pkg/cursor"
+	domain "github.com/ncostamagna/axul_domain/domain/user"
+	"gorm.io/gorm"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Repository is a role.Repository in memory
+type Repository struct {
+	mu       sync.Mutex
+	roles    map[string]domain.Role
+	versions map[string]int
+}
+
+// NewRepository is a fake role.Repository for the tests
+func NewRepository() *Repository {
+	return &Repository{roles: map[string]domain.Role{}, versions: map[string]int{}}
+}
+
+func (r *Repository) GetAll(ctx context.Context, filters role.Filters, offset, limit int) ([]domain.Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	roles := r.filter(filters)
+	if limit > 0 {
+		if offset > len(roles) {
+			offset = len(roles)
+		}
+		roles = roles[offset:min(offset+limit, len(roles))]
+	}
+
+	return roles, nil
+}
+
+func (r *Repository) GetPage(ctx context.Context, filters role.Filters, page cursor.Page) ([]domain.Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return cursor.Slice(r.filter(filters), page, role.Position), nil
+}
+
+func (r *Repository) Create(ctx context.Context, rl *domain.Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.roles {
+		if existing.UserID == rl.UserID && existing.App == rl.App {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+
+	now := time.Now()
+	rl.ID = uuid.New().String()
+	rl.CreatedAt, rl.UpdatedAt = now, now
+	r.roles[rl.ID] = *rl
+	r.versions[rl.ID] = 1
+	return nil
+}
+
+func (r *Repository) Update(ctx context.Context, userID, app string, version int, value *uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rl, ok := r.find(userID, app)
+	if !ok {
+		return role.ErrUserAppNotFound{UserID: userID, App: app}
+	}
+
+	if version > 0 && r.versions[rl.ID] != version {
+		return role.ErrVersionConflict{UserID: userID, App: app}
+	}
+
+	if value != nil {
+		rl.Role = *value
+	}
+	rl.UpdatedAt = time.Now()
+	r.roles[rl.ID] = rl
+	r.versions[rl.ID]++
+	return nil
+}
+
+func (r *Repository) GetVersion(ctx context.Context, userID, app string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rl, ok := r.find(userID, app)
+	if !ok {
+		return 0, gorm.ErrRecordNotFound
+	}
+
+	return r.versions[rl.ID], nil
+}
+
+func (r *Repository) DeleteByUser(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, rl := range r.roles {
+		if rl.UserID == userID {
+			delete(r.roles, id)
+			delete(r.versions, id)
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) find(userID, app string) (domain.Role, bool) {
+	for _, rl := range r.roles {
+		if rl.UserID == userID && rl.App == app {
+			return rl, true
+		}
+	}
+
+	return domain.Role{}, false
+}
+
+func (r *Repository) Count(ctx context.Context, filters role.Filters) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.filter(filters)), nil
+}
+
+func (r *Repository) filter(f role.Filters) []domain.Role {
+	roles := []domain.Role{}
+	for _, rl := range r.roles {
+		if f.UserID != nil && !contains(f.UserID, rl.UserID) {
+			continue
+		}
+
+		if f.App != nil && !contains(f.App, rl.App) {
+			continue
+		}
+
+		roles = append(roles, rl)
+	}
+
+	sort.Slice(roles, func(i, j int) bool {
+		if !roles[i].CreatedAt.Equal(roles[j].CreatedAt) {
+			return roles[i].CreatedAt.After(roles[j].CreatedAt)
+		}
+		return roles[i].ID > roles[j].ID
+	})
+
+	return roles
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
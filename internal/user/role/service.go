@@ -2,12 +2,19 @@ package role
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/cursor"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
 	domain "github.com/ncostamagna/axul_domain/domain/user"
 	"github.com/ncostamagna/go-logger-hub/loghub"
+	"gorm.io/gorm"
 )
 
+// AdminRoles are the roles of the admin app that make a user an admin
+var AdminRoles = domain.ADMIN_RW_ROLE | domain.OWNER_ROLE
+
 type Filters struct {
 	UserID []string
 	App    []string
@@ -15,23 +22,30 @@ type Filters struct {
 
 type Service interface {
 	Create(ctx context.Context, userId, app string) (*domain.Role, error)
-	AddRole(ctx context.Context, userId, app string, roles []string) error
+	AddRole(ctx context.Context, userId, app string, version int, roles []string) error
+	GetVersion(ctx context.Context, userId, app string) (int, error)
 	GetAll(ctx context.Context, filters Filters, offset, limit int, pload string) ([]domain.Role, error)
+	GetPage(ctx context.Context, filters Filters, page cursor.Page) ([]domain.Role, *cursor.Meta, error)
 	Count(ctx context.Context, filters Filters) (int, error)
+	Purge(ctx context.Context, userId string) error
+	IsAdmin(ctx context.Context, app, userId string) (bool, error)
+	Authorize(ctx context.Context, token, app string) (*user.TokenInfo, error)
 }
 
 type service struct {
 	repo    Repository
 	userSrv user.Service
+	tx      transaction.Manager
 	//auth    authentication.Auth
 	logger loghub.Logger
 }
 
 // NewService is a service handler
-func NewService(repo Repository, userSrv user.Service, logger loghub.Logger) Service {
+func NewService(repo Repository, userSrv user.Service, tx transaction.Manager, logger loghub.Logger) Service {
 	return &service{
 		repo:    repo,
 		userSrv: userSrv,
+		tx:      tx,
 		logger:  logger,
 	}
 }
@@ -53,7 +67,9 @@ func (s *service) Create(ctx context.Context, userId, app string) (*domain.Role,
 
 }
 
-func (s *service) AddRole(ctx context.Context, userId, app string, roles []string) error {
+// AddRole replaces the roles of the user in the app, version is the
+// version the client read and 0 updates any version
+func (s *service) AddRole(ctx context.Context, userId, app string, version int, roles []string) error {
 
 	role := domain.Role{
 		UserID: userId,
@@ -66,7 +82,7 @@ func (s *service) AddRole(ctx context.Context, userId, app string, roles []strin
 		}
 	}
 
-	if err := s.repo.Update(ctx, role.UserID, role.App, &role.Role); err != nil {
+	if err := s.repo.Update(ctx, role.UserID, role.App, version, &role.Role); err != nil {
 		return err
 	}
 
@@ -74,6 +90,17 @@ func (s *service) AddRole(ctx context.Context, userId, app string, roles []strin
 
 }
 
+// GetVersion returns the version of the roles of the user in the app, it's
+// increased by every update
+func (s *service) GetVersion(ctx context.Context, userId, app string) (int, error) {
+	version, err := s.repo.GetVersion(ctx, userId, app)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, ErrUserAppNotFound{userId, app}
+	}
+
+	return version, err
+}
+
 func (s *service) GetAll(ctx context.Context, filters Filters, offset, limit int, pload string) ([]domain.Role, error) {
 	roles, err := s.repo.GetAll(ctx, filters, offset, limit)
 	if err != nil {
@@ -83,6 +110,84 @@ func (s *service) GetAll(ctx context.Context, filters Filters, offset, limit int
 	return roles, nil
 }
 
+// GetPage returns the roles of a cursor page and the cursors of the pages
+// around it
+func (s *service) GetPage(ctx context.Context, filters Filters, page cursor.Page) ([]domain.Role, *cursor.Meta, error) {
+	roles, err := s.repo.GetPage(ctx, filters, page)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	roles, meta := cursor.New(roles, page, Position)
+	return roles, meta, nil
+}
+
 func (s service) Count(ctx context.Context, filters Filters) (int, error) {
 	return s.repo.Count(ctx, filters)
 }
+
+// Purge deletes the roles of the user and the user in a transaction, the
+// roles are kept when the user can't be deleted
+func (s *service) Purge(ctx context.Context, userId string) error {
+	err := s.tx.Do(ctx, func(ctx context.Context) error {
+		if err := s.repo.DeleteByUser(ctx, userId); err != nil {
+			return err
+		}
+
+		return s.userSrv.Delete(ctx, userId)
+	})
+	if err != nil {
+		return err
+	}
+	s.logger.Info(fmt.Sprintf("Purge %s User", userId))
+
+	return nil
+}
+
+// IsAdmin tells if the user has an admin role in the app, without an app
+// there aren't admins
+func (s *service) IsAdmin(ctx context.Context, app, userId string) (bool, error) {
+	if app == "" {
+		return false, nil
+	}
+
+	roles, err := s.repo.GetAll(ctx, Filters{UserID: []string{userId}, App: []string{app}}, 0, 0)
+	if err != nil {
+		return false, err
+	}
+
+	return len(roles) > 0 && roles[0].Role&AdminRoles != 0, nil
+}
+
+// Authorize checks that the token belongs to an admin of the app, only
+// the admins grant roles. Impersonation tokens aren't accepted and the
+// access tokens need the roles:write scope
+func (s *service) Authorize(ctx context.Context, token, app string) (*user.TokenInfo, error) {
+	if token == "" {
+		return nil, user.InvalidAuthentication
+	}
+
+	info, err := s.userSrv.CheckToken(ctx, token)
+	if err != nil {
+		return nil, user.InvalidAuthentication
+	}
+
+	if info.ActorID != "" {
+		return nil, user.ErrImpersonationNotAllowed
+	}
+
+	if !info.Allows(user.ScopeRolesWrite) {
+		return nil, user.ErrForbidden
+	}
+
+	admin, err := s.IsAdmin(ctx, app, info.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !admin {
+		return nil, ErrNotAdmin
+	}
+
+	return info, nil
+}
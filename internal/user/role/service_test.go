@@ -0,0 +1,190 @@
+package role_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/internal/user/role"
+	"github.com/ncostamagna/axul-user/internal/user/role/roletest"
+	"github.com/ncostamagna/axul-user/internal/user/usertest"
+	"github.com/ncostamagna/axul-user/pkg/dbtest"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	domain "github.com/ncostamagna/axul_domain/domain/user"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+)
+
+func TestServiceAddRole(t *testing.T) {
+	ctx := context.Background()
+	srv := role.NewService(roletest.NewRepository(), nil, transaction.None, loghub.New())
+
+	created, err := srv.Create(ctx, "user-id", "app")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if created.ID == "" || created.Role != 0 {
+		t.Errorf("create returned %+v", created)
+	}
+
+	if err := srv.AddRole(ctx, "user-id", "app", 0, []string{"read", "root"}); err != (role.InvalidRole{Role: "root"}) {
+		t.Errorf("invalid role: want InvalidRole, got %v", err)
+	}
+
+	if err := srv.AddRole(ctx, "other-id", "app", 0, []string{"read"}); !errors.As(err, &role.ErrUserAppNotFound{}) {
+		t.Errorf("unknown user app: want ErrUserAppNotFound, got %v", err)
+	}
+
+	version, err := srv.GetVersion(ctx, "user-id", "app")
+	if err != nil {
+		t.Fatalf("get version: %v", err)
+	}
+
+	if _, err := srv.GetVersion(ctx, "other-id", "app"); !errors.As(err, &role.ErrUserAppNotFound{}) {
+		t.Errorf("get version of an unknown user app: want ErrUserAppNotFound, got %v", err)
+	}
+
+	if err := srv.AddRole(ctx, "user-id", "app", version, []string{"read", "write", "read"}); err != nil {
+		t.Fatalf("add role: %v", err)
+	}
+
+	if err := srv.AddRole(ctx, "user-id", "app", version, []string{"owner"}); !errors.As(err, &role.ErrVersionConflict{}) {
+		t.Errorf("add role to an old version: want ErrVersionConflict, got %v", err)
+	}
+
+	roles, err := srv.GetAll(ctx, role.Filters{UserID: []string{"user-id"}}, 0, 0, "")
+	if err != nil || len(roles) != 1 {
+		t.Fatalf("get all returned %v, %v", roles, err)
+	}
+
+	if want := domain.READ_ROLE | domain.WRITE_ROLE; roles[0].Role != want {
+		t.Errorf("the role is %b, want %b", roles[0].Role, want)
+	}
+}
+
+func TestServicePurge(t *testing.T) {
+	ctx := context.Background()
+	locales, err := user.NewLocales()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, logger := dbtest.Open(t), loghub.New()
+	users := user.NewService(user.NewRepository(db, logger), usertest.NewAuth(), nil, nil, locales, logger)
+	roles := role.NewRepository(db, logger)
+
+	john, err := users.Create(ctx, "john", "John", "Doe", "secret-password", "john@example.com", "", "", "", "", "", "")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	for _, app := range []string{"crm", "erp"} {
+		if err := roles.Create(ctx, &domain.Role{UserID: john.ID, App: app}); err != nil {
+			t.Fatalf("create role: %v", err)
+		}
+	}
+
+	failed := errors.New("failed")
+	srv := role.NewService(roles, failingDelete{users, failed}, transaction.NewManager(db), logger)
+	if err := srv.Purge(ctx, john.ID); err != failed {
+		t.Fatalf("purge with a failing user delete returned %v", err)
+	}
+
+	if count, err := srv.Count(ctx, role.Filters{UserID: []string{john.ID}}); err != nil || count != 2 {
+		t.Errorf("the user has %d roles after a failed purge, %v, want 2", count, err)
+	}
+
+	srv = role.NewService(roles, users, transaction.NewManager(db), logger)
+	if err := srv.Purge(ctx, john.ID); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+
+	if count, err := srv.Count(ctx, role.Filters{UserID: []string{john.ID}}); err != nil || count != 0 {
+		t.Errorf("the user has %d roles after the purge, %v", count, err)
+	}
+
+	if _, err := users.Get(ctx, john.ID, ""); err == nil {
+		t.Error("the user exists after the purge")
+	}
+
+	if err := srv.Purge(ctx, john.ID); !errors.As(err, &user.ErrNotFound{}) {
+		t.Errorf("purge a deleted user: want ErrNotFound, got %v", err)
+	}
+}
+
+// failingDelete is a user.Service that can't delete users
+type failingDelete struct {
+	user.Service
+	err error
+}
+
+func (s failingDelete) Delete(ctx context.Context, id string) error {
+	return s.err
+}
+
+// tokens are the access tokens of the tests, the other tokens are left
+// to the auth
+type tokens map[string]*user.TokenInfo
+
+func (t tokens) Check(_ context.Context, token string) (*user.TokenInfo, error) {
+	return t[token], nil
+}
+
+func TestServiceAuthorize(t *testing.T) {
+	ctx := context.Background()
+
+	locales, err := user.NewLocales()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth, pats := usertest.NewAuth(), tokens{}
+	users := user.NewService(usertest.NewRepository(), auth, pats, nil, locales, loghub.New())
+	srv := role.NewService(roletest.NewRepository(), users, transaction.None, loghub.New())
+
+	admin, err := users.Create(ctx, "admin", "Ada", "Admin", "secret-password", "admin@example.com", "", "", "", "", "", "")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	john, err := users.Create(ctx, "john", "John", "Doe", "secret-password", "john@example.com", "", "", "", "", "", "")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if _, err := srv.Create(ctx, admin.ID, "admin"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if err := srv.AddRole(ctx, admin.ID, "admin", 0, []string{"owner"}); err != nil {
+		t.Fatalf("add role: %v", err)
+	}
+
+	adminToken, _ := users.IssueToken(ctx, admin)
+	johnToken, _ := users.IssueToken(ctx, john)
+	impersonation, _ := auth.Create(admin.ID, admin.UserName, "imp:session:"+john.ID, true, 0)
+	pats["read"] = &user.TokenInfo{UserID: admin.ID, Scopes: []string{user.ScopeRolesRead}}
+	pats["write"] = &user.TokenInfo{UserID: admin.ID, Scopes: []string{user.ScopeRolesWrite}}
+
+	cases := []struct {
+		name  string
+		token string
+		app   string
+		want  error
+	}{
+		{"no token", "", "admin", user.InvalidAuthentication},
+		{"not admin", johnToken, "admin", role.ErrNotAdmin},
+		{"no admin app", adminToken, "", role.ErrNotAdmin},
+		{"impersonation", impersonation, "admin", user.InvalidAuthentication},
+		{"read scope", "read", "admin", user.ErrForbidden},
+		{"admin", adminToken, "admin", nil},
+		{"write scope", "write", "admin", nil},
+	}
+
+	for _, c := range cases {
+		if _, err := srv.Authorize(ctx, c.token, c.app); err != c.want {
+			t.Errorf("%s: want %v, got %v", c.name, c.want, err)
+		}
+	}
+}
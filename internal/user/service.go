@@ -2,45 +2,128 @@ package user
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"github.com/ncostamagna/axul-user/pkg/cursor"
 	domain "github.com/ncostamagna/axul_domain/domain/user"
 	"github.com/ncostamagna/go-logger-hub/loghub"
+	"strings"
+	"time"
 
 	authentication "github.com/ncostamagna/axul_auth/auth"
 
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/text/unicode/norm"
 )
 
+const serviceAccountPrefix = "sa_"
+
+// dummyHash is compared when the login doesn't match any user
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// impersonationPrefix marks the hash claim of the impersonation tokens,
+// the claim is "imp:<session id>:<actor id>"
+const impersonationPrefix = "imp:"
+
+const (
+	ScopeUsersRead  = "users:read"
+	ScopeUsersWrite = "users:write"
+	ScopeRolesRead  = "roles:read"
+	ScopeRolesWrite = "roles:write"
+)
+
+// Scopes are the permissions that can be granted to a personal access token
+var Scopes = []string{ScopeUsersRead, ScopeUsersWrite, ScopeRolesRead, ScopeRolesWrite}
+
+// TokenInfo is the owner and permissions of a token, Scopes is nil for
+// session tokens because they can do everything. ActorID and SessionID are
+// set when an admin is impersonating the user
+type TokenInfo struct {
+	UserID    string
+	Scopes    []string
+	ActorID   string
+	SessionID string
+}
+
+// ImpersonationChecker tells if an impersonation session is still active,
+// a stopped or expired session invalidates its tokens
+type ImpersonationChecker interface {
+	Active(ctx context.Context, sessionID string) (bool, error)
+}
+
+// TokenChecker validates the tokens that aren't session JWTs, like the
+// personal access tokens. Check returns nil info when it doesn't know the
+// token format
+type TokenChecker interface {
+	Check(ctx context.Context, token string) (*TokenInfo, error)
+}
+
+// Filters.Kind is KindHuman or KindService, empty means every user.
+// UserName and Email are compared normalized and CreatedBy matches the
+// service accounts created by the user
 type Filters struct {
-	ID       []string
-	UserName string
+	ID         []string
+	UserName   string
+	Email      string
+	Kind       string
+	CreatedBy  string
+	Attributes []AttributeFilter
+}
+
+// AttributeFilter matches the users whose custom attribute Key of the App
+// has the Value, Key is a top-level attribute and Value is the JSON value as
+// text, "true" for a boolean and an integer like "30"
+type AttributeFilter struct {
+	App   string
+	Key   string
+	Value string
 }
 
 type Service interface {
 	Get(ctx context.Context, id, pload string) (*domain.User, error)
-	GetByToken(ctx context.Context, token string) (*domain.User, error)
+	GetByToken(ctx context.Context, token string) (*domain.User, *TokenInfo, error)
+	CheckToken(ctx context.Context, token string) (*TokenInfo, error)
 	GetAll(ctx context.Context, filters Filters, offset, limit int, pload string) ([]domain.User, error)
-	Create(ctx context.Context, userName, firstName, lastName, password, email, phone, clientID, clientSecret, token, language string) (*domain.User, error)
-	Update(ctx context.Context, id string, firstname, lastname, email, phone, photo, language *string) error
+	GetPage(ctx context.Context, filters Filters, page cursor.Page) ([]domain.User, *cursor.Meta, error)
+	Create(ctx context.Context, userName, firstName, lastName, password, email, phone, clientID, clientSecret, token, language, timezone string) (*domain.User, error)
+	Update(ctx context.Context, id string, version int, firstname, lastname, email, phone, photo, language, timezone *string) error
+	GetVersion(ctx context.Context, id string) (int, error)
 	UpdatePassword(ctx context.Context, id, newPassword, oldPassword string) error
 	Delete(ctx context.Context, id string) error
-	Login(ctx context.Context, user *domain.User, password string) (string, error)
-	TokenAccess(ctx context.Context, id, token string) (*domain.User, error)
+	GetByLogin(ctx context.Context, login string) (*domain.User, error)
+	GetPhone(ctx context.Context, id string) (*Phone, error)
+	GetLocale(ctx context.Context, id string) (*Locale, error)
+	VerifyPhone(ctx context.Context, id, number string) error
+	Login(ctx context.Context, login, password string) (*domain.User, string, error)
+	IssueToken(ctx context.Context, user *domain.User) (string, error)
+	IssueImpersonationToken(ctx context.Context, user *domain.User, actorID, sessionID string, duration time.Duration) (string, error)
+	CreateServiceAccount(ctx context.Context, name, description, createdBy string) (*domain.User, string, error)
+	ClientCredentials(ctx context.Context, clientID, clientSecret string) (*domain.User, string, error)
+	Authorize(ctx context.Context, token, id, scope string) (*domain.User, error)
+	TokenAccess(ctx context.Context, id, token string) (*domain.User, *TokenInfo, error)
 	Count(ctx context.Context, filters Filters) (int, error)
 }
 
 type service struct {
-	repo   Repository
-	auth   authentication.Auth
-	logger loghub.Logger
+	repo           Repository
+	auth           authentication.Auth
+	tokens         TokenChecker
+	impersonations ImpersonationChecker
+	locales        *Locales
+	logger         loghub.Logger
 }
 
-// NewService is a service handler
-func NewService(repo Repository, auth authentication.Auth, logger loghub.Logger) Service {
+// NewService is a service handler, tokens can be nil when only session
+// tokens are accepted and impersonations can be nil when it's disabled
+func NewService(repo Repository, auth authentication.Auth, tokens TokenChecker, impersonations ImpersonationChecker, locales *Locales, logger loghub.Logger) Service {
 	return &service{
-		repo:   repo,
-		auth:   auth,
-		logger: logger,
+		repo:           repo,
+		auth:           auth,
+		tokens:         tokens,
+		impersonations: impersonations,
+		locales:        locales,
+		logger:         logger,
 	}
 }
 
@@ -55,14 +138,58 @@ func (s *service) Get(ctx context.Context, id, pload string) (*domain.User, erro
 	return user, nil
 }
 
-func (s *service) GetByToken(ctx context.Context, token string) (*domain.User, error) {
+func (s *service) GetByToken(ctx context.Context, token string) (*domain.User, *TokenInfo, error) {
+	info, err := s.CheckToken(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.Get(ctx, info.UserID, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, info, nil
+}
+
+// CheckToken validates a session token, an impersonation token or any
+// token known by the TokenChecker
+func (s *service) CheckToken(ctx context.Context, token string) (*TokenInfo, error) {
+	if s.tokens != nil {
+		info, err := s.tokens.Check(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+
+		if info != nil {
+			return info, nil
+		}
+	}
+
 	u, err := s.auth.Check(token)
-	fmt.Println(u)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.Get(ctx, u.ID, "")
+	if !strings.HasPrefix(u.Hash, impersonationPrefix) {
+		return &TokenInfo{UserID: u.ID}, nil
+	}
+
+	sessionID, actorID, _ := strings.Cut(strings.TrimPrefix(u.Hash, impersonationPrefix), ":")
+	if s.impersonations == nil || actorID == "" {
+		return nil, authentication.ErrInvalidAuthentication
+	}
+
+	active, err := s.impersonations.Active(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !active {
+		return nil, authentication.ErrInvalidAuthentication
+	}
+
+	return &TokenInfo{UserID: u.ID, ActorID: actorID, SessionID: sessionID}, nil
 }
 
 func (s *service) GetAll(ctx context.Context, filters Filters, offset, limit int, pload string) ([]domain.User, error) {
@@ -76,7 +203,40 @@ func (s *service) GetAll(ctx context.Context, filters Filters, offset, limit int
 	return users, nil
 }
 
-func (s *service) Create(ctx context.Context, userName, firstName, lastName, password, email, phone, clientID, clientSecret, token, language string) (*domain.User, error) {
+// GetPage returns the users of a cursor page and the cursors of the pages
+// around it
+func (s *service) GetPage(ctx context.Context, filters Filters, page cursor.Page) ([]domain.User, *cursor.Meta, error) {
+	users, err := s.repo.GetPage(ctx, filters, page)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, nil, err
+	}
+
+	users, meta := cursor.New(users, page, Position)
+	s.logger.Info(fmt.Sprintf("Get %d Users", len(users)))
+	return users, meta, nil
+}
+
+func (s *service) Create(ctx context.Context, userName, firstName, lastName, password, email, phone, clientID, clientSecret, token, language, timezone string) (*domain.User, error) {
+
+	locale := Locale{Language: s.locales.Default(), Timezone: timezone}
+	if language != "" {
+		tag, err := s.locales.Resolve(language)
+		if err != nil {
+			return nil, err
+		}
+		locale.Language = tag
+	}
+
+	if timezone != "" {
+		if err := ValidTimezone(timezone); err != nil {
+			return nil, err
+		}
+	}
+
+	if strings.Contains(userName, "@") {
+		return nil, ErrInvalidUserName{userName}
+	}
 
 	hashPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -84,29 +244,32 @@ func (s *service) Create(ctx context.Context, userName, firstName, lastName, pas
 		return nil, err
 	}
 
-	var lang domain.Language
+	if err := s.available(ctx, userName, email); err != nil {
+		return nil, err
+	}
 
-	switch domain.Language(language) {
-	case domain.English, domain.Spanish:
-		lang = domain.Language(language)
-	default:
-		lang = domain.English
+	if phone != "" {
+		p, err := ParsePhone(phone)
+		if err != nil {
+			return nil, err
+		}
+		phone = p.Number
 	}
 
 	user := domain.User{
-		UserName:     userName,
+		UserName:     norm.NFKC.String(strings.TrimSpace(userName)),
 		FirstName:    firstName,
 		LastName:     lastName,
 		Password:     string(hashPassword),
-		Email:        email,
+		Email:        norm.NFKC.String(strings.TrimSpace(email)),
 		Phone:        phone,
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
 		Token:        token,
-		Language:     lang,
+		Language:     domain.Language(Base(locale.Language)),
 	}
 
-	if err := s.repo.Create(ctx, &user); err != nil {
+	if err := s.repo.Create(ctx, &user, &locale); err != nil {
 		s.logger.Error(err)
 		return nil, err
 	}
@@ -116,20 +279,39 @@ func (s *service) Create(ctx context.Context, userName, firstName, lastName, pas
 
 }
 
-func (s *service) Update(ctx context.Context, id string, firstname, lastname, email, phone, photo, language *string) error {
+// Update changes the fields that aren't nil, version is the version the
+// client read and 0 updates any version
+func (s *service) Update(ctx context.Context, id string, version int, firstname, lastname, email, phone, photo, language, timezone *string) error {
+
+	if email != nil {
+		if err := s.available(ctx, "", *email, id); err != nil {
+			return err
+		}
+	}
+
+	if phone != nil && *phone != "" {
+		p, err := ParsePhone(*phone)
+		if err != nil {
+			return err
+		}
+		phone = &p.Number
+	}
 
-	var lang *string
 	if language != nil {
-		switch domain.Language(*language) {
-		case domain.English, domain.Spanish:
-			lang = language
-		default:
-			l := string(domain.English)
-			lang = &l
+		tag, err := s.locales.Resolve(*language)
+		if err != nil {
+			return err
+		}
+		language = &tag
+	}
+
+	if timezone != nil && *timezone != "" {
+		if err := ValidTimezone(*timezone); err != nil {
+			return err
 		}
 	}
 
-	if err := s.repo.Update(ctx, id, firstname, lastname, email, phone, photo, lang, nil); err != nil {
+	if err := s.repo.Update(ctx, id, version, firstname, lastname, email, phone, photo, language, timezone, nil); err != nil {
 		return err
 	}
 
@@ -155,23 +337,92 @@ func (s *service) UpdatePassword(ctx context.Context, id, newPassword, oldPasswo
 	}
 
 	hashNewPassword := string(hashPassword)
-	if err := s.repo.Update(ctx, id, nil, nil, nil, nil, nil, nil, &hashNewPassword); err != nil {
+	if err := s.repo.Update(ctx, id, 0, nil, nil, nil, nil, nil, nil, nil, &hashNewPassword); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// GetVersion returns the version of the user, it's increased by every
+// update
+func (s *service) GetVersion(ctx context.Context, id string) (int, error) {
+	version, err := s.repo.GetVersion(ctx, id)
+	if err != nil {
+		s.logger.Warn(err)
+		return 0, NotFound
+	}
+
+	return version, nil
+}
+
 func (s *service) Delete(ctx context.Context, id string) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.logger.Info(fmt.Sprintf("Delete %s User", id))
+
+	return nil
+}
+
+// GetLocale returns the language tag and the timezone of the user, nil
+// for the users created before the locales
+func (s *service) GetLocale(ctx context.Context, id string) (*Locale, error) {
+	return s.repo.GetLocale(ctx, id)
+}
+
+// GetPhone returns the phone of the user, nil when it doesn't have one
+func (s *service) GetPhone(ctx context.Context, id string) (*Phone, error) {
+	return s.repo.GetPhone(ctx, id)
+}
+
+// VerifyPhone marks the number as verified, it fails when the user changed
+// the phone after the code was sent
+func (s *service) VerifyPhone(ctx context.Context, id, number string) error {
+	if err := s.repo.VerifyPhone(ctx, id, number); err != nil {
+		return err
+	}
+
+	s.logger.Info(fmt.Sprintf("Verify phone of %s User", id))
 	return nil
 }
 
-func (s *service) Login(ctx context.Context, user *domain.User, password string) (string, error) {
+// GetByLogin returns the human user with the username or the email
+func (s *service) GetByLogin(ctx context.Context, login string) (*domain.User, error) {
+	user, err := s.repo.GetByLogin(ctx, Normalize(login))
+	if err != nil {
+		s.logger.Warn(err)
+		return nil, NotFound
+	}
+
+	return user, nil
+}
+
+// Login accepts the username or the email, an unknown user is checked
+// against a dummy hash so it takes as long as a wrong password
+func (s *service) Login(ctx context.Context, login, password string) (*domain.User, string, error) {
+	user, err := s.GetByLogin(ctx, login)
+	if err != nil {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, "", InvalidAuthentication
+	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		s.logger.Error(err)
-		return "", InvalidAuthentication
+		s.logger.Warn(err)
+		return nil, "", InvalidAuthentication
 	}
 
+	token, err := s.IssueToken(ctx, user)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return user, token, nil
+}
+
+// IssueToken creates the session token for an already authenticated user,
+// it's shared by every login method.
+func (s *service) IssueToken(ctx context.Context, user *domain.User) (string, error) {
 	token, err := s.auth.Create(user.ID, user.UserName, "", true, 0)
 	if err != nil {
 		s.logger.Error(err)
@@ -180,23 +431,186 @@ func (s *service) Login(ctx context.Context, user *domain.User, password string)
 	return token, nil
 }
 
-func (s *service) TokenAccess(ctx context.Context, id, token string) (*domain.User, error) {
+// IssueImpersonationToken creates a short-lived token of the user that
+// carries the impersonation session and the admin behind it
+func (s *service) IssueImpersonationToken(ctx context.Context, user *domain.User, actorID, sessionID string, duration time.Duration) (string, error) {
+	hash := fmt.Sprintf("%s%s:%s", impersonationPrefix, sessionID, actorID)
+	token, err := s.auth.Create(user.ID, user.UserName, hash, true, int64(duration.Seconds()))
+	if err != nil {
+		s.logger.Error(err)
+		return "", err
+	}
+	return token, nil
+}
 
-	if err := s.auth.Access(id, token); err != nil {
-		return nil, err
+// CreateServiceAccount creates a user without password, email or language,
+// it returns the client secret that is shown only once
+func (s *service) CreateServiceAccount(ctx context.Context, name, description, createdBy string) (*domain.User, string, error) {
+	clientID, err := randomHex(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	hashSecret, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, "", err
+	}
+
+	if err := s.available(ctx, name, ""); err != nil {
+		return nil, "", err
+	}
+
+	user := domain.User{
+		UserName:     name,
+		FirstName:    name,
+		ClientID:     serviceAccountPrefix + clientID,
+		ClientSecret: string(hashSecret),
+	}
+
+	account := ServiceAccount{
+		Description: description,
+		CreatedBy:   createdBy,
+	}
+
+	if err := s.repo.CreateServiceAccount(ctx, &user, &account); err != nil {
+		return nil, "", err
+	}
+	s.logger.Info(fmt.Sprintf("Create %s Service Account by %s", user.ID, createdBy))
+
+	return &user, secret, nil
+}
+
+// ClientCredentials authenticates a service account and issues its token
+func (s *service) ClientCredentials(ctx context.Context, clientID, clientSecret string) (*domain.User, string, error) {
+	user, err := s.repo.GetServiceAccount(ctx, clientID)
+	if err != nil {
+		s.logger.Warn(err)
+		return nil, "", InvalidAuthentication
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.ClientSecret), []byte(clientSecret)); err != nil {
+		return nil, "", InvalidAuthentication
+	}
+
+	token, err := s.IssueToken(ctx, user)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return user, token, nil
+}
+
+// Authorize checks that the token belongs to the user with the given id,
+// it's used by the account management endpoints so impersonation tokens
+// aren't accepted.
+func (s *service) Authorize(ctx context.Context, token, id, scope string) (*domain.User, error) {
+	if token == "" {
+		return nil, InvalidAuthentication
+	}
+
+	info, err := s.CheckToken(ctx, token)
+	if err != nil {
+		return nil, InvalidAuthentication
+	}
+
+	if info.UserID != id {
+		return nil, ErrForbidden
+	}
+
+	if info.ActorID != "" {
+		return nil, ErrImpersonationNotAllowed
+	}
+
+	if !info.Allows(scope) {
+		return nil, ErrForbidden
+	}
+
+	return s.Get(ctx, id, "")
+}
+
+func (s *service) TokenAccess(ctx context.Context, id, token string) (*domain.User, *TokenInfo, error) {
+
+	info, err := s.CheckToken(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if info.UserID != id {
+		return nil, nil, authentication.ErrInvalidAuthentication
 	}
 
 	user, err := s.repo.Get(ctx, id)
 	if err != nil {
 		s.logger.Warn(err)
-		return nil, NotFound
+		return nil, nil, NotFound
 	}
 
-	s.logger.Info(fmt.Sprintf("Get %s User with token %s", id, token))
+	s.logger.Info(fmt.Sprintf("Get %s User with token access", id))
 
-	return user, nil
+	return user, info, nil
 
 }
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Allows tells if the token has the scope, a session token has every
+// scope and the write scopes include the read ones
+func (i *TokenInfo) Allows(scope string) bool {
+	if i.Scopes == nil {
+		return true
+	}
+
+	write := strings.TrimSuffix(scope, ":read") + ":write"
+	for _, s := range i.Scopes {
+		if s == scope || s == write {
+			return true
+		}
+	}
+	return false
+}
+
 func (s service) Count(ctx context.Context, filters Filters) (int, error) {
 	return s.repo.Count(ctx, filters)
 }
+
+// available checks that no other user has the username or the email, the
+// unique indexes still protect concurrent creations
+func (s *service) available(ctx context.Context, userName, email string, exclude ...string) error {
+	checks := []struct {
+		field   string
+		filters Filters
+	}{
+		{"username", Filters{UserName: userName}},
+		{"email", Filters{Email: email}},
+	}
+
+	for _, c := range checks {
+		if c.filters.UserName == "" && c.filters.Email == "" {
+			continue
+		}
+
+		users, err := s.repo.GetAll(ctx, c.filters, 0, 0)
+		if err != nil {
+			return err
+		}
+
+		for _, u := range users {
+			if len(exclude) == 0 || u.ID != exclude[0] {
+				return ErrUserAlreadyExists{c.field}
+			}
+		}
+	}
+
+	return nil
+}
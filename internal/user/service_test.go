@@ -0,0 +1,266 @@
+package user_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/internal/user/usertest"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+)
+
+func newService(t *testing.T) (user.Service, *usertest.Repository, *usertest.Auth) {
+	t.Helper()
+
+	locales, err := user.NewLocales()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, auth := usertest.NewRepository(), usertest.NewAuth()
+	return user.NewService(repo, auth, nil, nil, locales, loghub.New()), repo, auth
+}
+
+func TestServiceCreate(t *testing.T) {
+	ctx := context.Background()
+	srv, repo, _ := newService(t)
+
+	u, err := srv.Create(ctx, " John ", "John", "Doe", "secret-password", "john@example.com", "+1 415 555 0100", "", "", "", "es-AR", "America/Argentina/Buenos_Aires")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if u.UserName != "John" || u.Phone != "+14155550100" || u.Language != "es" {
+		t.Errorf("create returned %+v", u)
+	}
+
+	if u.Password == "secret-password" {
+		t.Error("the password is stored in plain text")
+	}
+
+	locale, _ := repo.GetLocale(ctx, u.ID)
+	if locale == nil || locale.Language != "es" || locale.Timezone != "America/Argentina/Buenos_Aires" {
+		t.Errorf("the locale is %+v", locale)
+	}
+
+	cases := []struct {
+		name                                   string
+		userName, email, phone, lang, timezone string
+		want                                   error
+	}{
+		{"taken username", "JOHN", "other@example.com", "", "", "", user.ErrUserAlreadyExists{Field: "username"}},
+		{"taken email", "other", "John@Example.com", "", "", "", user.ErrUserAlreadyExists{Field: "email"}},
+		{"username with @", "victim@example.com", "other@example.com", "", "", "", user.ErrInvalidUserName{UserName: "victim@example.com"}},
+		{"invalid phone", "other", "other@example.com", "12", "", "", user.ErrInvalidPhone{Phone: "12"}},
+		{"unsupported language", "other", "other@example.com", "", "xx", "", user.ErrUnsupportedLanguage{Language: "xx"}},
+		{"invalid timezone", "other", "other@example.com", "", "", "Mars/Olympus", user.ErrInvalidTimezone{Timezone: "Mars/Olympus"}},
+	}
+
+	for _, c := range cases {
+		_, err := srv.Create(ctx, c.userName, "Other", "Doe", "secret-password", c.email, c.phone, "", "", "", c.lang, c.timezone)
+		if err == nil || err.Error() != c.want.Error() {
+			t.Errorf("%s: want %v, got %v", c.name, c.want, err)
+		}
+	}
+}
+
+func TestServiceLogin(t *testing.T) {
+	ctx := context.Background()
+	srv, _, auth := newService(t)
+
+	u, err := srv.Create(ctx, "john", "John", "Doe", "secret-password", "john@example.com", "", "", "", "", "", "")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	for _, login := range []string{"john", "JOHN", "John@Example.com"} {
+		got, token, err := srv.Login(ctx, login, "secret-password")
+		if err != nil {
+			t.Fatalf("login %s: %v", login, err)
+		}
+
+		claims, err := auth.Check(token)
+		if err != nil || got.ID != u.ID || claims.ID != u.ID {
+			t.Errorf("login %s returned %v with the claims %+v, %v", login, got.ID, claims, err)
+		}
+	}
+
+	for _, c := range []struct{ login, password string }{
+		{"john", "wrong-password"},
+		{"nobody", "secret-password"},
+	} {
+		if _, _, err := srv.Login(ctx, c.login, c.password); err != user.InvalidAuthentication {
+			t.Errorf("login %s/%s: want InvalidAuthentication, got %v", c.login, c.password, err)
+		}
+	}
+}
+
+func TestServiceUpdatePassword(t *testing.T) {
+	ctx := context.Background()
+	srv, _, _ := newService(t)
+
+	u, err := srv.Create(ctx, "john", "John", "Doe", "secret-password", "john@example.com", "", "", "", "", "", "")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if err := srv.UpdatePassword(ctx, u.ID, "new-password", "wrong-password"); err != user.InvalidPassword {
+		t.Errorf("wrong old password: want InvalidPassword, got %v", err)
+	}
+
+	if err := srv.UpdatePassword(ctx, "unknown", "new-password", "secret-password"); !errors.As(err, &user.ErrNotFound{}) {
+		t.Errorf("unknown user: want ErrNotFound, got %v", err)
+	}
+
+	if err := srv.UpdatePassword(ctx, u.ID, "new-password", "secret-password"); err != nil {
+		t.Fatalf("update password: %v", err)
+	}
+
+	if _, _, err := srv.Login(ctx, "john", "secret-password"); err != user.InvalidAuthentication {
+		t.Errorf("login with the old password: want InvalidAuthentication, got %v", err)
+	}
+
+	if _, _, err := srv.Login(ctx, "john", "new-password"); err != nil {
+		t.Errorf("login with the new password: %v", err)
+	}
+}
+
+func TestServiceUpdate(t *testing.T) {
+	ctx := context.Background()
+	srv, _, _ := newService(t)
+
+	john, err := srv.Create(ctx, "john", "John", "Doe", "secret-password", "john@example.com", "", "", "", "", "", "")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if _, err := srv.Create(ctx, "jane", "Jane", "Doe", "secret-password", "jane@example.com", "", "", "", "", "", ""); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	email := "JANE@example.com"
+	if err := srv.Update(ctx, john.ID, 0, nil, nil, &email, nil, nil, nil, nil); !errors.As(err, &user.ErrUserAlreadyExists{}) {
+		t.Errorf("taken email: want ErrUserAlreadyExists, got %v", err)
+	}
+
+	name := "Johnny"
+	if err := srv.Update(ctx, "unknown", 0, &name, nil, nil, nil, nil, nil, nil); !errors.As(err, &user.ErrNotFound{}) {
+		t.Errorf("unknown user: want ErrNotFound, got %v", err)
+	}
+
+	version, err := srv.GetVersion(ctx, john.ID)
+	if err != nil {
+		t.Fatalf("get version: %v", err)
+	}
+
+	language := "es"
+	if err := srv.Update(ctx, john.ID, version, &name, nil, nil, nil, nil, &language, nil); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	if err := srv.Update(ctx, john.ID, version, &name, nil, nil, nil, nil, nil, nil); !errors.As(err, &user.ErrVersionConflict{}) {
+		t.Errorf("update an old version: want ErrVersionConflict, got %v", err)
+	}
+
+	got, err := srv.Get(ctx, john.ID, "")
+	if err != nil || got.FirstName != "Johnny" || got.Language != "es" {
+		t.Errorf("get after update returned %+v, %v", got, err)
+	}
+}
+
+func TestServiceAuthorize(t *testing.T) {
+	ctx := context.Background()
+	srv, _, auth := newService(t)
+
+	john, err := srv.Create(ctx, "john", "John", "Doe", "secret-password", "john@example.com", "", "", "", "", "", "")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	jane, err := srv.Create(ctx, "jane", "Jane", "Doe", "secret-password", "jane@example.com", "", "", "", "", "", "")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	token, err := srv.IssueToken(ctx, john)
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+
+	// an impersonation token is rejected when impersonation is disabled
+	impersonation, _ := auth.Create(john.ID, john.UserName, "imp:session:"+jane.ID, true, 0)
+
+	cases := []struct {
+		name  string
+		token string
+		id    string
+		want  error
+	}{
+		{"no token", "", john.ID, user.InvalidAuthentication},
+		{"unknown token", "unknown", john.ID, user.InvalidAuthentication},
+		{"another user", token, jane.ID, user.ErrForbidden},
+		{"impersonation", impersonation, john.ID, user.InvalidAuthentication},
+	}
+
+	for _, c := range cases {
+		if _, err := srv.Authorize(ctx, c.token, c.id, user.ScopeUsersWrite); err != c.want {
+			t.Errorf("%s: want %v, got %v", c.name, c.want, err)
+		}
+	}
+
+	got, err := srv.Authorize(ctx, token, john.ID, user.ScopeUsersWrite)
+	if err != nil || got.ID != john.ID {
+		t.Errorf("authorize returned %v, %v", got, err)
+	}
+}
+
+func TestServiceClientCredentials(t *testing.T) {
+	ctx := context.Background()
+	srv, _, auth := newService(t)
+
+	account, secret, err := srv.CreateServiceAccount(ctx, "billing", "the billing jobs", "owner-id")
+	if err != nil {
+		t.Fatalf("create service account: %v", err)
+	}
+
+	if _, _, err := srv.ClientCredentials(ctx, account.ClientID, "wrong-secret"); err != user.InvalidAuthentication {
+		t.Errorf("wrong secret: want InvalidAuthentication, got %v", err)
+	}
+
+	got, token, err := srv.ClientCredentials(ctx, account.ClientID, secret)
+	if err != nil {
+		t.Fatalf("client credentials: %v", err)
+	}
+
+	if claims, err := auth.Check(token); err != nil || claims.ID != got.ID {
+		t.Errorf("the token has the claims %+v, %v", claims, err)
+	}
+
+	if _, _, err := srv.Login(ctx, "billing", secret); err != user.InvalidAuthentication {
+		t.Errorf("service account login: want InvalidAuthentication, got %v", err)
+	}
+}
+
+func TestTokenInfoAllows(t *testing.T) {
+	cases := []struct {
+		name   string
+		scopes []string
+		scope  string
+		want   bool
+	}{
+		{"session token", nil, user.ScopeUsersWrite, true},
+		{"same scope", []string{user.ScopeUsersRead}, user.ScopeUsersRead, true},
+		{"write includes read", []string{user.ScopeUsersWrite}, user.ScopeUsersRead, true},
+		{"read excludes write", []string{user.ScopeUsersRead}, user.ScopeUsersWrite, false},
+		{"another resource", []string{user.ScopeRolesWrite}, user.ScopeUsersRead, false},
+		{"no scopes", []string{}, user.ScopeUsersRead, false},
+	}
+
+	for _, c := range cases {
+		info := &user.TokenInfo{UserID: "id", Scopes: c.scopes}
+		if got := info.Allows(c.scope); got != c.want {
+			t.Errorf("%s: allows %s returned %v", c.name, c.scope, got)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package user
+
+import (
+	"time"
+)
+
+const (
+	KindHuman   = "human"
+	KindService = "service"
+)
+
+// ServiceAccount marks a user as a non-human identity, the user row keeps
+// the name and the client credentials but has no password, email or language
+type ServiceAccount struct {
+	UserID      string    `json:"user_id" gorm:"type:char(36);not null;primary_key"`
+	Description string    `json:"description" gorm:"type:varchar(255)"`
+	CreatedBy   string    `json:"created_by" gorm:"type:char(36);not null;index"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (ServiceAccount) TableName() string {
+	return "service_accounts"
+}
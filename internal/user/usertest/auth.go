@@ -0,0 +1,53 @@
+package usertest
+
+import (
+	"fmt"
+	authentication "github.com/ncostamagna/axul_auth/auth"
+	"sync"
+)
+
+// Auth is an authentication.Auth that keeps the issued tokens in memory,
+// the tokens are opaque and they never expire
+type Auth struct {
+	mu     sync.Mutex
+	claims map[string]authentication.UserClaims
+}
+
+// NewAuth is a fake authentication.Auth for the tests
+func NewAuth() *Auth {
+	return &Auth{claims: map[string]authentication.UserClaims{}}
+}
+
+func (a *Auth) Create(id, username, hash string, authorized bool, duration int64) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	token := fmt.Sprintf("token-%d-%s", len(a.claims)+1, id)
+	a.claims[token] = authentication.UserClaims{ID: id, UserName: username, Hash: hash, Authorized: authorized}
+	return token, nil
+}
+
+func (a *Auth) Access(id, token string) error {
+	claims, err := a.Check(token)
+	if err != nil {
+		return err
+	}
+
+	if claims.ID != id {
+		return authentication.ErrInvalidAuthentication
+	}
+
+	return nil
+}
+
+func (a *Auth) Check(token string) (*authentication.UserClaims, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	claims, ok := a.claims[token]
+	if !ok {
+		return nil, authentication.ErrInvalidAuthentication
+	}
+
+	return &claims, nil
+}
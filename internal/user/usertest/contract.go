@@ -0,0 +1,419 @@
+package usertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/cursor"
+	domain "github.com/ncostamagna/axul_domain/domain/user"
+	"testing"
+)
+
+// RepositoryContract is the behavior every user.Repository must have,
+// newRepo returns an empty repository for each case
+func RepositoryContract(t *testing.T, newRepo func(t *testing.T) user.Repository) {
+	ctx := context.Background()
+
+	t.Run("create and get", func(t *testing.T) {
+		repo := newRepo(t)
+
+		u := &domain.User{UserName: "john", FirstName: "John", LastName: "Doe", Email: "john@example.com", Language: "en", Phone: "+14155550100"}
+		if err := repo.Create(ctx, u, &user.Locale{Language: "en-US", Timezone: "America/New_York"}); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+
+		if u.ID == "" {
+			t.Fatal("create didn't set the id")
+		}
+
+		got, err := repo.Get(ctx, u.ID)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+
+		if got.UserName != "john" || got.Email != "john@example.com" || got.FirstName != "John" {
+			t.Errorf("get returned %+v", got)
+		}
+
+		locale, err := repo.GetLocale(ctx, u.ID)
+		if err != nil || locale == nil || locale.Language != "en-US" || locale.Timezone != "America/New_York" {
+			t.Errorf("get locale returned %+v, %v", locale, err)
+		}
+
+		phone, err := repo.GetPhone(ctx, u.ID)
+		if err != nil || phone == nil || phone.Number != "+14155550100" || phone.Country != "US" {
+			t.Errorf("get phone returned %+v, %v", phone, err)
+		}
+
+		if _, err := repo.Get(ctx, "00000000-0000-0000-0000-000000000000"); err == nil {
+			t.Error("get of an unknown id didn't fail")
+		}
+	})
+
+	t.Run("unique username and email", func(t *testing.T) {
+		repo := newRepo(t)
+		create(t, repo, "john", "john@example.com")
+
+		for _, u := range []*domain.User{
+			{UserName: "JOHN", Email: "other@example.com", Language: "en"},
+			{UserName: "other", Email: "John@Example.com", Language: "en"},
+		} {
+			err := repo.Create(ctx, u, nil)
+			if !errors.As(err, &user.ErrUserAlreadyExists{}) {
+				t.Errorf("create %s <%s>: want ErrUserAlreadyExists, got %v", u.UserName, u.Email, err)
+			}
+		}
+
+		// the logins are compared case folded, not with lower()
+		create(t, repo, "Straße", "JOSÉ@example.com")
+		for _, u := range []*domain.User{
+			{UserName: "STRASSE", Email: "other@example.com", Language: "en"},
+			{UserName: "other", Email: "jose\u0301@example.com", Language: "en"},
+		} {
+			err := repo.Create(ctx, u, nil)
+			if !errors.As(err, &user.ErrUserAlreadyExists{}) {
+				t.Errorf("create %s <%s>: want ErrUserAlreadyExists, got %v", u.UserName, u.Email, err)
+			}
+		}
+
+		// service accounts don't have email
+		create(t, repo, "svc-1", "")
+		create(t, repo, "svc-2", "")
+	})
+
+	t.Run("update", func(t *testing.T) {
+		repo := newRepo(t)
+		u := create(t, repo, "john", "john@example.com")
+
+		firstName, phone, language, timezone := "Johnny", "+14155550101", "es-AR", "America/Argentina/Buenos_Aires"
+		if err := repo.Update(ctx, u.ID, 0, &firstName, nil, nil, &phone, nil, &language, &timezone, nil); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+
+		got, err := repo.Get(ctx, u.ID)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+
+		if got.FirstName != "Johnny" || got.LastName != "Doe" || got.Phone != phone || got.Language != "es" {
+			t.Errorf("get after update returned %+v", got)
+		}
+
+		locale, err := repo.GetLocale(ctx, u.ID)
+		if err != nil || locale == nil || locale.Language != language || locale.Timezone != timezone {
+			t.Errorf("get locale returned %+v, %v", locale, err)
+		}
+
+		if err := repo.VerifyPhone(ctx, u.ID, "+14155550100"); !errors.Is(err, user.ErrPhoneChanged) {
+			t.Errorf("verify an old phone: want ErrPhoneChanged, got %v", err)
+		}
+
+		if err := repo.VerifyPhone(ctx, u.ID, phone); err != nil {
+			t.Errorf("verify phone: %v", err)
+		}
+	})
+
+	t.Run("update not found", func(t *testing.T) {
+		repo := newRepo(t)
+
+		name := "John"
+		err := repo.Update(ctx, "00000000-0000-0000-0000-000000000000", 0, &name, nil, nil, nil, nil, nil, nil, nil)
+		if !errors.As(err, &user.ErrNotFound{}) {
+			t.Errorf("want ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("update with a taken email", func(t *testing.T) {
+		repo := newRepo(t)
+		create(t, repo, "john", "john@example.com")
+		jane := create(t, repo, "jane", "jane@example.com")
+
+		email := "JOHN@example.com"
+		err := repo.Update(ctx, jane.ID, 0, nil, nil, &email, nil, nil, nil, nil, nil)
+		if !errors.As(err, &user.ErrUserAlreadyExists{}) {
+			t.Errorf("want ErrUserAlreadyExists, got %v", err)
+		}
+	})
+
+	t.Run("version", func(t *testing.T) {
+		repo := newRepo(t)
+		u := create(t, repo, "john", "john@example.com")
+
+		if version, err := repo.GetVersion(ctx, u.ID); err != nil || version != 1 {
+			t.Fatalf("get version of a new user returned %d, %v, want 1", version, err)
+		}
+
+		name := "Johnny"
+		if err := repo.Update(ctx, u.ID, 1, &name, nil, nil, nil, nil, nil, nil, nil); err != nil {
+			t.Fatalf("update version 1: %v", err)
+		}
+
+		// every update increases the version, the ones without version too
+		password := "hash"
+		if err := repo.Update(ctx, u.ID, 0, nil, nil, nil, nil, nil, nil, nil, &password); err != nil {
+			t.Fatalf("update without version: %v", err)
+		}
+
+		if version, err := repo.GetVersion(ctx, u.ID); err != nil || version != 3 {
+			t.Errorf("get version after two updates returned %d, %v, want 3", version, err)
+		}
+
+		name = "Jack"
+		if err := repo.Update(ctx, u.ID, 2, &name, nil, nil, nil, nil, nil, nil, nil); !errors.As(err, &user.ErrVersionConflict{}) {
+			t.Errorf("update an old version: want ErrVersionConflict, got %v", err)
+		}
+
+		if got, _ := repo.Get(ctx, u.ID); got == nil || got.FirstName != "Johnny" {
+			t.Errorf("the update of an old version changed the user: %+v", got)
+		}
+
+		if err := repo.Update(ctx, "00000000-0000-0000-0000-000000000000", 1, &name, nil, nil, nil, nil, nil, nil, nil); !errors.As(err, &user.ErrNotFound{}) {
+			t.Errorf("update an unknown user with version: want ErrNotFound, got %v", err)
+		}
+
+		if _, err := repo.GetVersion(ctx, "00000000-0000-0000-0000-000000000000"); err == nil {
+			t.Error("get version of an unknown id didn't fail")
+		}
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		repo := newRepo(t)
+		john := create(t, repo, "john", "john@example.com")
+		jane := create(t, repo, "jane", "jane@example.com")
+
+		svc := &domain.User{UserName: "billing", FirstName: "billing", ClientID: "sa_billing", Language: "en"}
+		if err := repo.CreateServiceAccount(ctx, svc, &user.ServiceAccount{CreatedBy: john.ID}); err != nil {
+			t.Fatalf("create service account: %v", err)
+		}
+
+		cases := []struct {
+			name    string
+			filters user.Filters
+			want    []string
+		}{
+			{"none", user.Filters{}, []string{john.ID, jane.ID, svc.ID}},
+			{"ids", user.Filters{ID: []string{john.ID, svc.ID}}, []string{john.ID, svc.ID}},
+			{"username without case", user.Filters{UserName: " JOHN "}, []string{john.ID}},
+			{"email without case", user.Filters{Email: "Jane@Example.com"}, []string{jane.ID}},
+			{"humans", user.Filters{Kind: user.KindHuman}, []string{john.ID, jane.ID}},
+			{"service accounts", user.Filters{Kind: user.KindService}, []string{svc.ID}},
+			{"created by", user.Filters{CreatedBy: john.ID}, []string{svc.ID}},
+			{"created by another user", user.Filters{CreatedBy: jane.ID}, nil},
+			{"no match", user.Filters{UserName: "nobody"}, nil},
+		}
+
+		for _, c := range cases {
+			users, err := repo.GetAll(ctx, c.filters, 0, 0)
+			if err != nil {
+				t.Fatalf("%s: get all: %v", c.name, err)
+			}
+
+			if !sameIDs(users, c.want) {
+				t.Errorf("%s: got %v, want %v", c.name, ids(users), c.want)
+			}
+
+			count, err := repo.Count(ctx, c.filters)
+			if err != nil || count != len(c.want) {
+				t.Errorf("%s: count returned %d, %v, want %d", c.name, count, err, len(c.want))
+			}
+		}
+	})
+
+	t.Run("login", func(t *testing.T) {
+		repo := newRepo(t)
+		john := create(t, repo, "john", "john@example.com")
+
+		svc := &domain.User{UserName: "billing", ClientID: "sa_billing", Language: "en"}
+		if err := repo.CreateServiceAccount(ctx, svc, &user.ServiceAccount{CreatedBy: john.ID}); err != nil {
+			t.Fatalf("create service account: %v", err)
+		}
+
+		for _, login := range []string{"john", "john@example.com"} {
+			got, err := repo.GetByLogin(ctx, login)
+			if err != nil || got.ID != john.ID {
+				t.Errorf("get by login %s returned %v, %v", login, got, err)
+			}
+		}
+
+		jose := create(t, repo, "Straße", "JOSÉ@example.com")
+		for _, login := range []string{"Straße", "STRASSE", "josé@example.com", "JOSE\u0301@EXAMPLE.COM"} {
+			got, err := repo.GetByLogin(ctx, user.Normalize(login))
+			if err != nil || got.ID != jose.ID {
+				t.Errorf("get by login %s returned %v, %v", login, got, err)
+			}
+		}
+
+		if _, err := repo.GetByLogin(ctx, "billing"); err == nil {
+			t.Error("a service account can log in with its name")
+		}
+
+		got, err := repo.GetServiceAccount(ctx, "sa_billing")
+		if err != nil || got.ID != svc.ID {
+			t.Errorf("get service account returned %v, %v", got, err)
+		}
+
+		if _, err := repo.GetServiceAccount(ctx, "sa_unknown"); err == nil {
+			t.Error("get service account of an unknown client didn't fail")
+		}
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		repo := newRepo(t)
+
+		var all []string
+		for i := 0; i < 5; i++ {
+			u := create(t, repo, fmt.Sprintf("user-%d", i), fmt.Sprintf("user-%d@example.com", i))
+			all = append(all, u.ID)
+		}
+
+		var pages []domain.User
+		for offset := 0; offset < 6; offset += 2 {
+			page, err := repo.GetAll(ctx, user.Filters{}, offset, 2)
+			if err != nil {
+				t.Fatalf("get all: %v", err)
+			}
+
+			if want := min(2, 5-offset); len(page) != want {
+				t.Errorf("page at %d has %d users, want %d", offset, len(page), want)
+			}
+			pages = append(pages, page...)
+		}
+
+		if !sameIDs(pages, all) {
+			t.Errorf("pages returned %v, want every user once %v", ids(pages), all)
+		}
+
+		first, err := repo.GetAll(ctx, user.Filters{}, 0, 0)
+		if err != nil {
+			t.Fatalf("get all: %v", err)
+		}
+
+		for i := range first {
+			if first[i].ID != pages[i].ID {
+				t.Fatalf("the pages aren't in the order of the whole list: %v and %v", ids(pages), ids(first))
+			}
+		}
+	})
+
+	// the cursors go from the newest user to the oldest, a user created
+	// while the client scrolls doesn't move the next pages
+	t.Run("cursor pagination", func(t *testing.T) {
+		repo := newRepo(t)
+
+		var all []string
+		for i := 0; i < 5; i++ {
+			u := create(t, repo, fmt.Sprintf("user-%d", i), fmt.Sprintf("user-%d@example.com", i))
+			all = append([]string{u.ID}, all...)
+		}
+
+		var pages []domain.User
+		var last *cursor.Meta
+		for page := (cursor.Page{Limit: 2}); ; {
+			rows, err := repo.GetPage(ctx, user.Filters{}, page)
+			if err != nil {
+				t.Fatalf("get page: %v", err)
+			}
+
+			users, meta := cursor.New(rows, page, user.Position)
+			if page.Cursor == nil {
+				create(t, repo, "late", "late@example.com")
+			}
+
+			pages, last = append(pages, users...), meta
+			if meta.Next == "" {
+				break
+			}
+			page.Cursor, _ = cursor.Parse(meta.Next)
+		}
+
+		if got := ids(pages); fmt.Sprint(got) != fmt.Sprint(all) {
+			t.Errorf("the next cursors returned %v, want %v", got, all)
+		}
+
+		var back []domain.User
+		for prev := last.Prev; prev != ""; {
+			c, _ := cursor.Parse(prev)
+			page := cursor.Page{Cursor: c, Limit: 2}
+
+			rows, err := repo.GetPage(ctx, user.Filters{}, page)
+			if err != nil {
+				t.Fatalf("get page: %v", err)
+			}
+
+			users, meta := cursor.New(rows, page, user.Position)
+			back, prev = append(users, back...), meta.Prev
+		}
+
+		first, err := repo.GetAll(ctx, user.Filters{}, 0, 0)
+		if err != nil {
+			t.Fatalf("get all: %v", err)
+		}
+
+		// the last page isn't read again, the late user is the newest
+		if got, want := ids(back), ids(first[:len(first)-1]); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("the prev cursors returned %v, want %v", got, want)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		repo := newRepo(t)
+		john := create(t, repo, "john", "john@example.com")
+		create(t, repo, "jane", "jane@example.com")
+
+		if err := repo.Delete(ctx, john.ID); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+
+		if _, err := repo.Get(ctx, john.ID); err == nil {
+			t.Error("get of a deleted user didn't fail")
+		}
+
+		if count, _ := repo.Count(ctx, user.Filters{}); count != 1 {
+			t.Errorf("count after delete returned %d, want 1", count)
+		}
+
+		if err := repo.Delete(ctx, john.ID); !errors.As(err, &user.ErrNotFound{}) {
+			t.Errorf("delete twice: want ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func create(t *testing.T, repo user.Repository, userName, email string) *domain.User {
+	t.Helper()
+
+	u := &domain.User{UserName: userName, FirstName: "John", LastName: "Doe", Email: email, Language: "en"}
+	if err := repo.Create(context.Background(), u, nil); err != nil {
+		t.Fatalf("create %s: %v", userName, err)
+	}
+
+	return u
+}
+
+func ids(users []domain.User) []string {
+	ids := make([]string, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+	return ids
+}
+
+func sameIDs(users []domain.User, want []string) bool {
+	if len(users) != len(want) {
+		return false
+	}
+
+	seen := map[string]int{}
+	for _, id := range want {
+		seen[id]++
+	}
+
+	for _, u := range users {
+		if seen[u.ID] == 0 {
+			return false
+		}
+		seen[u.ID]--
+	}
+
+	return true
+}
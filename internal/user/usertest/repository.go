@@ -0,0 +1,369 @@
+package usertest
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/cursor"
+	domain "github.com/ncostamagna/axul_domain/domain/user"
+	"gorm.io/gorm"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrAttributesNotSupported is returned by the filters with attributes,
+// they are stored by the attribute package
+var ErrAttributesNotSupported = errors.New("usertest: attribute filters aren't supported")
+
+// Repository is a user.Repository in memory, it follows the same
+// contract as the database repository except the attribute filters
+type Repository struct {
+	mu       sync.Mutex
+	users    map[string]domain.User
+	services map[string]bool
+	owners   map[string]string
+	phones   map[string]user.Phone
+	locales  map[string]user.Locale
+	versions map[string]int
+}
+
+// NewRepository is a fake user.Repository for the tests
+func NewRepository() *Repository {
+	return &Repository{
+		users:    map[string]domain.User{},
+		services: map[string]bool{},
+		owners:   map[string]string{},
+		phones:   map[string]user.Phone{},
+		locales:  map[string]user.Locale{},
+		versions: map[string]int{},
+	}
+}
+
+func (r *Repository) GetAll(ctx context.Context, filters user.Filters, offset, limit int) ([]domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users, err := r.filter(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit > 0 {
+		if offset > len(users) {
+			offset = len(users)
+		}
+		users = users[offset:min(offset+limit, len(users))]
+	}
+
+	return users, nil
+}
+
+func (r *Repository) GetPage(ctx context.Context, filters user.Filters, page cursor.Page) ([]domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users, err := r.filter(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	return cursor.Slice(users, page, user.Position), nil
+}
+
+func (r *Repository) Get(ctx context.Context, id string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &u, nil
+}
+
+func (r *Repository) GetByLogin(ctx context.Context, login string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, match := range []func(u domain.User) bool{
+		func(u domain.User) bool { return user.Normalize(u.UserName) == login },
+		func(u domain.User) bool { return user.Normalize(u.Email) == login },
+	} {
+		for _, u := range r.sorted() {
+			if !r.services[u.ID] && match(u) {
+				return &u, nil
+			}
+		}
+	}
+
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *Repository) GetPhone(ctx context.Context, userID string) (*user.Phone, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.phones[userID]
+	if !ok {
+		return nil, nil
+	}
+
+	return &p, nil
+}
+
+func (r *Repository) GetLocale(ctx context.Context, userID string) (*user.Locale, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l, ok := r.locales[userID]
+	if !ok {
+		return nil, nil
+	}
+
+	return &l, nil
+}
+
+func (r *Repository) VerifyPhone(ctx context.Context, userID, number string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.phones[userID]
+	if !ok || p.Number != number {
+		return user.ErrPhoneChanged
+	}
+
+	now := time.Now()
+	p.VerifiedAt = &now
+	r.phones[userID] = p
+	return nil
+}
+
+func (r *Repository) Create(ctx context.Context, u *domain.User, locale *user.Locale) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.insert(u); err != nil {
+		return err
+	}
+
+	if err := r.setPhone(u.ID, u.Phone); err != nil {
+		return err
+	}
+
+	if locale != nil {
+		locale.UserID = u.ID
+		r.locales[u.ID] = *locale
+	}
+
+	return nil
+}
+
+func (r *Repository) CreateServiceAccount(ctx context.Context, u *domain.User, account *user.ServiceAccount) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.insert(u); err != nil {
+		return err
+	}
+
+	account.UserID = u.ID
+	r.services[u.ID] = true
+	r.owners[u.ID] = account.CreatedBy
+	return nil
+}
+
+func (r *Repository) GetServiceAccount(ctx context.Context, clientID string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range r.users {
+		if r.services[u.ID] && u.ClientID == clientID {
+			return &u, nil
+		}
+	}
+
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *Repository) Update(ctx context.Context, id string, version int, firstname, lastname, email, phone, photo, language, timezone, password *string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return user.ErrNotFound{UserID: id}
+	}
+
+	if version > 0 && r.versions[id] != version {
+		return user.ErrVersionConflict{UserID: id}
+	}
+
+	if email != nil && r.taken(id, "", *email) {
+		return user.ErrUserAlreadyExists{}
+	}
+
+	set := func(field *string, value *string) {
+		if value != nil {
+			*field = *value
+		}
+	}
+
+	set(&u.FirstName, firstname)
+	set(&u.LastName, lastname)
+	set(&u.Email, email)
+	set(&u.Phone, phone)
+	set(&u.Photo, photo)
+	set(&u.Password, password)
+	if language != nil {
+		u.Language = domain.Language(user.Base(*language))
+	}
+
+	if phone != nil {
+		if err := r.setPhone(id, *phone); err != nil {
+			return err
+		}
+	}
+
+	if language != nil || timezone != nil {
+		locale := r.locales[id]
+		locale.UserID = id
+		set(&locale.Language, language)
+		set(&locale.Timezone, timezone)
+		r.locales[id] = locale
+	}
+
+	u.UpdatedAt = time.Now()
+	r.users[id] = u
+	r.versions[id]++
+	return nil
+}
+
+func (r *Repository) GetVersion(ctx context.Context, id string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return 0, gorm.ErrRecordNotFound
+	}
+
+	return r.versions[id], nil
+}
+
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return user.ErrNotFound{UserID: id}
+	}
+
+	delete(r.users, id)
+	return nil
+}
+
+func (r *Repository) Count(ctx context.Context, filters user.Filters) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users, err := r.filter(filters)
+	return len(users), err
+}
+
+func (r *Repository) insert(u *domain.User) error {
+	if r.taken("", u.UserName, u.Email) {
+		return user.ErrUserAlreadyExists{}
+	}
+
+	now := time.Now()
+	u.ID = uuid.New().String()
+	u.CreatedAt, u.UpdatedAt = now, now
+	r.users[u.ID] = *u
+	r.versions[u.ID] = 1
+	return nil
+}
+
+// taken follows the unique indexes of the database, the username and the
+// non empty email are unique without case
+func (r *Repository) taken(exclude, userName, email string) bool {
+	for _, u := range r.users {
+		if u.ID == exclude {
+			continue
+		}
+
+		if userName != "" && user.Normalize(u.UserName) == user.Normalize(userName) {
+			return true
+		}
+
+		if email != "" && user.Normalize(u.Email) == user.Normalize(email) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Repository) setPhone(userID, number string) error {
+	if p, ok := r.phones[userID]; ok && p.Number == number {
+		return nil
+	}
+	delete(r.phones, userID)
+
+	if number == "" {
+		return nil
+	}
+
+	p, err := user.ParsePhone(number)
+	if err != nil {
+		return err
+	}
+	p.UserID = userID
+	r.phones[userID] = *p
+	return nil
+}
+
+func (r *Repository) filter(f user.Filters) ([]domain.User, error) {
+	if len(f.Attributes) > 0 {
+		return nil, ErrAttributesNotSupported
+	}
+
+	ids := map[string]bool{}
+	for _, id := range f.ID {
+		ids[id] = true
+	}
+
+	users := []domain.User{}
+	for _, u := range r.sorted() {
+		switch {
+		case f.ID != nil && !ids[u.ID]:
+		case f.UserName != "" && user.Normalize(u.UserName) != user.Normalize(f.UserName):
+		case f.Email != "" && user.Normalize(u.Email) != user.Normalize(f.Email):
+		case f.Kind == user.KindHuman && r.services[u.ID]:
+		case f.Kind == user.KindService && !r.services[u.ID]:
+		case f.CreatedBy != "" && r.owners[u.ID] != f.CreatedBy:
+		default:
+			users = append(users, u)
+		}
+	}
+
+	return users, nil
+}
+
+// sorted returns the users like the database, newest first
+func (r *Repository) sorted() []domain.User {
+	users := make([]domain.User, 0, len(r.users))
+	for _, u := range r.users {
+		users = append(users, u)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if !users[i].CreatedAt.Equal(users[j].CreatedAt) {
+			return users[i].CreatedAt.After(users[j].CreatedAt)
+		}
+		return users[i].ID > users[j].ID
+	})
+
+	return users
+}
@@ -0,0 +1,28 @@
+package usertest
+
+import (
+	"context"
+	"github.com/ncostamagna/axul-user/internal/user"
+	domain "github.com/ncostamagna/axul_domain/domain/user"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"testing"
+)
+
+// NewService returns a user.Service over an in-memory repository and a fake
+// auth, with the user John Doe (john, john@example.com, +14155550100)
+func NewService(t testing.TB) (user.Service, *domain.User) {
+	t.Helper()
+
+	locales, err := user.NewLocales()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := user.NewService(NewRepository(), NewAuth(), nil, nil, locales, loghub.New())
+	u, err := srv.Create(context.Background(), "john", "John", "Doe", "secret-password", "john@example.com", "+14155550100", "", "", "", "en", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return srv, u
+}
@@ -0,0 +1,12 @@
+// Package migrations has the SQL migrations of the database, one
+// directory per dialect with the files <version>_<name>.up.sql and
+// <version>_<name>.down.sql
+package migrations
+
+import "embed"
+
+//go:embed mysql/*.sql postgres/*.sql sqlite/*.sql
+var FS embed.FS
+
+// Dialects are the directories of FS, every migration is in all of them
+var Dialects = []string{"mysql", "postgres", "sqlite"}
@@ -0,0 +1,136 @@
+package migrations_test
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/ncostamagna/axul-user/migrations"
+	"github.com/ncostamagna/axul-user/pkg/migrate"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// until returns the migrations of the dialect before the version
+func until(t *testing.T, dialect, version string) fs.FS {
+	t.Helper()
+
+	fsys, err := fs.Sub(migrations.FS, dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := fstest.MapFS{}
+	for _, f := range files {
+		if f.Name() >= version {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		before[f.Name()] = &fstest.MapFile{Data: data}
+	}
+
+	return before
+}
+
+func up(t *testing.T, db *gorm.DB, fsys fs.FS) {
+	t.Helper()
+
+	migrator, err := migrate.New(db, fsys, loghub.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// the users that only differ in case are renamed before the unique
+// indexes are created, the oldest one keeps the username and the email
+func TestUsersUniqueIndexes(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "users.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	up(t, db, until(t, "sqlite", "0003"))
+
+	now := time.Now()
+	users := []struct {
+		id, userName, email string
+		createdAt           time.Time
+	}{
+		{"bbbbbbbb-0000-0000-0000-000000000000", "JOHN", "John@Example.com", now},
+		{"aaaaaaaa-0000-0000-0000-000000000000", "john", "john@example.com", now.Add(-time.Hour)},
+		{"cccccccc-0000-0000-0000-000000000000", "jane", "", now},
+		{"dddddddd-0000-0000-0000-000000000000", "mary", "", now},
+	}
+	for _, u := range users {
+		if err := db.Exec("INSERT INTO users (id, user_name, email, language, created_at) VALUES (?, ?, ?, 'en', ?)", u.id, u.userName, u.email, u.createdAt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	up(t, db, until(t, "sqlite", "0004"))
+
+	var got []struct {
+		UserName string
+		Email    string
+	}
+	if err := db.Raw("SELECT user_name, email FROM users ORDER BY id").Scan(&got).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	want := "john john@example.com, JOHN-bbbbbbbb , jane , mary "
+	var rows []string
+	for _, u := range got {
+		rows = append(rows, u.UserName+" "+u.Email)
+	}
+	if strings.Join(rows, ", ") != want {
+		t.Errorf("the users are %q, want %q", strings.Join(rows, ", "), want)
+	}
+
+	if err := db.Exec("INSERT INTO users (id, user_name, email, language) VALUES ('eeeeeeee', 'John', '', 'en')").Error; err == nil {
+		t.Error("a username that only differs in case was created")
+	}
+}
+
+// every dialect has the same migrations, migrate create adds them to all
+func TestDialects(t *testing.T) {
+	var want []string
+	for _, dialect := range migrations.Dialects {
+		files, err := fs.Glob(migrations.FS, dialect+"/*.sql")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+		for _, f := range files {
+			names = append(names, strings.TrimPrefix(f, dialect+"/"))
+		}
+
+		if want == nil {
+			want = names
+			continue
+		}
+
+		if strings.Join(names, " ") != strings.Join(want, " ") {
+			t.Errorf("the %s migrations are %v, want %v", dialect, names, want)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS `user_attributes`;
+DROP TABLE IF EXISTS `phone_verifications`;
+DROP TABLE IF EXISTS `email_changes`;
+DROP TABLE IF EXISTS `impersonation_events`;
+DROP TABLE IF EXISTS `impersonation_sessions`;
+DROP TABLE IF EXISTS `access_tokens`;
+DROP TABLE IF EXISTS `passkey_sessions`;
+DROP TABLE IF EXISTS `passkeys`;
+DROP TABLE IF EXISTS `magic_challenges`;
+DROP TABLE IF EXISTS `identities`;
+DROP TABLE IF EXISTS `user_locales`;
+DROP TABLE IF EXISTS `user_phones`;
+DROP TABLE IF EXISTS `service_accounts`;
+DROP TABLE IF EXISTS `roles`;
+DROP TABLE IF EXISTS `users`;
@@ -0,0 +1,203 @@
+-- the tables of the service before the versioned migrations, they are
+-- created only when they don't exist so the databases created by
+-- DATABASE_MIGRATE keep their data
+
+CREATE TABLE IF NOT EXISTS `users` (
+    `id` char(36) NOT NULL,
+    `user_name` char(70) UNIQUE,
+    `first_name` char(30),
+    `last_name` char(30),
+    `password` longtext,
+    `language` char(3) NOT NULL,
+    `email` char(70),
+    `phone` char(30),
+    `photo` char(100),
+    `client_id` longtext,
+    `client_secret` longtext,
+    `token` longtext,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `roles` (
+    `id` char(36) NOT NULL,
+    `user_id` char(36) NOT NULL,
+    `app` char(36) NOT NULL,
+    `role` bigint unsigned,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_userid_app` (`user_id`,`app`),
+    CONSTRAINT `fk_users_roles` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `service_accounts` (
+    `user_id` char(36) NOT NULL,
+    `description` varchar(255),
+    `created_by` char(36) NOT NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`user_id`),
+    INDEX `idx_service_accounts_created_by` (`created_by`)
+);
+
+CREATE TABLE IF NOT EXISTS `user_phones` (
+    `user_id` char(36) NOT NULL,
+    `number` char(20) NOT NULL,
+    `country` char(2),
+    `calling_code` int,
+    `verified_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `user_locales` (
+    `user_id` char(36) NOT NULL,
+    `language` varchar(35),
+    `timezone` varchar(64),
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `identities` (
+    `id` char(36) NOT NULL,
+    `user_id` char(36) NOT NULL,
+    `provider` char(30) NOT NULL,
+    `subject` varchar(191) NOT NULL,
+    `email` char(70),
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_identities_user_id` (`user_id`),
+    UNIQUE INDEX `idx_provider_subject` (`provider`,`subject`)
+);
+
+CREATE TABLE IF NOT EXISTS `magic_challenges` (
+    `id` char(36) NOT NULL,
+    `user_id` char(36) NOT NULL,
+    `method` char(10) NOT NULL,
+    `secret_hash` char(64) NOT NULL,
+    `device_hash` char(64) NOT NULL,
+    `attempts` bigint NOT NULL DEFAULT 0,
+    `expires_at` datetime(3) NULL,
+    `used_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_magic_challenges_user_id` (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `passkeys` (
+    `id` char(36) NOT NULL,
+    `user_id` char(36) NOT NULL,
+    `name` char(70) NOT NULL,
+    `credential_id` varchar(255) NOT NULL,
+    `public_key` longblob NOT NULL,
+    `attestation_type` char(30),
+    `aa_guid` longblob,
+    `sign_count` int unsigned,
+    `transports` varchar(100),
+    `backup_eligible` boolean,
+    `backup_state` boolean,
+    `last_used_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_passkeys_credential_id` (`credential_id`),
+    INDEX `idx_passkeys_user_id` (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `passkey_sessions` (
+    `id` char(36) NOT NULL,
+    `user_id` char(36),
+    `kind` char(20) NOT NULL,
+    `data` text NOT NULL,
+    `expires_at` datetime(3) NOT NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `access_tokens` (
+    `id` char(36) NOT NULL,
+    `user_id` char(36) NOT NULL,
+    `name` char(70) NOT NULL,
+    `hint` char(20) NOT NULL,
+    `hash` char(64) NOT NULL,
+    `scopes` varchar(255) NOT NULL,
+    `expires_at` datetime(3) NULL,
+    `last_used_at` datetime(3) NULL,
+    `revoked_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_access_tokens_user_id` (`user_id`),
+    UNIQUE INDEX `idx_access_tokens_hash` (`hash`)
+);
+
+CREATE TABLE IF NOT EXISTS `impersonation_sessions` (
+    `id` char(36) NOT NULL,
+    `actor_id` char(36) NOT NULL,
+    `user_id` char(36) NOT NULL,
+    `reason` varchar(255) NOT NULL,
+    `expires_at` datetime(3) NULL,
+    `ended_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_impersonation_sessions_user_id` (`user_id`),
+    INDEX `idx_impersonation_sessions_actor_id` (`actor_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `impersonation_events` (
+    `id` char(36) NOT NULL,
+    `session_id` char(36) NOT NULL,
+    `type` char(10) NOT NULL,
+    `actor_id` char(36) NOT NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_impersonation_events_session_id` (`session_id`),
+    CONSTRAINT `fk_impersonation_sessions_events` FOREIGN KEY (`session_id`) REFERENCES `impersonation_sessions`(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `email_changes` (
+    `id` char(36) NOT NULL,
+    `user_id` char(36) NOT NULL,
+    `old_email` char(70),
+    `new_email` char(70) NOT NULL,
+    `confirm_hash` char(64) NOT NULL,
+    `cancel_hash` char(64) NOT NULL,
+    `expires_at` datetime(3) NULL,
+    `confirmed_at` datetime(3) NULL,
+    `canceled_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_email_changes_user_id` (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `phone_verifications` (
+    `id` char(36) NOT NULL,
+    `user_id` char(36) NOT NULL,
+    `number` char(20) NOT NULL,
+    `code_hash` char(64) NOT NULL,
+    `attempts` bigint NOT NULL DEFAULT 0,
+    `expires_at` datetime(3) NULL,
+    `used_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_phone_verifications_user_id` (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `user_attributes` (
+    `user_id` char(36) NOT NULL,
+    `app` char(36) NOT NULL,
+    `data` text NOT NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`user_id`,`app`)
+);
@@ -0,0 +1,5 @@
+-- the version of the users and the roles, it's increased by every update
+-- and the updates with an If-Match header only apply to the same version
+
+ALTER TABLE `users` ADD COLUMN `version` bigint unsigned NOT NULL DEFAULT 1;
+ALTER TABLE `roles` ADD COLUMN `version` bigint unsigned NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+-- the renamed duplicates keep their new username
+
+DROP INDEX `idx_users_email_lower` ON `users`;
+DROP INDEX `idx_users_user_name_lower` ON `users`;
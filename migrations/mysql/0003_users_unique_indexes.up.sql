@@ -0,0 +1,34 @@
+-- the case-insensitive unique indexes of the users, the tables created
+-- before the versioned migrations don't have them. The duplicates are
+-- renamed first, the oldest user keeps the username and the email, the
+-- others get the username with the start of their id and no email.
+-- mysql doesn't have CREATE INDEX IF NOT EXISTS, the indexes created by
+-- the older versions of the service are kept
+
+UPDATE `users` JOIN (
+    SELECT DISTINCT `a`.`id` FROM `users` AS `a` JOIN `users` AS `u`
+    ON lower(`u`.`user_name`) = lower(`a`.`user_name`)
+    AND (`u`.`created_at` < `a`.`created_at` OR (`u`.`created_at` = `a`.`created_at` AND `u`.`id` < `a`.`id`))
+) AS `dup` ON `dup`.`id` = `users`.`id`
+SET `users`.`user_name` = concat(left(`users`.`user_name`, 61), '-', left(`users`.`id`, 8));
+
+UPDATE `users` JOIN (
+    SELECT DISTINCT `a`.`id` FROM `users` AS `a` JOIN `users` AS `u`
+    ON lower(`u`.`email`) = lower(`a`.`email`) AND `a`.`email` <> ''
+    AND (`u`.`created_at` < `a`.`created_at` OR (`u`.`created_at` = `a`.`created_at` AND `u`.`id` < `a`.`id`))
+) AS `dup` ON `dup`.`id` = `users`.`id`
+SET `users`.`email` = '';
+
+SET @missing = (SELECT count(*) = 0 FROM information_schema.statistics
+    WHERE table_schema = database() AND table_name = 'users' AND index_name = 'idx_users_user_name_lower');
+SET @stmt = IF(@missing, 'CREATE UNIQUE INDEX `idx_users_user_name_lower` ON `users` ((lower(`user_name`)))', 'DO 0');
+PREPARE stmt FROM @stmt;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @missing = (SELECT count(*) = 0 FROM information_schema.statistics
+    WHERE table_schema = database() AND table_name = 'users' AND index_name = 'idx_users_email_lower');
+SET @stmt = IF(@missing, 'CREATE UNIQUE INDEX `idx_users_email_lower` ON `users` ((nullif(lower(`email`), '''')))', 'DO 0');
+PREPARE stmt FROM @stmt;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
@@ -0,0 +1,6 @@
+CREATE UNIQUE INDEX `idx_users_user_name_lower` ON `users` ((lower(`user_name`)));
+CREATE UNIQUE INDEX `idx_users_email_lower` ON `users` ((nullif(lower(`email`), '')));
+DROP INDEX `idx_users_email_norm` ON `users`;
+DROP INDEX `idx_users_user_name_norm` ON `users`;
+ALTER TABLE `users` DROP COLUMN `email_norm`;
+ALTER TABLE `users` DROP COLUMN `user_name_norm`;
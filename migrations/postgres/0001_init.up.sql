@@ -0,0 +1,206 @@
+-- the tables of the service before the versioned migrations, they are
+-- created only when they don't exist so the databases created by
+-- DATABASE_MIGRATE keep their data
+-- the char columns of the models are varchar, postgres pads the char
+-- values with spaces
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" varchar(36) NOT NULL,
+    "user_name" varchar(70),
+    "first_name" varchar(30),
+    "last_name" varchar(30),
+    "password" text,
+    "language" varchar(3) NOT NULL,
+    "email" varchar(70),
+    "phone" varchar(30),
+    "photo" varchar(100),
+    "client_id" text,
+    "client_secret" text,
+    "token" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_users_user_name" UNIQUE ("user_name")
+);
+
+CREATE TABLE IF NOT EXISTS "roles" (
+    "id" varchar(36) NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "app" varchar(36) NOT NULL,
+    "role" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_roles" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_userid_app" ON "roles" ("user_id","app");
+
+CREATE TABLE IF NOT EXISTS "service_accounts" (
+    "user_id" varchar(36) NOT NULL,
+    "description" varchar(255),
+    "created_by" varchar(36) NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("user_id")
+);
+CREATE INDEX IF NOT EXISTS "idx_service_accounts_created_by" ON "service_accounts" ("created_by");
+
+CREATE TABLE IF NOT EXISTS "user_phones" (
+    "user_id" varchar(36) NOT NULL,
+    "number" varchar(20) NOT NULL,
+    "country" varchar(2),
+    "calling_code" integer,
+    "verified_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("user_id")
+);
+
+CREATE TABLE IF NOT EXISTS "user_locales" (
+    "user_id" varchar(36) NOT NULL,
+    "language" varchar(35),
+    "timezone" varchar(64),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("user_id")
+);
+
+CREATE TABLE IF NOT EXISTS "identities" (
+    "id" varchar(36) NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "provider" varchar(30) NOT NULL,
+    "subject" varchar(191) NOT NULL,
+    "email" varchar(70),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_provider_subject" ON "identities" ("provider","subject");
+CREATE INDEX IF NOT EXISTS "idx_identities_user_id" ON "identities" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "magic_challenges" (
+    "id" varchar(36) NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "method" varchar(10) NOT NULL,
+    "secret_hash" varchar(64) NOT NULL,
+    "device_hash" varchar(64) NOT NULL,
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "expires_at" timestamptz,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_magic_challenges_user_id" ON "magic_challenges" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "passkeys" (
+    "id" varchar(36) NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "name" varchar(70) NOT NULL,
+    "credential_id" varchar(255) NOT NULL,
+    "public_key" bytea NOT NULL,
+    "attestation_type" varchar(30),
+    "aa_guid" bytea,
+    "sign_count" bigint,
+    "transports" varchar(100),
+    "backup_eligible" boolean,
+    "backup_state" boolean,
+    "last_used_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_passkeys_user_id" ON "passkeys" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_passkeys_credential_id" ON "passkeys" ("credential_id");
+
+CREATE TABLE IF NOT EXISTS "passkey_sessions" (
+    "id" varchar(36) NOT NULL,
+    "user_id" varchar(36),
+    "kind" varchar(20) NOT NULL,
+    "data" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "access_tokens" (
+    "id" varchar(36) NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "name" varchar(70) NOT NULL,
+    "hint" varchar(20) NOT NULL,
+    "hash" varchar(64) NOT NULL,
+    "scopes" varchar(255) NOT NULL,
+    "expires_at" timestamptz,
+    "last_used_at" timestamptz,
+    "revoked_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_access_tokens_hash" ON "access_tokens" ("hash");
+CREATE INDEX IF NOT EXISTS "idx_access_tokens_user_id" ON "access_tokens" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "impersonation_sessions" (
+    "id" varchar(36) NOT NULL,
+    "actor_id" varchar(36) NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "reason" varchar(255) NOT NULL,
+    "expires_at" timestamptz,
+    "ended_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_impersonation_sessions_user_id" ON "impersonation_sessions" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_impersonation_sessions_actor_id" ON "impersonation_sessions" ("actor_id");
+
+CREATE TABLE IF NOT EXISTS "impersonation_events" (
+    "id" varchar(36) NOT NULL,
+    "session_id" varchar(36) NOT NULL,
+    "type" varchar(10) NOT NULL,
+    "actor_id" varchar(36) NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_impersonation_sessions_events" FOREIGN KEY ("session_id") REFERENCES "impersonation_sessions"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_impersonation_events_session_id" ON "impersonation_events" ("session_id");
+
+CREATE TABLE IF NOT EXISTS "email_changes" (
+    "id" varchar(36) NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "old_email" varchar(70),
+    "new_email" varchar(70) NOT NULL,
+    "confirm_hash" varchar(64) NOT NULL,
+    "cancel_hash" varchar(64) NOT NULL,
+    "expires_at" timestamptz,
+    "confirmed_at" timestamptz,
+    "canceled_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_email_changes_user_id" ON "email_changes" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "phone_verifications" (
+    "id" varchar(36) NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "number" varchar(20) NOT NULL,
+    "code_hash" varchar(64) NOT NULL,
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "expires_at" timestamptz,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_phone_verifications_user_id" ON "phone_verifications" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "user_attributes" (
+    "user_id" varchar(36) NOT NULL,
+    "app" varchar(36) NOT NULL,
+    "data" text NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("user_id","app")
+);
@@ -0,0 +1,21 @@
+-- the case-insensitive unique indexes of the users, the tables created
+-- before the versioned migrations don't have them. The duplicates are
+-- renamed first, the oldest user keeps the username and the email, the
+-- others get the username with the start of their id and no email
+
+UPDATE "users" SET "user_name" = left("user_name", 61) || '-' || left("id", 8)
+WHERE EXISTS (
+    SELECT 1 FROM "users" AS "u"
+    WHERE lower("u"."user_name") = lower("users"."user_name")
+    AND ("u"."created_at" < "users"."created_at" OR ("u"."created_at" = "users"."created_at" AND "u"."id" < "users"."id"))
+);
+
+UPDATE "users" SET "email" = ''
+WHERE "email" <> '' AND EXISTS (
+    SELECT 1 FROM "users" AS "u"
+    WHERE lower("u"."email") = lower("users"."email")
+    AND ("u"."created_at" < "users"."created_at" OR ("u"."created_at" = "users"."created_at" AND "u"."id" < "users"."id"))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_user_name_lower" ON "users" ((lower("user_name")));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email_lower" ON "users" ((nullif(lower("email"), '')));
@@ -0,0 +1,6 @@
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_user_name_lower" ON "users" ((lower("user_name")));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email_lower" ON "users" ((nullif(lower("email"), '')));
+DROP INDEX IF EXISTS "idx_users_email_norm";
+DROP INDEX IF EXISTS "idx_users_user_name_norm";
+ALTER TABLE "users" DROP COLUMN "email_norm";
+ALTER TABLE "users" DROP COLUMN "user_name_norm";
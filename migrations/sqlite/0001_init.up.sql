@@ -0,0 +1,204 @@
+-- the tables of the service before the versioned migrations, they are
+-- created only when they don't exist so the databases created by
+-- DATABASE_MIGRATE keep their data
+
+CREATE TABLE IF NOT EXISTS `users` (
+    `id` char(36) NOT NULL,
+    `user_name` char(70),
+    `first_name` char(30),
+    `last_name` char(30),
+    `password` text,
+    `language` char(3) NOT NULL,
+    `email` char(70),
+    `phone` char(30),
+    `photo` char(100),
+    `client_id` text,
+    `client_secret` text,
+    `token` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    PRIMARY KEY (`id`),
+    CONSTRAINT `uni_users_user_name` UNIQUE (`user_name`)
+);
+
+CREATE TABLE IF NOT EXISTS `roles` (
+    `id` char(36) NOT NULL,
+    `user_id` char(36) NOT NULL,
+    `app` char(36) NOT NULL,
+    `role` integer,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    PRIMARY KEY (`id`),
+    CONSTRAINT `fk_users_roles` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_userid_app` ON `roles`(`user_id`,`app`);
+
+CREATE TABLE IF NOT EXISTS `service_accounts` (
+    `user_id` char(36) NOT NULL,
+    `description` varchar(255),
+    `created_by` char(36) NOT NULL,
+    `created_at` datetime,
+    PRIMARY KEY (`user_id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_service_accounts_created_by` ON `service_accounts`(`created_by`);
+
+CREATE TABLE IF NOT EXISTS `user_phones` (
+    `user_id` char(36) NOT NULL,
+    `number` char(20) NOT NULL,
+    `country` char(2),
+    `calling_code` integer,
+    `verified_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `user_locales` (
+    `user_id` char(36) NOT NULL,
+    `language` varchar(35),
+    `timezone` varchar(64),
+    `created_at` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `identities` (
+    `id` char(36) NOT NULL,
+    `user_id` char(36) NOT NULL,
+    `provider` char(30) NOT NULL,
+    `subject` varchar(191) NOT NULL,
+    `email` char(70),
+    `created_at` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_provider_subject` ON `identities`(`provider`,`subject`);
+CREATE INDEX IF NOT EXISTS `idx_identities_user_id` ON `identities`(`user_id`);
+
+CREATE TABLE IF NOT EXISTS `magic_challenges` (
+    `id` char(36) NOT NULL,
+    `user_id` char(36) NOT NULL,
+    `method` char(10) NOT NULL,
+    `secret_hash` char(64) NOT NULL,
+    `device_hash` char(64) NOT NULL,
+    `attempts` integer NOT NULL DEFAULT 0,
+    `expires_at` datetime,
+    `used_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_magic_challenges_user_id` ON `magic_challenges`(`user_id`);
+
+CREATE TABLE IF NOT EXISTS `passkeys` (
+    `id` char(36) NOT NULL,
+    `user_id` char(36) NOT NULL,
+    `name` char(70) NOT NULL,
+    `credential_id` varchar(255) NOT NULL,
+    `public_key` blob NOT NULL,
+    `attestation_type` char(30),
+    `aa_guid` blob,
+    `sign_count` integer,
+    `transports` varchar(100),
+    `backup_eligible` numeric,
+    `backup_state` numeric,
+    `last_used_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_passkeys_credential_id` ON `passkeys`(`credential_id`);
+CREATE INDEX IF NOT EXISTS `idx_passkeys_user_id` ON `passkeys`(`user_id`);
+
+CREATE TABLE IF NOT EXISTS `passkey_sessions` (
+    `id` char(36) NOT NULL,
+    `user_id` char(36),
+    `kind` char(20) NOT NULL,
+    `data` text NOT NULL,
+    `expires_at` datetime NOT NULL,
+    `created_at` datetime,
+    PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `access_tokens` (
+    `id` char(36) NOT NULL,
+    `user_id` char(36) NOT NULL,
+    `name` char(70) NOT NULL,
+    `hint` char(20) NOT NULL,
+    `hash` char(64) NOT NULL,
+    `scopes` varchar(255) NOT NULL,
+    `expires_at` datetime,
+    `last_used_at` datetime,
+    `revoked_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_access_tokens_user_id` ON `access_tokens`(`user_id`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_access_tokens_hash` ON `access_tokens`(`hash`);
+
+CREATE TABLE IF NOT EXISTS `impersonation_sessions` (
+    `id` char(36) NOT NULL,
+    `actor_id` char(36) NOT NULL,
+    `user_id` char(36) NOT NULL,
+    `reason` varchar(255) NOT NULL,
+    `expires_at` datetime,
+    `ended_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_impersonation_sessions_user_id` ON `impersonation_sessions`(`user_id`);
+CREATE INDEX IF NOT EXISTS `idx_impersonation_sessions_actor_id` ON `impersonation_sessions`(`actor_id`);
+
+CREATE TABLE IF NOT EXISTS `impersonation_events` (
+    `id` char(36) NOT NULL,
+    `session_id` char(36) NOT NULL,
+    `type` char(10) NOT NULL,
+    `actor_id` char(36) NOT NULL,
+    `created_at` datetime,
+    PRIMARY KEY (`id`),
+    CONSTRAINT `fk_impersonation_sessions_events` FOREIGN KEY (`session_id`) REFERENCES `impersonation_sessions`(`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_impersonation_events_session_id` ON `impersonation_events`(`session_id`);
+
+CREATE TABLE IF NOT EXISTS `email_changes` (
+    `id` char(36) NOT NULL,
+    `user_id` char(36) NOT NULL,
+    `old_email` char(70),
+    `new_email` char(70) NOT NULL,
+    `confirm_hash` char(64) NOT NULL,
+    `cancel_hash` char(64) NOT NULL,
+    `expires_at` datetime,
+    `confirmed_at` datetime,
+    `canceled_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_email_changes_user_id` ON `email_changes`(`user_id`);
+
+CREATE TABLE IF NOT EXISTS `phone_verifications` (
+    `id` char(36) NOT NULL,
+    `user_id` char(36) NOT NULL,
+    `number` char(20) NOT NULL,
+    `code_hash` char(64) NOT NULL,
+    `attempts` integer NOT NULL DEFAULT 0,
+    `expires_at` datetime,
+    `used_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_phone_verifications_user_id` ON `phone_verifications`(`user_id`);
+
+CREATE TABLE IF NOT EXISTS `user_attributes` (
+    `user_id` char(36) NOT NULL,
+    `app` char(36) NOT NULL,
+    `data` text NOT NULL,
+    `created_at` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`user_id`,`app`)
+);
@@ -0,0 +1,4 @@
+-- the renamed duplicates keep their new username
+
+DROP INDEX IF EXISTS `idx_users_email_lower`;
+DROP INDEX IF EXISTS `idx_users_user_name_lower`;
@@ -0,0 +1,11 @@
+-- the username and the email in the form of user.Normalize (NFKC and
+-- case folding), the logins are looked up and kept unique on them
+-- instead of the lower() of the database, which doesn't fold every
+-- letter. The service fills them for the existing users when it starts
+
+ALTER TABLE `users` ADD COLUMN `user_name_norm` varchar(255);
+ALTER TABLE `users` ADD COLUMN `email_norm` varchar(255);
+CREATE UNIQUE INDEX `idx_users_user_name_norm` ON `users` (`user_name_norm`);
+CREATE UNIQUE INDEX `idx_users_email_norm` ON `users` (`email_norm`);
+DROP INDEX IF EXISTS `idx_users_email_lower`;
+DROP INDEX IF EXISTS `idx_users_user_name_lower`;
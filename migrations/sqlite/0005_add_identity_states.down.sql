@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `identity_states`;
+ALTER TABLE `identities` DROP COLUMN `created_user`;
@@ -0,0 +1,13 @@
+-- the consumed states of the identity logins, the nonce of a state can
+-- only be stored once so a state can't be replayed. created_user marks
+-- the identity that created its user just in time, that user doesn't
+-- know its password and can't remove its last identity
+
+ALTER TABLE `identities` ADD COLUMN `created_user` numeric NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS `identity_states` (
+    `nonce_hash` char(64) NOT NULL,
+    `expires_at` datetime NOT NULL,
+    PRIMARY KEY (`nonce_hash`)
+);
+CREATE INDEX IF NOT EXISTS `idx_identity_states_expires_at` ON `identity_states`(`expires_at`);
@@ -0,0 +1,65 @@
+// Package apierror builds the error responses of the endpoints, they keep
+// the error so the handler renders the message of its code in the language
+// of the request
+package apierror
+
+import (
+	"net/http"
+
+	"github.com/ncostamagna/go-http-utils/response"
+)
+
+// Response is an error response with the error of the endpoint
+type Response struct {
+	response.ErrorResponse
+	err error
+}
+
+// New returns the response of err with the status
+func New(status int, err error) *Response {
+	return &Response{response.ErrorResponse{Status: status, Message: err.Error()}, err}
+}
+
+func (r *Response) Unwrap() error {
+	return r.err
+}
+
+func BadRequest(err error) *Response {
+	return New(http.StatusBadRequest, err)
+}
+
+func Unauthorized(err error) *Response {
+	return New(http.StatusUnauthorized, err)
+}
+
+func Forbidden(err error) *Response {
+	return New(http.StatusForbidden, err)
+}
+
+func NotFound(err error) *Response {
+	return New(http.StatusNotFound, err)
+}
+
+// Conflict is the 409 response, go-http-utils doesn't have it
+func Conflict(err error) *Response {
+	return New(http.StatusConflict, err)
+}
+
+// PreconditionFailed is the 412 response of the updates of an old version
+func PreconditionFailed(err error) *Response {
+	return New(http.StatusPreconditionFailed, err)
+}
+
+// PreconditionRequired is the 428 response of the updates without If-Match
+func PreconditionRequired(err error) *Response {
+	return New(http.StatusPreconditionRequired, err)
+}
+
+// TooManyRequests is the 429 response of the throttled requests
+func TooManyRequests(err error) *Response {
+	return New(http.StatusTooManyRequests, err)
+}
+
+func InternalServerError(err error) *Response {
+	return New(http.StatusInternalServerError, err)
+}
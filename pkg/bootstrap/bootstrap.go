@@ -1,12 +1,28 @@
 package bootstrap
 
 import (
+	"context"
 	"fmt"
-	domain "github.com/ncostamagna/axul_domain/domain/user"
+	"github.com/glebarez/sqlite"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/internal/user/identity"
+	"github.com/ncostamagna/axul-user/migrations"
+	"github.com/ncostamagna/axul-user/pkg/blob"
+	"github.com/ncostamagna/axul-user/pkg/cache"
+	"github.com/ncostamagna/axul-user/pkg/mailer"
+	"github.com/ncostamagna/axul-user/pkg/migrate"
+	"github.com/ncostamagna/axul-user/pkg/replica"
+	"github.com/ncostamagna/axul-user/pkg/sms"
 	"github.com/ncostamagna/go-logger-hub/loghub"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"io/fs"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func NewLogger() loghub.Logger {
@@ -46,29 +62,232 @@ func NewLogger() (*slog.Logger, error) {
 	})), nil
 }*/
 
+// DBConnection opens the database of DATABASE_DRIVER, mysql by default,
+// postgres or sqlite. The sqlite database is the file DATABASE_NAME, in
+// memory when it's empty
 func DBConnection() (*gorm.DB, error) {
 
-	dsn := os.ExpandEnv("${DATABASE_USER}:${DATABASE_PASSWORD}@(${DATABASE_HOST}:${DATABASE_PORT})/${DATABASE_NAME}?charset=utf8&parseTime=True&loc=Local")
-	fmt.Println("connect: ", dsn)
+	dialector, err := dialector(os.Getenv("DATABASE_DRIVER"))
+	if err != nil {
+		return nil, err
+	}
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, err
 	}
 
+	if db.Dialector.Name() == "sqlite" {
+		// sqlite allows a single writer and every connection to
+		// :memory: opens a different database
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
 	if os.Getenv("DATABASE_DEBUG") == "true" {
 		db = db.Debug()
 	}
 
-	if os.Getenv("DATABASE_MIGRATE") == "true" {
-		if err := db.AutoMigrate(&domain.User{}); err != nil {
+	return db, nil
+}
+
+func dialector(driver string) (gorm.Dialector, error) {
+	switch driver {
+	case "", "mysql":
+		dsn := os.ExpandEnv("${DATABASE_USER}:${DATABASE_PASSWORD}@(${DATABASE_HOST}:${DATABASE_PORT})/${DATABASE_NAME}?charset=utf8&parseTime=True&loc=Local")
+		fmt.Println("connect: ", address())
+		return mysql.Open(dsn), nil
+
+	case "postgres":
+		sslMode := os.Getenv("DATABASE_SSLMODE")
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+
+		dsn := os.ExpandEnv("host=${DATABASE_HOST} port=${DATABASE_PORT} user=${DATABASE_USER} password=${DATABASE_PASSWORD} dbname=${DATABASE_NAME} sslmode=") + sslMode
+		fmt.Println("connect: ", address())
+		return postgres.Open(dsn), nil
+
+	case "sqlite":
+		name := os.Getenv("DATABASE_NAME")
+		if name == "" {
+			name = ":memory:"
+		}
+
+		fmt.Println("connect: ", name)
+		return sqlite.Open(name + "?_pragma=foreign_keys(1)"), nil
+	}
+
+	return nil, fmt.Errorf("database driver '%s' isn't supported, it must be mysql, postgres or sqlite", driver)
+}
+
+// address is the database of the DSN without the credentials, the DSN
+// isn't printed
+func address() string {
+	return os.ExpandEnv("${DATABASE_HOST}:${DATABASE_PORT}/${DATABASE_NAME}")
+}
+
+// Replicas sends the reads of db to DATABASE_REPLICAS, a comma separated
+// list of DSNs of DATABASE_DRIVER (file names with sqlite).
+// DATABASE_REPLICA_CHECK_INTERVAL is the seconds between the health checks
+// of a replica, 10 by default
+func Replicas(db *gorm.DB, logger loghub.Logger) error {
+	v := os.Getenv("DATABASE_REPLICAS")
+	if v == "" {
+		return nil
+	}
+
+	var config replica.Config
+	for _, dsn := range strings.Split(v, ",") {
+		dialector, err := replicaDialector(os.Getenv("DATABASE_DRIVER"), strings.TrimSpace(dsn))
+		if err != nil {
+			return err
+		}
+		config.Replicas = append(config.Replicas, dialector)
+	}
+
+	interval, _ := strconv.Atoi(os.Getenv("DATABASE_REPLICA_CHECK_INTERVAL"))
+	config.CheckInterval = time.Duration(interval) * time.Second
+
+	logger.Info(fmt.Sprintf("%d database replicas", len(config.Replicas)))
+	return db.Use(replica.New(config, logger))
+}
+
+func replicaDialector(driver, dsn string) (gorm.Dialector, error) {
+	switch driver {
+	case "", "mysql":
+		return mysql.Open(dsn), nil
+	case "postgres":
+		return postgres.Open(dsn), nil
+	case "sqlite":
+		return sqlite.Open(dsn + "?_pragma=foreign_keys(1)"), nil
+	}
+
+	return nil, fmt.Errorf("database driver '%s' isn't supported, it must be mysql, postgres or sqlite", driver)
+}
+
+// NewCache is the in-process LRU of CACHE_SIZE entries, 10000 by default,
+// and CACHE_TTL is the seconds an entry is kept, 60 by default
+func NewCache() (cache.Cache, time.Duration) {
+	size, _ := strconv.Atoi(os.Getenv("CACHE_SIZE"))
+	if size <= 0 {
+		size = 10000
+	}
+
+	ttl, _ := strconv.Atoi(os.Getenv("CACHE_TTL"))
+	if ttl <= 0 {
+		ttl = 60
+	}
+
+	return cache.NewLRU(size), time.Duration(ttl) * time.Second
+}
+
+// NewMigrator has the embedded migrations of the database driver, they
+// are applied with the migrate command or at the start when DATABASE_MIGRATE
+// is true
+func NewMigrator(db *gorm.DB, logger loghub.Logger) (*migrate.Migrator, error) {
+	fsys, err := fs.Sub(migrations.FS, db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.New(db, fsys, logger)
+}
+
+// NewLocales is the registry of SUPPORTED_LANGUAGES, a comma separated list
+// of BCP 47 tags where the first one is the default, by default "en,es"
+func NewLocales() (*user.Locales, error) {
+	v := os.Getenv("SUPPORTED_LANGUAGES")
+	if v == "" {
+		return user.NewLocales()
+	}
+
+	return user.NewLocales(strings.Split(v, ",")...)
+}
+
+// NewMailer returns the SMTP mailer, without MAIL_HOST the emails are logged
+func NewMailer(logger loghub.Logger) mailer.Mailer {
+	host := os.Getenv("MAIL_HOST")
+	if host == "" {
+		return mailer.NewLogMailer(logger)
+	}
+
+	return mailer.NewSMTP(host, os.Getenv("MAIL_PORT"), os.Getenv("MAIL_USER"), os.Getenv("MAIL_PASSWORD"), os.Getenv("MAIL_FROM"))
+}
+
+// NewSMSSender returns the SMS provider, only the local fake that logs
+// the messages is available for now
+func NewSMSSender(logger loghub.Logger) sms.Sender {
+	return sms.NewLogSender(logger)
+}
+
+// NewBlobStore stores the uploaded files in BLOB_DIR, by default ./data
+func NewBlobStore() (blob.Store, error) {
+	dir := os.Getenv("BLOB_DIR")
+	if dir == "" {
+		dir = "data"
+	}
+
+	return blob.NewLocalStore(dir)
+}
+
+// NewWebAuthn is the passkeys relying party, WEBAUTHN_RP_ORIGINS is a comma
+// separated list of the origins allowed to register and use passkeys.
+// It returns nil when WEBAUTHN_RP_ID isn't set and passkeys are disabled
+func NewWebAuthn() (*webauthn.WebAuthn, error) {
+	if os.Getenv("WEBAUTHN_RP_ID") == "" {
+		return nil, nil
+	}
+
+	return webauthn.New(&webauthn.Config{
+		RPID:          os.Getenv("WEBAUTHN_RP_ID"),
+		RPDisplayName: os.Getenv("WEBAUTHN_RP_NAME"),
+		RPOrigins:     strings.Split(os.Getenv("WEBAUTHN_RP_ORIGINS"), ","),
+	})
+}
+
+// IdentityProviders builds the external login providers configured in the
+// environment, a provider without client id is disabled
+func IdentityProviders(ctx context.Context) ([]identity.Provider, error) {
+	var providers []identity.Provider
+	redirect := os.Getenv("IDENTITY_REDIRECT_URL")
+
+	if id := os.Getenv("GOOGLE_CLIENT_ID"); id != "" {
+		p, err := identity.NewGoogleProvider(ctx, identity.ProviderConfig{
+			ClientID:     id,
+			ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
+			RedirectURL:  fmt.Sprintf("%s/%s/callback", redirect, identity.Google),
+		}, nil)
+		if err != nil {
 			return nil, err
 		}
+		providers = append(providers, p)
+	}
+
+	if id := os.Getenv("GITHUB_CLIENT_ID"); id != "" {
+		providers = append(providers, identity.NewGitHubProvider(identity.ProviderConfig{
+			ClientID:     id,
+			ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
+			RedirectURL:  fmt.Sprintf("%s/%s/callback", redirect, identity.GitHub),
+		}, nil))
+	}
 
-		if err := db.AutoMigrate(&domain.Role{}); err != nil {
+	if id := os.Getenv("OIDC_CLIENT_ID"); id != "" {
+		p, err := identity.NewOIDCProvider(ctx, identity.ProviderConfig{
+			Name:         identity.OIDC,
+			Issuer:       os.Getenv("OIDC_ISSUER"),
+			ClientID:     id,
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  fmt.Sprintf("%s/%s/callback", redirect, identity.OIDC),
+		}, nil)
+		if err != nil {
 			return nil, err
 		}
+		providers = append(providers, p)
 	}
 
-	return db, nil
+	return providers, nil
 }
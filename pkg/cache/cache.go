@@ -0,0 +1,149 @@
+// Package cache keeps the results of the repositories in a Cache, an LRU
+// in process or a cache shared by the instances of the service
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"github.com/ncostamagna/axul-user/pkg/replica"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"golang.org/x/sync/singleflight"
+	"hash/fnv"
+	"sync/atomic"
+	"time"
+)
+
+// Cache stores encoded values until their ttl, a shared cache like Redis
+// implements it to share the values between the instances
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Stats are the metrics of a Store, Loads counts the calls to the
+// repository and it's lower than Misses when concurrent misses of a key
+// share a load
+type Stats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Loads  uint64 `json:"loads"`
+	Errors uint64 `json:"errors"`
+}
+
+// Store reads through a Cache, the values are encoded with gob and the
+// concurrent misses of a key share a single load so an expired key
+// doesn't send every request to the database
+type Store struct {
+	name   string
+	cache  Cache
+	ttl    time.Duration
+	group  singleflight.Group
+	logger loghub.Logger
+
+	// generations changes when a key is deleted, a load that started
+	// before doesn't store its value
+	generations [256]atomic.Uint64
+
+	hits, misses, loads, errors atomic.Uint64
+}
+
+// NewStore returns the Store of the keys with the name prefix
+func NewStore(name string, cache Cache, ttl time.Duration, logger loghub.Logger) *Store {
+	return &Store{name: name, cache: cache, ttl: ttl, logger: logger}
+}
+
+// entry wraps the values so the nil pointers are encoded too
+type entry[T any] struct {
+	Value T
+}
+
+// Load returns the value of the key, from the cache or from load. The
+// errors of load aren't cached and the errors of the cache are logged and
+// the value is loaded
+func Load[T any](ctx context.Context, s *Store, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	key = s.key(key)
+
+	if data, ok, err := s.cache.Get(ctx, key); err != nil {
+		s.fail(err)
+	} else if ok {
+		var e entry[T]
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&e); err == nil {
+			s.hits.Add(1)
+			return e.Value, nil
+		}
+		s.fail(err)
+	}
+	s.misses.Add(1)
+
+	// the load is shared, it isn't canceled when the request that
+	// started it is. It reads the primary, a replica can be behind the
+	// write that deleted the key and its value would be kept for the ttl
+	shared := context.WithoutCancel(ctx)
+	v, err, _ := s.group.Do(key, func() (interface{}, error) {
+		s.loads.Add(1)
+		generation := s.generation(key).Load()
+		value, err := load(replica.Primary(shared))
+		if err != nil || s.generation(key).Load() != generation {
+			return value, err
+		}
+
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(entry[T]{value}); err != nil {
+			s.fail(err)
+			return value, nil
+		}
+
+		if err := s.cache.Set(shared, key, buf.Bytes(), s.ttl); err != nil {
+			s.fail(err)
+		}
+		return value, nil
+	})
+
+	value, _ := v.(T)
+	return value, err
+}
+
+// Delete removes the keys, the loads of the keys in flight don't store
+// their values. The errors are logged
+func (s *Store) Delete(ctx context.Context, keys ...string) {
+	prefixed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		key = s.key(key)
+		s.generation(key).Add(1)
+		s.group.Forget(key)
+		prefixed = append(prefixed, key)
+	}
+
+	if err := s.cache.Delete(context.WithoutCancel(ctx), prefixed...); err != nil {
+		s.fail(err)
+	}
+}
+
+// Stats returns the metrics of the store
+func (s *Store) Stats() Stats {
+	return Stats{Hits: s.hits.Load(), Misses: s.misses.Load(), Loads: s.loads.Load(), Errors: s.errors.Load()}
+}
+
+// String is the JSON of the stats, a Store is an expvar.Var
+func (s *Store) String() string {
+	st := s.Stats()
+	return fmt.Sprintf(`{"hits":%d,"misses":%d,"loads":%d,"errors":%d}`, st.Hits, st.Misses, st.Loads, st.Errors)
+}
+
+func (s *Store) key(key string) string {
+	return s.name + ":" + key
+}
+
+func (s *Store) generation(key string) *atomic.Uint64 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return &s.generations[h.Sum32()%uint32(len(s.generations))]
+}
+
+func (s *Store) fail(err error) {
+	s.errors.Add(1)
+	s.logger.Warn(fmt.Errorf("cache %s: %w", s.name, err))
+}
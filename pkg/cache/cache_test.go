@@ -0,0 +1,203 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ncostamagna/axul-user/pkg/cache"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+)
+
+type value struct {
+	Name string
+	Tags []string
+}
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(2)
+
+	_ = c.Set(ctx, "a", []byte("1"), time.Minute)
+	_ = c.Set(ctx, "b", []byte("2"), time.Minute)
+	if _, ok, _ := c.Get(ctx, "a"); !ok {
+		t.Fatal("a isn't cached")
+	}
+
+	_ = c.Set(ctx, "c", []byte("3"), time.Minute)
+	if _, ok, _ := c.Get(ctx, "b"); ok {
+		t.Error("b is cached, it was the least recently used")
+	}
+
+	if v, ok, _ := c.Get(ctx, "a"); !ok || string(v) != "1" {
+		t.Errorf("a returned %q, %v", v, ok)
+	}
+
+	_ = c.Delete(ctx, "a", "unknown")
+	if _, ok, _ := c.Get(ctx, "a"); ok {
+		t.Error("a is cached after the delete")
+	}
+
+	_ = c.Set(ctx, "d", []byte("4"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, ok, _ := c.Get(ctx, "d"); ok {
+		t.Error("d is cached after its ttl")
+	}
+
+	if c.Len() != 1 {
+		t.Errorf("the cache has %d entries, want 1", c.Len())
+	}
+}
+
+func TestStoreLoad(t *testing.T) {
+	ctx := context.Background()
+	s := cache.NewStore("test", cache.NewLRU(10), time.Minute, loghub.New())
+
+	loads := 0
+	load := func(ctx context.Context) (*value, error) {
+		loads++
+		return &value{Name: "john", Tags: []string{"a"}}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		v, err := cache.Load(ctx, s, "john", load)
+		if err != nil || v.Name != "john" || len(v.Tags) != 1 {
+			t.Fatalf("load returned %+v, %v", v, err)
+		}
+	}
+
+	if loads != 1 {
+		t.Errorf("the value was loaded %d times, want 1", loads)
+	}
+
+	s.Delete(ctx, "john")
+	if _, err := cache.Load(ctx, s, "john", load); err != nil || loads != 2 {
+		t.Errorf("load after delete returned %v and loaded %d times, want 2", err, loads)
+	}
+
+	if st := s.Stats(); st.Hits != 2 || st.Misses != 2 || st.Loads != 2 || st.Errors != 0 {
+		t.Errorf("the stats are %+v", st)
+	}
+}
+
+func TestStoreNil(t *testing.T) {
+	ctx := context.Background()
+	s := cache.NewStore("test", cache.NewLRU(10), time.Minute, loghub.New())
+
+	for i := 0; i < 2; i++ {
+		v, err := cache.Load(ctx, s, "none", func(ctx context.Context) (*value, error) { return nil, nil })
+		if err != nil || v != nil {
+			t.Fatalf("load returned %+v, %v, want nil", v, err)
+		}
+	}
+
+	if st := s.Stats(); st.Hits != 1 {
+		t.Errorf("the nil value wasn't cached: %+v", st)
+	}
+}
+
+func TestStoreErrorsArentCached(t *testing.T) {
+	ctx := context.Background()
+	s := cache.NewStore("test", cache.NewLRU(10), time.Minute, loghub.New())
+	failed := errors.New("failed")
+
+	if _, err := cache.Load(ctx, s, "john", func(ctx context.Context) (int, error) { return 0, failed }); err != failed {
+		t.Fatalf("load returned %v, want the error of the repository", err)
+	}
+
+	v, err := cache.Load(ctx, s, "john", func(ctx context.Context) (int, error) { return 1, nil })
+	if err != nil || v != 1 {
+		t.Errorf("load after an error returned %d, %v", v, err)
+	}
+}
+
+// concurrent misses of a key share a single load
+func TestStoreStampede(t *testing.T) {
+	ctx := context.Background()
+	s := cache.NewStore("test", cache.NewLRU(10), time.Minute, loghub.New())
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (int, error) {
+		loads.Add(1)
+		<-release
+		return 1, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := cache.Load(ctx, s, "john", load); err != nil || v != 1 {
+				t.Errorf("load returned %d, %v", v, err)
+			}
+		}()
+	}
+
+	for s.Stats().Misses < 10 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Errorf("the value was loaded %d times, want 1", n)
+	}
+}
+
+// a delete while the value is loaded keeps the old value out of the cache
+func TestStoreDeleteDuringLoad(t *testing.T) {
+	ctx := context.Background()
+	s := cache.NewStore("test", cache.NewLRU(10), time.Minute, loghub.New())
+
+	v, err := cache.Load(ctx, s, "john", func(ctx context.Context) (int, error) {
+		s.Delete(ctx, "john")
+		return 1, nil
+	})
+	if err != nil || v != 1 {
+		t.Fatalf("load returned %d, %v", v, err)
+	}
+
+	v, err = cache.Load(ctx, s, "john", func(ctx context.Context) (int, error) { return 2, nil })
+	if err != nil || v != 2 {
+		t.Errorf("load after a delete during the load returned %d, %v, want the new value", v, err)
+	}
+}
+
+type brokenCache struct{}
+
+func (brokenCache) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errors.New("down")
+}
+
+func (brokenCache) Set(context.Context, string, []byte, time.Duration) error {
+	return errors.New("down")
+}
+
+func (brokenCache) Delete(context.Context, ...string) error {
+	return errors.New("down")
+}
+
+// the repository is used when the cache is down
+func TestStoreCacheDown(t *testing.T) {
+	ctx := context.Background()
+	s := cache.NewStore("test", brokenCache{}, time.Minute, loghub.New())
+
+	v, err := cache.Load(ctx, s, "john", func(ctx context.Context) (int, error) { return 1, nil })
+	if err != nil || v != 1 {
+		t.Fatalf("load returned %d, %v", v, err)
+	}
+	s.Delete(ctx, "john")
+
+	if st := s.Stats(); st.Errors != 3 || st.Misses != 1 {
+		t.Errorf("the stats are %+v, want the get, set and delete errors", st)
+	}
+
+	if want := `{"hits":0,"misses":1,"loads":1,"errors":3}`; s.String() != want {
+		t.Errorf("the expvar is %s, want %s", s.String(), want)
+	}
+}
@@ -0,0 +1,213 @@
+// Package cursor paginates the lists ordered by (created_at, id) with
+// opaque cursors, a page starts after the last row of the previous page so
+// the rows created while a client scrolls don't move the next pages
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/ncostamagna/axul-user/pkg/i18n"
+	"github.com/ncostamagna/go-http-utils/meta"
+	"github.com/ncostamagna/go-http-utils/response"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrInvalid is returned by Parse when the cursor wasn't made by String
+var ErrInvalid = i18n.NewError("CURSOR_INVALID", "the cursor isn't valid, send the next or prev cursor of a page")
+
+// Cursor is the position of a row in a list, the newest rows first. A
+// cursor with Before goes to the rows before the position, the previous
+// page, and without it to the rows after it
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"i"`
+	Before    bool      `json:"b,omitempty"`
+}
+
+// String is the opaque value of the cursor sent to the clients
+func (c Cursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Parse returns the cursor of a value made by String
+func Parse(v string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == "" || c.CreatedAt.IsZero() {
+		return nil, ErrInvalid
+	}
+
+	return &c, nil
+}
+
+// Page is a page of Limit rows at Cursor, the first page without it
+type Page struct {
+	Cursor *Cursor
+	Limit  int
+}
+
+// Limit is the limit of a page, def when the request has none
+func Limit(limit int, def string) (int, error) {
+	if limit > 0 {
+		return limit, nil
+	}
+	return strconv.Atoi(def)
+}
+
+// Scope filters and orders a query by the page, it reads a row more than
+// the limit to know if there is another page
+func (p Page) Scope(tx *gorm.DB) *gorm.DB {
+	order := "created_at desc, id desc"
+	if c := p.Cursor; c != nil {
+		if c.Before {
+			tx = tx.Where("created_at > ? or (created_at = ? and id > ?)", c.CreatedAt, c.CreatedAt, c.ID)
+			order = "created_at, id"
+		} else {
+			tx = tx.Where("created_at < ? or (created_at = ? and id < ?)", c.CreatedAt, c.CreatedAt, c.ID)
+		}
+	}
+
+	return tx.Order(order).Limit(p.Limit + 1)
+}
+
+// Meta is the meta of a list with the cursors of the next and previous
+// pages, the page fields are only set in the lists requested by page
+type Meta struct {
+	Page       int    `json:"page,omitempty"`
+	PerPage    int    `json:"per_page"`
+	PageCount  int    `json:"page_count,omitempty"`
+	TotalCount int    `json:"total_count,omitempty"`
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+}
+
+// Response is response.SuccessResponse with the meta of the cursors
+type Response struct {
+	Message string      `json:"message"`
+	Status  int         `json:"status"`
+	Data    interface{} `json:"data"`
+	Meta    *Meta       `json:"meta,omitempty"`
+}
+
+// OK is response.OK of a list with its cursors
+func OK(msg string, data interface{}, meta *Meta) response.Response {
+	if msg == "" {
+		msg = "Ok request."
+	}
+	return &Response{Message: msg, Status: http.StatusOK, Data: data, Meta: meta}
+}
+
+func (r *Response) Error() string {
+	return ""
+}
+
+func (r *Response) StatusCode() int {
+	return r.Status
+}
+
+func (r *Response) GetBody() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+func (r *Response) GetData() interface{} {
+	return r.Data
+}
+
+// New returns the rows of the page, newest first, and their meta. rows are
+// the rows of a query with Scope, key is the position of a row
+func New[T any](rows []T, page Page, key func(T) Cursor) ([]T, *Meta) {
+	more := len(rows) > page.Limit
+	if more {
+		rows = rows[:page.Limit]
+	}
+
+	before := page.Cursor != nil && page.Cursor.Before
+	if before {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	res := &Meta{PerPage: page.Limit}
+	if len(rows) == 0 {
+		return rows, res
+	}
+
+	// a page at a cursor always has rows on the side it came from
+	if more || before {
+		next := key(rows[len(rows)-1])
+		res.Next = next.String()
+	}
+
+	if (more && before) || (page.Cursor != nil && !before) {
+		prev := key(rows[0])
+		prev.Before = true
+		res.Prev = prev.String()
+	}
+
+	return rows, res
+}
+
+// Offset returns the meta of a page requested by offset with the cursors
+// around its rows, the clients follow them to move from the pages to the
+// cursors. rows are the rows of the page in the order of Scope
+func Offset[T any](rows []T, m *meta.Meta, key func(T) Cursor) *Meta {
+	res := &Meta{Page: m.Page, PerPage: m.PerPage, PageCount: m.PageCount, TotalCount: m.TotalCount}
+	if len(rows) == 0 {
+		return res
+	}
+
+	if m.Page < m.PageCount {
+		res.Next = key(rows[len(rows)-1]).String()
+	}
+
+	if m.Page > 1 {
+		prev := key(rows[0])
+		prev.Before = true
+		res.Prev = prev.String()
+	}
+
+	return res
+}
+
+// Slice is Scope for the rows in memory of the fake repositories, rows are
+// sorted like Scope without a cursor
+func Slice[T any](rows []T, page Page, key func(T) Cursor) []T {
+	res := []T{}
+	for i := range rows {
+		row := rows[i]
+		switch c := page.Cursor; {
+		case c == nil:
+		case c.Before:
+			row = rows[len(rows)-1-i]
+			if !older(*c, key(row)) {
+				continue
+			}
+		default:
+			if !older(key(row), *c) {
+				continue
+			}
+		}
+
+		if res = append(res, row); len(res) > page.Limit {
+			break
+		}
+	}
+
+	return res
+}
+
+func older(a, b Cursor) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
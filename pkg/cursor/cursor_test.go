@@ -0,0 +1,88 @@
+package cursor_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ncostamagna/axul-user/pkg/cursor"
+	"github.com/ncostamagna/go-http-utils/meta"
+)
+
+type row struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+func key(r row) cursor.Cursor {
+	return cursor.Cursor{CreatedAt: r.CreatedAt, ID: r.ID}
+}
+
+func TestParse(t *testing.T) {
+	c := cursor.Cursor{CreatedAt: time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC), ID: "b", Before: true}
+
+	got, err := cursor.Parse(c.String())
+	if err != nil || !got.CreatedAt.Equal(c.CreatedAt) || got.ID != c.ID || !got.Before {
+		t.Errorf("parse of %s returned %+v, %v", c, got, err)
+	}
+
+	for _, v := range []string{"page-2", "e30", c.String() + "!"} {
+		if _, err := cursor.Parse(v); err != cursor.ErrInvalid {
+			t.Errorf("parse of %q returned %v, want ErrInvalid", v, err)
+		}
+	}
+}
+
+// the rows created at the same time are ordered by id, the pages don't
+// skip or repeat them
+func TestPagesWithSameTime(t *testing.T) {
+	now := time.Now()
+	rows := []row{{now, "e"}, {now, "d"}, {now, "c"}, {now.Add(-time.Second), "b"}, {now.Add(-time.Second), "a"}}
+
+	var next []string
+	var last *cursor.Meta
+	for page := (cursor.Page{Limit: 2}); ; {
+		list, m := cursor.New(cursor.Slice(rows, page, key), page, key)
+		for _, r := range list {
+			next = append(next, r.ID)
+		}
+
+		last = m
+		if m.Next == "" {
+			break
+		}
+		page.Cursor, _ = cursor.Parse(m.Next)
+	}
+
+	if fmt.Sprint(next) != "[e d c b a]" {
+		t.Errorf("the next cursors returned %v", next)
+	}
+
+	c, _ := cursor.Parse(last.Prev)
+	page := cursor.Page{Cursor: c, Limit: 2}
+	list, m := cursor.New(cursor.Slice(rows, page, key), page, key)
+	if fmt.Sprint(list) != fmt.Sprint(rows[2:4]) || m.Next == "" || m.Prev == "" {
+		t.Errorf("the prev cursor returned %v, %+v", list, m)
+	}
+}
+
+func TestOffset(t *testing.T) {
+	now := time.Now()
+	rows := []row{{now, "b"}, {now, "a"}}
+
+	cases := []struct {
+		page       int
+		next, prev bool
+	}{
+		{1, true, false},
+		{2, true, true},
+		{3, false, true},
+	}
+
+	for _, c := range cases {
+		m := cursor.Offset(rows, &meta.Meta{Page: c.page, PerPage: 2, PageCount: 3, TotalCount: 6}, key)
+		if (m.Next != "") != c.next || (m.Prev != "") != c.prev || m.Page != c.page || m.TotalCount != 6 {
+			t.Errorf("the meta of page %d is %+v", c.page, m)
+		}
+	}
+}
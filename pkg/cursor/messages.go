@@ -0,0 +1,12 @@
+package cursor
+
+import "github.com/ncostamagna/axul-user/pkg/i18n"
+
+func init() {
+	i18n.Register(
+		i18n.Message{Code: "CURSOR_INVALID", Field: "cursor", Text: map[string]string{
+			i18n.English: "the cursor isn't valid, send the next or prev cursor of a page",
+			i18n.Spanish: "el cursor no es válido, envía el cursor next o prev de una página",
+		}},
+	)
+}
@@ -0,0 +1,58 @@
+// Package dbtest opens the databases of the tests
+package dbtest
+
+import (
+	"context"
+	"github.com/ncostamagna/axul-user/pkg/bootstrap"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"math"
+	"os"
+	"testing"
+)
+
+// Open returns a migrated database for a test, an in-memory SQLite
+// database unless TEST_DATABASE_DRIVER is mysql or postgres, then the
+// DATABASE_* variables of bootstrap.DBConnection are used and the
+// migrations are reverted when the test ends
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	driver := os.Getenv("TEST_DATABASE_DRIVER")
+	if driver == "" {
+		driver = "sqlite"
+		t.Setenv("DATABASE_NAME", "")
+	}
+	t.Setenv("DATABASE_DRIVER", driver)
+
+	db, err := bootstrap.DBConnection()
+	if err != nil {
+		t.Fatalf("open %s database: %v", driver, err)
+	}
+	db = db.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Silent)})
+
+	migrator, err := bootstrap.NewMigrator(db, loghub.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrate %s database: %v", driver, err)
+	}
+
+	t.Cleanup(func() {
+		if driver != "sqlite" {
+			if _, err := migrator.Down(ctx, math.MaxInt); err != nil {
+				t.Errorf("revert %s database: %v", driver, err)
+			}
+		}
+
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+
+	return db
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/ncostamagna/axul-user/internal/user/accesstoken"
+	"net/http"
+)
+
+func NewHTTPAccessTokenServer(_ context.Context, r http.Handler, endpoints accesstoken.Endpoints) http.Handler {
+
+	router := engine(r)
+
+	opts := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(encodeError),
+	}
+
+	router.POST("/users/:id/tokens", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Create),
+		decodeAccessTokenCreateHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	router.GET("/users/:id/tokens", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.GetAll),
+		decodeAccessTokenHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	router.DELETE("/users/:id/tokens/:token", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Revoke),
+		decodeAccessTokenHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	return router
+
+}
+
+func decodeAccessTokenCreateHandler(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req accesstoken.CreateReq
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	pp := ctx.Value("params").(gin.Params)
+	req.ID = pp.ByName("id")
+	req.Authorization = authorization(ctx)
+
+	return req, nil
+}
+
+func decodeAccessTokenHandler(ctx context.Context, r *http.Request) (interface{}, error) {
+	pp := ctx.Value("params").(gin.Params)
+	req := accesstoken.UserReq{
+		ID:            pp.ByName("id"),
+		TokenID:       pp.ByName("token"),
+		Authorization: authorization(ctx),
+	}
+
+	return req, nil
+}
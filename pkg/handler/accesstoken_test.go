@@ -0,0 +1,37 @@
+package handler_test
+
+import (
+	"testing"
+)
+
+type accessTokenBody struct {
+	Name      string   `json:"name,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+	ExpiresIn int      `json:"expires_in,omitempty"`
+}
+
+func TestAccessTokens(t *testing.T) {
+	s := newServer(t)
+	id, token := s.user(t, john)
+	tokens := "/users/" + id + "/tokens"
+
+	s.do(t, call{Name: "access_tokens/create_without_token", Method: "POST", Path: tokens, Body: accessTokenBody{Name: "ci", Scopes: []string{"users:read"}}})
+	s.do(t, call{Name: "access_tokens/create_without_name", Method: "POST", Path: tokens, Token: token, Body: accessTokenBody{Scopes: []string{"users:read"}}})
+	s.do(t, call{Name: "access_tokens/create_invalid_scope", Method: "POST", Path: tokens, Token: token, Body: accessTokenBody{Name: "ci", Scopes: []string{"users:admin"}}})
+
+	var created struct {
+		ID    string `json:"id"`
+		Token string `json:"token"`
+	}
+	s.do(t, call{Name: "access_tokens/create", Method: "POST", Path: tokens, Token: token, Body: accessTokenBody{Name: "ci", Scopes: []string{"users:read"}, ExpiresIn: 30}}).decode(t, &created)
+	s.do(t, call{Name: "access_tokens/list", Method: "GET", Path: tokens, Token: token})
+
+	// the access token only has the scopes it was created with
+	s.do(t, call{Name: "access_tokens/get_user", Method: "GET", Path: "/users/" + id, Token: created.Token})
+	s.do(t, call{Name: "access_tokens/update_user", Method: "PATCH", Path: "/users/" + id, Token: created.Token, Header: ifMatch("*"), Body: storeBody{FirstName: "Johnny"}})
+	s.do(t, call{Name: "access_tokens/list_with_access_token", Method: "GET", Path: tokens, Token: created.Token})
+
+	s.do(t, call{Name: "access_tokens/revoke", Method: "DELETE", Path: tokens + "/" + created.ID, Token: token})
+	s.do(t, call{Name: "access_tokens/revoke_not_found", Method: "DELETE", Path: tokens + "/" + created.ID, Token: token})
+	s.do(t, call{Name: "access_tokens/get_user_revoked", Method: "GET", Path: "/users/" + id, Token: created.Token})
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/ncostamagna/axul-user/internal/user/attribute"
+	"io"
+	"net/http"
+)
+
+func NewHTTPAttributeServer(_ context.Context, r http.Handler, endpoints attribute.Endpoints) http.Handler {
+
+	router := engine(r)
+
+	opts := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(encodeError),
+	}
+
+	router.GET("/users/:id/apps/:app/attributes", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Get),
+		decodeAttributeHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	router.PATCH("/users/:id/apps/:app/attributes", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Patch),
+		decodeAttributePatchHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	return router
+
+}
+
+func decodeAttributeHandler(ctx context.Context, r *http.Request) (interface{}, error) {
+	pp := ctx.Value("params").(gin.Params)
+	req := attribute.GetReq{
+		ID:            pp.ByName("id"),
+		App:           pp.ByName("app"),
+		Authorization: authorization(ctx),
+	}
+
+	return req, nil
+}
+
+// decodeAttributePatchHandler keeps the body as is, it's a JSON merge patch
+func decodeAttributePatchHandler(ctx context.Context, r *http.Request) (interface{}, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		return nil, invalidFormat(err)
+	}
+
+	pp := ctx.Value("params").(gin.Params)
+	req := attribute.PatchReq{
+		ID:            pp.ByName("id"),
+		App:           pp.ByName("app"),
+		Attributes:    body,
+		Authorization: authorization(ctx),
+	}
+
+	return req, nil
+}
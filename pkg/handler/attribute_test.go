@@ -0,0 +1,25 @@
+package handler_test
+
+import (
+	"testing"
+)
+
+// the schema of the crm app is in testdata/schemas
+func TestAttributes(t *testing.T) {
+	s := newServer(t)
+	id, token := s.user(t, john)
+	_, other := s.user(t, storeBody{UserName: "jane", FirstName: "Jane", Password: "secret-password", Email: "jane@example.com"})
+	crm := "/users/" + id + "/apps/crm/attributes"
+
+	s.do(t, call{Name: "attributes/get_empty", Method: "GET", Path: crm, Token: token})
+	s.do(t, call{Name: "attributes/get_unknown_app", Method: "GET", Path: "/users/" + id + "/apps/erp/attributes", Token: token})
+
+	s.do(t, call{Name: "attributes/patch_without_token", Method: "PATCH", Path: crm, Body: map[string]interface{}{"segment": "retail"}})
+	s.do(t, call{Name: "attributes/patch_another_user", Method: "PATCH", Path: crm, Token: other, Body: map[string]interface{}{"segment": "retail"}})
+	s.do(t, call{Name: "attributes/patch", Method: "PATCH", Path: crm, Token: token, Body: map[string]interface{}{"segment": "retail", "seats": 5}})
+	s.do(t, call{Name: "attributes/patch_merge", Method: "PATCH", Path: crm, Token: token, Body: map[string]interface{}{"seats": 10}})
+	s.do(t, call{Name: "attributes/patch_invalid", Method: "PATCH", Path: crm, Token: token, Body: map[string]interface{}{"seats": 0}})
+	s.do(t, call{Name: "attributes/patch_not_object", Method: "PATCH", Path: crm, Token: token, Body: `["retail"]`})
+
+	s.do(t, call{Name: "attributes/get", Method: "GET", Path: crm, Token: token})
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/ncostamagna/axul-user/internal/user/emailchange"
+	"net/http"
+)
+
+func NewHTTPEmailChangeServer(_ context.Context, r http.Handler, endpoints emailchange.Endpoints) http.Handler {
+
+	router := engine(r)
+
+	opts := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(encodeError),
+	}
+
+	router.POST("/users/email/confirm", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Confirm),
+		decodeEmailChangeHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	router.POST("/users/email/cancel", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Cancel),
+		decodeEmailChangeHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	return router
+
+}
+
+func decodeEmailChangeHandler(_ context.Context, r *http.Request) (interface{}, error) {
+	var req emailchange.TokenReq
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
@@ -0,0 +1,39 @@
+package handler_test
+
+import (
+	"testing"
+
+	"github.com/ncostamagna/axul-user/pkg/mailer"
+)
+
+// changeTokens returns the confirm and the cancel tokens of the last email
+// change in messages
+func changeTokens(t *testing.T, messages []mailer.Message) (string, string) {
+	t.Helper()
+
+	if len(messages) < 2 {
+		t.Fatalf("%d emails were sent, want the confirmation and the cancel", len(messages))
+	}
+
+	messages = messages[len(messages)-2:]
+	return secret(t, loginToken, messages[0].Body), secret(t, loginToken, messages[1].Body)
+}
+
+func TestEmailChange(t *testing.T) {
+	s := newServer(t)
+	id, token := s.user(t, john)
+
+	s.do(t, call{Name: "email_change/request", Method: "PATCH", Path: "/users/" + id, Token: token, Header: ifMatch("*"), Body: map[string]string{"email": "johnny@example.com"}})
+	confirm, cancel := changeTokens(t, s.inbox.Messages())
+
+	s.do(t, call{Name: "email_change/cancel_without_token", Method: "POST", Path: "/users/email/cancel", Body: map[string]string{}})
+	s.do(t, call{Name: "email_change/cancel", Method: "POST", Path: "/users/email/cancel", Body: map[string]string{"token": cancel}})
+	s.do(t, call{Name: "email_change/confirm_canceled", Method: "POST", Path: "/users/email/confirm", Body: map[string]string{"token": confirm}})
+
+	s.do(t, call{Name: "email_change/request_again", Method: "PATCH", Path: "/users/" + id, Token: token, Header: ifMatch("*"), Body: map[string]string{"email": "johnny@example.com"}})
+	confirm, _ = changeTokens(t, s.inbox.Messages())
+
+	s.do(t, call{Name: "email_change/confirm_invalid", Method: "POST", Path: "/users/email/confirm", Body: map[string]string{"token": "unknown.secret"}})
+	s.do(t, call{Name: "email_change/confirm", Method: "POST", Path: "/users/email/confirm", Body: map[string]string{"token": confirm}})
+	s.do(t, call{Name: "email_change/confirm_again", Method: "POST", Path: "/users/email/confirm", Body: map[string]string{"token": confirm}})
+}
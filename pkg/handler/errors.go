@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/ncostamagna/axul-user/pkg/i18n"
+	"github.com/ncostamagna/go-http-utils/response"
+	"net/http"
+)
+
+// NewHTTPErrorServer serves the catalogue of the error codes with their
+// messages in every language
+func NewHTTPErrorServer(_ context.Context, r http.Handler) http.Handler {
+
+	router := engine(r)
+
+	opts := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(encodeError),
+	}
+
+	router.GET("/errors", gin.WrapH(httptransport.NewServer(
+		func(_ context.Context, _ interface{}) (interface{}, error) {
+			return response.OK("", i18n.Catalog(), nil), nil
+		},
+		httptransport.NopRequestDecoder,
+		encodeResponse,
+		opts...,
+	)))
+
+	return router
+
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ncostamagna/axul-user/pkg/apierror"
+	"github.com/ncostamagna/axul-user/pkg/i18n"
+	"github.com/ncostamagna/go-http-utils/response"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// engine returns the gin engine of r to add the routes. When r is nil or
+// another handler, like a middleware around the engine, a new engine is
+// created with the middlewares of the decoders and the requests of the
+// routes it doesn't have go to r
+func engine(r http.Handler) *gin.Engine {
+	if router, ok := r.(*gin.Engine); ok {
+		return router
+	}
+
+	router := gin.Default()
+	router.Use(ginRequestID(), ginDecode(), ginLanguage(nil))
+	if r != nil {
+		router.NoRoute(gin.WrapH(r))
+	}
+
+	return router
+}
+
+// versioned is the data of the responses with the version of the
+// resource, it's sent in the ETag header
+type versioned interface {
+	GetVersion() int
+}
+
+// data returns the data of r, response.OK keeps a pointer to it
+func data(r response.Response) interface{} {
+	if p, ok := r.GetData().(*interface{}); ok && p != nil {
+		return *p
+	}
+	return r.GetData()
+}
+
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// ifMatch returns the version of the If-Match header, 0 for "*". The
+// header is required and it must be a single ETag of a GET response, the
+// weak ETags are accepted because the proxies weaken them when they
+// compress the responses
+func ifMatch(r *http.Request) (int, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" {
+		return 0, apierror.PreconditionRequired(i18n.NewError("IF_MATCH_REQUIRED", "the If-Match header is required, send the ETag of the last GET"))
+	}
+
+	if value == "*" {
+		return 0, nil
+	}
+
+	tag, err := strconv.Unquote(strings.TrimPrefix(value, "W/"))
+	if err == nil {
+		if version, err := strconv.Atoi(tag); err == nil && version > 0 {
+			return version, nil
+		}
+	}
+
+	return 0, apierror.PreconditionFailed(i18n.NewError("IF_MATCH_INVALID", "the If-Match header %s isn't an ETag of this resource", value))
+}
+
+// invalidFormat is the response of a body that can't be decoded
+func invalidFormat(err error) error {
+	return apierror.BadRequest(i18n.NewError("INVALID_REQUEST_FORMAT", "invalid request format: '%v'", err.Error()))
+}
+
+// AccessControl adds the CORS headers and answers the preflight requests
+func AccessControl(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS, HEAD")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept,Authorization,Cache-Control,Content-Type,DNT,If-Match,If-Modified-Since,Keep-Alive,Origin,User-Agent,X-Requested-With")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag,X-Request-ID")
+
+		if r.Method == "OPTIONS" {
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/ncostamagna/axul-user/internal/user/identity"
+	"github.com/ncostamagna/go-http-utils/response"
+	"net/http"
+)
+
+func NewHTTPIdentityServer(_ context.Context, r http.Handler, endpoints identity.Endpoints) http.Handler {
+
+	router := engine(r)
+
+	opts := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(encodeError),
+	}
+
+	router.GET("/users/login/:provider", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Login),
+		decodeProviderHandler,
+		encodeRedirect,
+		opts...,
+	)))
+
+	router.GET("/users/login/:provider/callback", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Callback),
+		decodeCallbackHandler,
+		encodeCallback,
+		opts...,
+	)))
+
+	router.GET("/users/:id/identities", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.GetAll),
+		decodeIdentityHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	router.POST("/users/:id/identities/:provider", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Link),
+		decodeIdentityHandler,
+		encodeLink,
+		opts...,
+	)))
+
+	router.DELETE("/users/:id/identities/:provider", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Unlink),
+		decodeIdentityHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	return router
+
+}
+
+func decodeProviderHandler(ctx context.Context, r *http.Request) (interface{}, error) {
+	pp := ctx.Value("params").(gin.Params)
+	req := identity.RedirectReq{
+		Provider: pp.ByName("provider"),
+	}
+
+	return req, nil
+}
+
+func decodeCallbackHandler(ctx context.Context, r *http.Request) (interface{}, error) {
+	pp := ctx.Value("params").(gin.Params)
+	v := r.URL.Query()
+
+	req := identity.CallbackReq{
+		Provider: pp.ByName("provider"),
+		Code:     v.Get("code"),
+		State:    v.Get("state"),
+	}
+
+	if c, err := r.Cookie(nonceCookie); err == nil {
+		req.Nonce = c.Value
+	}
+
+	return req, nil
+}
+
+func decodeIdentityHandler(ctx context.Context, r *http.Request) (interface{}, error) {
+	pp := ctx.Value("params").(gin.Params)
+	req := identity.UserReq{
+		ID:            pp.ByName("id"),
+		Provider:      pp.ByName("provider"),
+		Authorization: authorization(ctx),
+	}
+
+	return req, nil
+}
+
+func encodeRedirect(_ context.Context, w http.ResponseWriter, resp interface{}) error {
+	r := resp.(identity.Redirect)
+	setNonce(w, r.Nonce, int(identity.StateDuration.Seconds()))
+	w.Header().Set("Location", r.URL)
+	w.WriteHeader(http.StatusFound)
+	return nil
+}
+
+func encodeLink(ctx context.Context, w http.ResponseWriter, resp interface{}) error {
+	r := data(resp.(response.Response)).(identity.Redirect)
+	setNonce(w, r.Nonce, int(identity.StateDuration.Seconds()))
+	return encodeResponse(ctx, w, resp)
+}
+
+// encodeCallback removes the nonce cookie, the state was consumed
+func encodeCallback(ctx context.Context, w http.ResponseWriter, resp interface{}) error {
+	setNonce(w, "", -1)
+	return encodeResponse(ctx, w, resp)
+}
+
+// nonceCookie binds the state of a login to the browser, it's only sent
+// to the callback
+const nonceCookie = "identity_nonce"
+
+func setNonce(w http.ResponseWriter, nonce string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     nonceCookie,
+		Value:    nonce,
+		Path:     "/users/login/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
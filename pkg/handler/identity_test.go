@@ -0,0 +1,79 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// authorize is the login of Jane in the provider of the redirect, code
+// gets an ID token with the nonce of the redirect. It returns the path of
+// the callback and the nonce cookie of res
+func (s *server) authorize(t *testing.T, redirect, code string, res result) (string, string) {
+	t.Helper()
+
+	u, err := url.Parse(redirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.idp.Code(code, u.Query().Get("nonce"))
+
+	var cookie string
+	for _, c := range (&http.Response{Header: res.Header}).Cookies() {
+		if c.Name == "identity_nonce" {
+			cookie = c.Name + "=" + c.Value
+		}
+	}
+
+	return "/users/login/oidc/callback?" + url.Values{"code": {code}, "state": {u.Query().Get("state")}}.Encode(), cookie
+}
+
+func TestIdentity(t *testing.T) {
+	s := newServer(t)
+	id, token := s.user(t, john)
+	identities := "/users/" + id + "/identities"
+
+	s.do(t, call{Name: "identity/link_without_token", Method: "POST", Path: identities + "/oidc"})
+	s.do(t, call{Name: "identity/link_unknown_provider", Method: "POST", Path: identities + "/unknown", Token: token})
+
+	var link struct {
+		URL string `json:"url"`
+	}
+	res := s.do(t, call{Name: "identity/link", Method: "POST", Path: identities + "/oidc", Token: token})
+	res.decode(t, &link)
+	callback, cookie := s.authorize(t, link.URL, "link", res)
+	s.do(t, call{Name: "identity/link_callback", Method: "GET", Path: callback, Header: map[string]string{"Cookie": cookie}})
+	s.do(t, call{Name: "identity/list", Method: "GET", Path: identities, Token: token})
+
+	// the provider logs in the linked user, the state only works once and
+	// with the cookie of the browser that started the login
+	s.do(t, call{Name: "identity/login_unknown_provider", Method: "GET", Path: "/users/login/unknown"})
+	res = s.do(t, call{Name: "identity/login", Method: "GET", Path: "/users/login/oidc"})
+	callback, cookie = s.authorize(t, res.Header.Get("Location"), "login", res)
+	s.do(t, call{Name: "identity/login_callback_without_code", Method: "GET", Path: "/users/login/oidc/callback"})
+	s.do(t, call{Name: "identity/login_callback_without_cookie", Method: "GET", Path: callback})
+	s.do(t, call{Name: "identity/login_callback", Method: "GET", Path: callback, Header: map[string]string{"Cookie": cookie}})
+	s.do(t, call{Name: "identity/login_callback_replay", Method: "GET", Path: callback, Header: map[string]string{"Cookie": cookie}})
+
+	s.do(t, call{Name: "identity/unlink", Method: "DELETE", Path: identities + "/oidc", Token: token})
+	s.do(t, call{Name: "identity/unlink_not_found", Method: "DELETE", Path: identities + "/oidc", Token: token})
+
+	// without a linked user the provider login creates Jane, the identity
+	// is the only login of the new user
+	res = s.do(t, call{Name: "identity/signup", Method: "GET", Path: "/users/login/oidc"})
+	callback, cookie = s.authorize(t, res.Header.Get("Location"), "signup", res)
+
+	var jane struct {
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+		Token string `json:"token"`
+	}
+	s.do(t, call{Name: "identity/signup_callback", Method: "GET", Path: callback, Header: map[string]string{"Cookie": cookie}}).decode(t, &jane)
+	s.do(t, call{Name: "identity/unlink_last", Method: "DELETE", Path: "/users/" + jane.User.ID + "/identities/oidc", Token: jane.Token})
+
+	res = s.do(t, call{Name: "identity/link_again", Method: "POST", Path: identities + "/oidc", Token: token})
+	res.decode(t, &link)
+	callback, cookie = s.authorize(t, link.URL, "link-again", res)
+	s.do(t, call{Name: "identity/link_taken", Method: "GET", Path: callback, Header: map[string]string{"Cookie": cookie}})
+}
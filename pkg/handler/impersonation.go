@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/ncostamagna/axul-user/internal/user/impersonation"
+	"net/http"
+)
+
+func NewHTTPImpersonationServer(_ context.Context, r http.Handler, endpoints impersonation.Endpoints) http.Handler {
+
+	router := engine(r)
+
+	opts := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(encodeError),
+	}
+
+	router.POST("/users/:id/impersonate", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Start),
+		decodeImpersonateHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	router.DELETE("/users/:id/impersonate", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Stop),
+		decodeImpersonationHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	router.GET("/users/:id/impersonations", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.GetAll),
+		decodeImpersonationHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	return router
+
+}
+
+func decodeImpersonateHandler(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req impersonation.StartReq
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	pp := ctx.Value("params").(gin.Params)
+	req.ID = pp.ByName("id")
+	req.Authorization = authorization(ctx)
+
+	return req, nil
+}
+
+func decodeImpersonationHandler(ctx context.Context, r *http.Request) (interface{}, error) {
+	pp := ctx.Value("params").(gin.Params)
+	req := impersonation.UserReq{
+		ID:            pp.ByName("id"),
+		Authorization: authorization(ctx),
+	}
+
+	return req, nil
+}
@@ -0,0 +1,34 @@
+package handler_test
+
+import (
+	"testing"
+)
+
+func TestImpersonation(t *testing.T) {
+	s := newServer(t)
+	id, token := s.user(t, john)
+	adminID, admin := s.admin(t)
+	impersonate := "/users/" + id + "/impersonate"
+
+	s.do(t, call{Name: "impersonation/start_without_token", Method: "POST", Path: impersonate, Body: map[string]string{"reason": "ticket 42"}})
+	s.do(t, call{Name: "impersonation/start_not_admin", Method: "POST", Path: "/users/" + adminID + "/impersonate", Token: token, Body: map[string]string{"reason": "ticket 42"}})
+	s.do(t, call{Name: "impersonation/start_without_reason", Method: "POST", Path: impersonate, Token: admin, Body: map[string]string{}})
+	s.do(t, call{Name: "impersonation/start_self", Method: "POST", Path: "/users/" + adminID + "/impersonate", Token: admin, Body: map[string]string{"reason": "ticket 42"}})
+
+	var started struct {
+		Token string `json:"token"`
+	}
+	s.do(t, call{Name: "impersonation/start", Method: "POST", Path: impersonate, Token: admin, Body: map[string]string{"reason": "ticket 42"}}).decode(t, &started)
+
+	// the admin acts as the user but can't impersonate anyone else with
+	// the impersonation token
+	s.do(t, call{Name: "impersonation/get_user", Method: "GET", Path: "/users/" + id, Token: started.Token})
+	s.do(t, call{Name: "impersonation/start_nested", Method: "POST", Path: "/users/" + adminID + "/impersonate", Token: started.Token, Body: map[string]string{"reason": "ticket 42"}})
+
+	s.do(t, call{Name: "impersonation/list_not_admin", Method: "GET", Path: "/users/" + id + "/impersonations", Token: token})
+	s.do(t, call{Name: "impersonation/list", Method: "GET", Path: "/users/" + id + "/impersonations", Token: admin})
+
+	s.do(t, call{Name: "impersonation/stop", Method: "DELETE", Path: impersonate, Token: started.Token})
+	s.do(t, call{Name: "impersonation/stop_again", Method: "DELETE", Path: impersonate, Token: admin})
+	s.do(t, call{Name: "impersonation/get_user_stopped", Method: "GET", Path: "/users/" + id, Token: started.Token})
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/ncostamagna/axul-user/internal/user/passkey"
+	"net/http"
+)
+
+func NewHTTPPasskeyServer(_ context.Context, r http.Handler, endpoints passkey.Endpoints) http.Handler {
+
+	router := engine(r)
+
+	opts := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(encodeError),
+	}
+
+	router.POST("/users/:id/passkeys/registration", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.BeginRegistration),
+		decodePasskeyHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	router.POST("/users/:id/passkeys", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.FinishRegistration),
+		decodePasskeyRegisterHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	router.GET("/users/:id/passkeys", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.GetAll),
+		decodePasskeyHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	router.PATCH("/users/:id/passkeys/:passkey", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Rename),
+		decodePasskeyRenameHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	router.DELETE("/users/:id/passkeys/:passkey", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Delete),
+		decodePasskeyHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	router.POST("/users/login/passkey", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.BeginLogin),
+		decodeNothing,
+		encodeResponse,
+		opts...,
+	)))
+
+	router.POST("/users/login/passkey/verify", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.FinishLogin),
+		decodePasskeyLoginHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	return router
+
+}
+
+func decodePasskeyHandler(ctx context.Context, r *http.Request) (interface{}, error) {
+	pp := ctx.Value("params").(gin.Params)
+	req := passkey.UserReq{
+		ID:            pp.ByName("id"),
+		Passkey:       pp.ByName("passkey"),
+		Authorization: authorization(ctx),
+	}
+
+	return req, nil
+}
+
+func decodePasskeyRegisterHandler(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req passkey.RegisterReq
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	pp := ctx.Value("params").(gin.Params)
+	req.ID = pp.ByName("id")
+	req.Authorization = authorization(ctx)
+
+	return req, nil
+}
+
+func decodePasskeyRenameHandler(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req passkey.RenameReq
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	pp := ctx.Value("params").(gin.Params)
+	req.ID = pp.ByName("id")
+	req.Passkey = pp.ByName("passkey")
+	req.Authorization = authorization(ctx)
+
+	return req, nil
+}
+
+func decodePasskeyLoginHandler(_ context.Context, r *http.Request) (interface{}, error) {
+	var req passkey.LoginReq
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func decodeNothing(_ context.Context, _ *http.Request) (interface{}, error) {
+	return nil, nil
+}
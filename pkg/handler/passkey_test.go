@@ -0,0 +1,198 @@
+package handler_test
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+)
+
+// the flags of the authenticator data, the user was present and verified
+// and the data has the attested credential
+const (
+	flagUP = 0x01
+	flagUV = 0x04
+	flagAT = 0x40
+)
+
+// authenticator is a passkey in software with a none attestation, its key
+// and its credential id are fixed so the requests only change in the
+// challenge and the signature
+type authenticator struct {
+	key        *ecdsa.PrivateKey
+	id         []byte
+	userHandle string
+	count      uint32
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	t.Helper()
+
+	d := bytes.Repeat([]byte{1}, 32)
+	priv, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the public key is the uncompressed point, 0x04 || X || Y
+	pub := priv.PublicKey().Bytes()
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(pub[1:33]), Y: new(big.Int).SetBytes(pub[33:])},
+		D:         new(big.Int).SetBytes(d),
+	}
+
+	return &authenticator{key: key, id: []byte("passkey-of-john")}
+}
+
+// options are the WebAuthn options of a registration or a login, only
+// what the authenticator uses
+type options struct {
+	SessionID string `json:"session_id"`
+	Options   struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+			User      struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		} `json:"publicKey"`
+	} `json:"options"`
+}
+
+func (a *authenticator) clientData(t *testing.T, kind, challenge string) []byte {
+	t.Helper()
+
+	b, err := json.Marshal(map[string]string{"type": kind, "challenge": challenge, "origin": origin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func (a *authenticator) authData(flags byte, attested []byte) []byte {
+	rp := sha256.Sum256([]byte("localhost"))
+	data := append(rp[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.count)
+	return append(data, attested...)
+}
+
+func (a *authenticator) credential(t *testing.T, response map[string][]byte) json.RawMessage {
+	t.Helper()
+
+	encoded := map[string]string{}
+	for k, v := range response {
+		encoded[k] = base64.RawURLEncoding.EncodeToString(v)
+	}
+
+	id := base64.RawURLEncoding.EncodeToString(a.id)
+	b, err := json.Marshal(map[string]interface{}{"id": id, "rawId": id, "type": "public-key", "response": encoded})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// create is the answer to the options of a registration
+func (a *authenticator) create(t *testing.T, o options) json.RawMessage {
+	t.Helper()
+
+	a.userHandle = o.Options.PublicKey.User.ID
+
+	pub := a.key.PublicKey
+	key, err := webauthncbor.Marshal(map[int]interface{}{1: 2, 3: -7, -1: 1, -2: pub.X.FillBytes(make([]byte, 32)), -3: pub.Y.FillBytes(make([]byte, 32))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a zero AAGUID, the length of the credential id, the id and the key
+	attested := binary.BigEndian.AppendUint16(make([]byte, 16), uint16(len(a.id)))
+	attested = append(append(attested, a.id...), key...)
+
+	object, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authData(flagUP|flagUV|flagAT, attested),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.credential(t, map[string][]byte{
+		"clientDataJSON":    a.clientData(t, "webauthn.create", o.Options.PublicKey.Challenge),
+		"attestationObject": object,
+	})
+}
+
+// get is the answer to the options of a login, the signature counter
+// grows in every login
+func (a *authenticator) get(t *testing.T, o options) json.RawMessage {
+	t.Helper()
+
+	a.count++
+	data := a.authData(flagUP|flagUV, nil)
+	client := a.clientData(t, "webauthn.get", o.Options.PublicKey.Challenge)
+
+	hash := sha256.Sum256(client)
+	digest := sha256.Sum256(append(append([]byte(nil), data...), hash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handle, err := base64.RawURLEncoding.DecodeString(a.userHandle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.credential(t, map[string][]byte{
+		"clientDataJSON":    client,
+		"authenticatorData": data,
+		"signature":         signature,
+		"userHandle":        handle,
+	})
+}
+
+func TestPasskeys(t *testing.T) {
+	s := newServer(t)
+	id, token := s.user(t, john)
+	passkeys := "/users/" + id + "/passkeys"
+	a := newAuthenticator(t)
+
+	s.do(t, call{Name: "passkeys/begin_registration_without_token", Method: "POST", Path: passkeys + "/registration"})
+
+	var registration options
+	s.do(t, call{Name: "passkeys/begin_registration", Method: "POST", Path: passkeys + "/registration", Token: token}).decode(t, &registration)
+	credential := a.create(t, registration)
+	s.do(t, call{Name: "passkeys/register_without_session", Method: "POST", Path: passkeys, Token: token, Body: map[string]interface{}{"credential": credential}})
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	s.do(t, call{Name: "passkeys/register", Method: "POST", Path: passkeys, Token: token, Body: map[string]interface{}{"session_id": registration.SessionID, "name": "Laptop", "credential": credential}}).decode(t, &created)
+	s.do(t, call{Name: "passkeys/register_again", Method: "POST", Path: passkeys, Token: token, Body: map[string]interface{}{"session_id": registration.SessionID, "credential": credential}})
+
+	s.do(t, call{Name: "passkeys/rename_without_name", Method: "PATCH", Path: passkeys + "/" + created.ID, Token: token, Body: map[string]string{}})
+	s.do(t, call{Name: "passkeys/rename", Method: "PATCH", Path: passkeys + "/" + created.ID, Token: token, Body: map[string]string{"name": "Work laptop"}})
+	s.do(t, call{Name: "passkeys/list", Method: "GET", Path: passkeys, Token: token})
+
+	// the login is discoverable, the passkey tells who the user is
+	var login options
+	s.do(t, call{Name: "passkeys/begin_login", Method: "POST", Path: "/users/login/passkey"}).decode(t, &login)
+	credential = a.get(t, login)
+	s.do(t, call{Name: "passkeys/login", Method: "POST", Path: "/users/login/passkey/verify", Body: map[string]interface{}{"session_id": login.SessionID, "credential": credential}})
+	s.do(t, call{Name: "passkeys/login_again", Method: "POST", Path: "/users/login/passkey/verify", Body: map[string]interface{}{"session_id": login.SessionID, "credential": credential}})
+
+	s.do(t, call{Name: "passkeys/delete", Method: "DELETE", Path: passkeys + "/" + created.ID, Token: token})
+	s.do(t, call{Name: "passkeys/delete_not_found", Method: "DELETE", Path: passkeys + "/" + created.ID, Token: token})
+
+	s.do(t, call{Name: "passkeys/begin_login_deleted", Method: "POST", Path: "/users/login/passkey"}).decode(t, &login)
+	s.do(t, call{Name: "passkeys/login_deleted", Method: "POST", Path: "/users/login/passkey/verify", Body: map[string]interface{}{"session_id": login.SessionID, "credential": a.get(t, login)}})
+}
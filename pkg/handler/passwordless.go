@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/ncostamagna/axul-user/internal/user/passwordless"
+	"net/http"
+)
+
+func NewHTTPPasswordlessServer(_ context.Context, r http.Handler, endpoints passwordless.Endpoints) http.Handler {
+
+	router := engine(r)
+
+	opts := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(encodeError),
+	}
+
+	router.POST("/users/login/magic", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Request),
+		decodeMagicHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	router.POST("/users/login/magic/verify", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Verify),
+		decodeMagicVerifyHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	return router
+
+}
+
+func decodeMagicHandler(_ context.Context, r *http.Request) (interface{}, error) {
+	var req passwordless.MagicReq
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	if req.Device == "" {
+		req.Device = r.Header.Get("X-Device-ID")
+	}
+
+	return req, nil
+}
+
+func decodeMagicVerifyHandler(_ context.Context, r *http.Request) (interface{}, error) {
+	var req passwordless.VerifyReq
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	if req.Device == "" {
+		req.Device = r.Header.Get("X-Device-ID")
+	}
+
+	return req, nil
+}
@@ -0,0 +1,59 @@
+package handler_test
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+var (
+	loginCode  = regexp.MustCompile(`code is (\d{6})`)
+	loginToken = regexp.MustCompile(`token=(\S+)`)
+)
+
+// secret returns the first group of re in body, the secrets of the emails
+// and the SMS are query escaped in the links
+func secret(t *testing.T, re *regexp.Regexp, body string) string {
+	t.Helper()
+
+	m := re.FindStringSubmatch(body)
+	if m == nil {
+		t.Fatalf("%q doesn't have a secret", body)
+	}
+
+	v, err := url.QueryUnescape(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestPasswordless(t *testing.T) {
+	s := newServer(t)
+	s.user(t, john)
+
+	s.do(t, call{Name: "passwordless/request_without_device", Method: "POST", Path: "/users/login/magic", Body: map[string]string{"username": "john"}})
+	s.do(t, call{Name: "passwordless/request_invalid_method", Method: "POST", Path: "/users/login/magic", Body: map[string]string{"username": "john", "method": "sms", "device_id": "laptop"}})
+
+	var challenge struct {
+		ChallengeID string `json:"challenge_id"`
+	}
+	s.do(t, call{Name: "passwordless/request_code", Method: "POST", Path: "/users/login/magic", Body: map[string]string{"username": "john", "method": "code", "device_id": "laptop"}}).decode(t, &challenge)
+	code := secret(t, loginCode, s.inbox.Wait(t, 1)[0].Body)
+
+	// an unknown user gets the same answer and no email
+	s.do(t, call{Name: "passwordless/request_unknown_user", Method: "POST", Path: "/users/login/magic", Body: map[string]string{"username": "nobody", "method": "code", "device_id": "laptop"}})
+
+	s.do(t, call{Name: "passwordless/verify_without_code", Method: "POST", Path: "/users/login/magic/verify", Body: map[string]string{"challenge_id": challenge.ChallengeID, "device_id": "laptop"}})
+	s.do(t, call{Name: "passwordless/verify_another_device", Method: "POST", Path: "/users/login/magic/verify", Body: map[string]string{"challenge_id": challenge.ChallengeID, "code": code, "device_id": "phone"}})
+	s.do(t, call{Name: "passwordless/verify_code", Method: "POST", Path: "/users/login/magic/verify", Body: map[string]string{"challenge_id": challenge.ChallengeID, "code": code, "device_id": "laptop"}})
+	s.do(t, call{Name: "passwordless/verify_code_again", Method: "POST", Path: "/users/login/magic/verify", Body: map[string]string{"challenge_id": challenge.ChallengeID, "code": code, "device_id": "laptop"}})
+
+	s.do(t, call{Name: "passwordless/request_link", Method: "POST", Path: "/users/login/magic", Body: map[string]string{"username": "john@example.com", "device_id": "laptop"}})
+	token := secret(t, loginToken, s.inbox.Wait(t, 2)[1].Body)
+	s.do(t, call{Name: "passwordless/verify_link", Method: "POST", Path: "/users/login/magic/verify", Body: map[string]string{"token": token, "device_id": "laptop"}})
+
+	if n := s.inbox.Len(); n != 2 {
+		t.Errorf("%d emails were sent, want the code and the link of john", n)
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/ncostamagna/axul-user/internal/user/phoneverify"
+	"net/http"
+)
+
+func NewHTTPPhoneVerifyServer(_ context.Context, r http.Handler, endpoints phoneverify.Endpoints) http.Handler {
+
+	router := engine(r)
+
+	opts := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(encodeError),
+	}
+
+	router.POST("/users/:id/phone/verification", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Send),
+		decodePhoneSendHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	router.POST("/users/:id/phone/verify", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Verify),
+		decodePhoneVerifyHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	return router
+
+}
+
+func decodePhoneSendHandler(ctx context.Context, r *http.Request) (interface{}, error) {
+	pp := ctx.Value("params").(gin.Params)
+	req := phoneverify.UserReq{
+		ID:            pp.ByName("id"),
+		Authorization: authorization(ctx),
+	}
+
+	return req, nil
+}
+
+func decodePhoneVerifyHandler(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req phoneverify.VerifyReq
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	pp := ctx.Value("params").(gin.Params)
+	req.ID = pp.ByName("id")
+	req.Authorization = authorization(ctx)
+
+	return req, nil
+}
@@ -0,0 +1,23 @@
+package handler_test
+
+import (
+	"testing"
+)
+
+func TestPhoneVerify(t *testing.T) {
+	s := newServer(t)
+	id, token := s.user(t, john)
+	phone := "/users/" + id + "/phone"
+
+	s.do(t, call{Name: "phone_verify/send_without_token", Method: "POST", Path: phone + "/verification"})
+	s.do(t, call{Name: "phone_verify/send", Method: "POST", Path: phone + "/verification", Token: token})
+	code := secret(t, loginCode, s.phone.Messages()[0])
+
+	s.do(t, call{Name: "phone_verify/verify_without_code", Method: "POST", Path: phone + "/verify", Token: token, Body: map[string]string{}})
+	s.do(t, call{Name: "phone_verify/verify_wrong_code", Method: "POST", Path: phone + "/verify", Token: token, Body: map[string]string{"code": "x"}})
+	s.do(t, call{Name: "phone_verify/verify", Method: "POST", Path: phone + "/verify", Token: token, Body: map[string]string{"code": code}})
+	s.do(t, call{Name: "phone_verify/send_verified", Method: "POST", Path: phone + "/verification", Token: token})
+
+	id, token = s.user(t, storeBody{UserName: "jane", FirstName: "Jane", Password: "secret-password", Email: "jane@example.com"})
+	s.do(t, call{Name: "phone_verify/send_without_phone", Method: "POST", Path: "/users/" + id + "/phone/verification", Token: token})
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/ncostamagna/axul-user/internal/user/photo"
+	"github.com/ncostamagna/axul-user/pkg/blob"
+	"io"
+	"net/http"
+)
+
+func NewHTTPPhotoServer(_ context.Context, r http.Handler, endpoints photo.Endpoints) http.Handler {
+
+	router := engine(r)
+
+	opts := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(encodeError),
+	}
+
+	router.PUT("/users/:id/photo", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Upload),
+		decodePhotoUploadHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	router.GET("/users/:id/photo", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Get),
+		decodePhotoHandler,
+		encodeBlob,
+		opts...,
+	)))
+
+	return router
+
+}
+
+// decodePhotoUploadHandler reads the "photo" file of the multipart form,
+// one byte over the limit is read so the service can reject it
+func decodePhotoUploadHandler(ctx context.Context, r *http.Request) (interface{}, error) {
+	r.Body = http.MaxBytesReader(nil, r.Body, photo.MaxUploadSize+1<<20)
+
+	f, _, err := r.FormFile("photo")
+	if err != nil {
+		return nil, invalidFormat(err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, photo.MaxUploadSize+1))
+	if err != nil {
+		return nil, invalidFormat(err)
+	}
+
+	pp := ctx.Value("params").(gin.Params)
+	req := photo.UploadReq{
+		ID:            pp.ByName("id"),
+		Data:          data,
+		Authorization: authorization(ctx),
+	}
+
+	return req, nil
+}
+
+func decodePhotoHandler(ctx context.Context, r *http.Request) (interface{}, error) {
+	pp := ctx.Value("params").(gin.Params)
+	req := photo.GetReq{
+		ID:      pp.ByName("id"),
+		Version: r.URL.Query().Get("v"),
+		Size:    r.URL.Query().Get("size"),
+	}
+
+	return req, nil
+}
+
+func encodeBlob(_ context.Context, w http.ResponseWriter, resp interface{}) error {
+	b := resp.(*blob.Blob)
+	w.Header().Set("Content-Type", b.ContentType)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(b.Data)
+	return err
+}
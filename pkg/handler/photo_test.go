@@ -0,0 +1,70 @@
+package handler_test
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/url"
+	"testing"
+)
+
+// upload is a multipart form with data in its photo field
+func upload(t *testing.T, data []byte) ([]byte, map[string]string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := w.SetBoundary("photo-boundary"); err != nil {
+		t.Fatal(err)
+	}
+
+	if data != nil {
+		f, err := w.CreateFormFile("photo", "photo.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), map[string]string{"Content-Type": w.FormDataContentType()}
+}
+
+func TestPhoto(t *testing.T) {
+	s := newServer(t)
+	id, token := s.user(t, john)
+	path := "/users/" + id + "/photo"
+
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewGray(image.Rect(0, 0, 100, 80))); err != nil {
+		t.Fatal(err)
+	}
+
+	body, header := upload(t, img.Bytes())
+	s.do(t, call{Name: "photo/upload_without_token", Method: "PUT", Path: path, Header: header, Body: body})
+	s.do(t, call{Name: "photo/get_without_photo", Method: "GET", Path: path})
+
+	var uploaded struct {
+		Photo string `json:"photo"`
+	}
+	s.do(t, call{Name: "photo/upload", Method: "PUT", Path: path, Token: token, Header: header, Body: body}).decode(t, &uploaded)
+
+	u, err := url.Parse(uploaded.Photo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.do(t, call{Name: "photo/get", Method: "GET", Path: u.RequestURI()})
+	s.do(t, call{Name: "photo/get_small", Method: "GET", Path: u.RequestURI() + "&size=small"})
+	s.do(t, call{Name: "photo/get_invalid_size", Method: "GET", Path: u.RequestURI() + "&size=huge"})
+
+	body, header = upload(t, []byte("not an image"))
+	s.do(t, call{Name: "photo/upload_invalid", Method: "PUT", Path: path, Token: token, Header: header, Body: body})
+
+	body, header = upload(t, nil)
+	s.do(t, call{Name: "photo/upload_without_photo", Method: "PUT", Path: path, Token: token, Header: header, Body: body})
+}
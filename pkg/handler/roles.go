@@ -2,24 +2,19 @@ package handler
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-kit/kit/endpoint"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/ncostamagna/axul-user/internal/user/role"
-	"github.com/ncostamagna/go-http-utils/response"
+	"github.com/ncostamagna/axul-user/pkg/apierror"
+	"github.com/ncostamagna/axul-user/pkg/cursor"
 	"net/http"
+	"strconv"
 )
 
 func NewHTTPRolesServer(_ context.Context, r http.Handler, endpoints role.Endpoints) http.Handler {
 
-	var router *gin.Engine
-	if r == nil {
-		router = gin.Default()
-	} else {
-		router = r.(*gin.Engine)
-	}
+	router := engine(r)
 
 	opts := []httptransport.ServerOption{
 		httptransport.ServerErrorEncoder(encodeError),
@@ -32,6 +27,13 @@ func NewHTTPRolesServer(_ context.Context, r http.Handler, endpoints role.Endpoi
 		opts...,
 	)))
 
+	router.GET("/users/:id/apps", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.GetAll),
+		decodeGetAllRolesHandler,
+		encodeResponse,
+		opts...,
+	)))
+
 	router.PUT("/users/:id/apps/:app", gin.WrapH(httptransport.NewServer(
 		endpoint.Endpoint(endpoints.AddRoles),
 		decodeAddRoleHandler,
@@ -52,25 +54,57 @@ func NewHTTPRolesServer(_ context.Context, r http.Handler, endpoints role.Endpoi
 
 func decodeAppStoreHandler(ctx context.Context, r *http.Request) (interface{}, error) {
 	var req role.AppReq
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, response.BadRequest(fmt.Sprintf("invalid request format: '%v'", err.Error()))
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
 	}
 
 	pp := ctx.Value("params").(gin.Params)
 	req.ID = pp.ByName("id")
+	req.Authorization = authorization(ctx)
+
+	return req, nil
+}
+
+func decodeGetAllRolesHandler(ctx context.Context, r *http.Request) (interface{}, error) {
+	v := r.URL.Query()
+
+	limit, _ := strconv.Atoi(v.Get("limit"))
+	page, _ := strconv.Atoi(v.Get("page"))
+
+	pp := ctx.Value("params").(gin.Params)
+	req := role.GetAllReq{
+		ID:    pp.ByName("id"),
+		Limit: limit,
+		Page:  page,
+	}
+
+	if c := v.Get("cursor"); c != "" {
+		var err error
+		if req.Cursor, err = cursor.Parse(c); err != nil {
+			return nil, apierror.BadRequest(err)
+		}
+	}
 
 	return req, nil
 }
 
 func decodeAddRoleHandler(ctx context.Context, r *http.Request) (interface{}, error) {
 	var req role.AddRoles
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, response.BadRequest(fmt.Sprintf("invalid request format: '%v'", err.Error()))
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
 	}
 
 	pp := ctx.Value("params").(gin.Params)
 	req.ID = pp.ByName("id")
 	req.App = pp.ByName("app")
+	req.Authorization = authorization(ctx)
+
+	version, err := ifMatch(r)
+	if err != nil {
+		return nil, err
+	}
+	req.Version = version
+
 	return req, nil
 }
 
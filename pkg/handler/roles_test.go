@@ -0,0 +1,113 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/internal/user/role"
+	"github.com/ncostamagna/axul-user/internal/user/role/roletest"
+	"github.com/ncostamagna/axul-user/internal/user/usertest"
+	"github.com/ncostamagna/axul-user/pkg/handler"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+)
+
+func TestRoles(t *testing.T) {
+	s := newServer(t)
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	s.do(t, call{Name: "roles/user", Method: "POST", Path: "/users", Body: john}).decode(t, &created)
+	apps := "/users/" + created.ID + "/apps"
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	s.do(t, call{Name: "roles/login", Method: "POST", Path: "/users/login", Body: map[string]string{"login": "john", "password": john.Password}}).decode(t, &login)
+	_, admin := s.admin(t)
+
+	s.do(t, call{Name: "roles/create_without_token", Method: "POST", Path: apps, Body: map[string]string{"app": "admin"}})
+	s.do(t, call{Name: "roles/create_not_admin", Method: "POST", Path: apps, Token: login.Token, Body: map[string]string{"app": "admin"}})
+	s.do(t, call{Name: "roles/create", Method: "POST", Path: apps, Token: admin, Body: map[string]string{"app": "billing"}})
+	s.do(t, call{Name: "roles/create_without_app", Method: "POST", Path: apps, Token: admin, Body: map[string]string{}})
+
+	etag := s.do(t, call{Name: "roles/get_created", Method: "GET", Path: apps + "/billing"}).ETag
+
+	s.do(t, call{Name: "roles/add", Method: "PUT", Path: apps + "/billing", Token: admin, Header: ifMatch(etag), Body: map[string][]string{"roles": {"read", "write"}}})
+	s.do(t, call{Name: "roles/add_stale", Method: "PUT", Path: apps + "/billing", Token: admin, Header: ifMatch(etag), Body: map[string][]string{"roles": {"owner"}}})
+	s.do(t, call{Name: "roles/add_without_if_match", Method: "PUT", Path: apps + "/billing", Token: admin, Body: map[string][]string{"roles": {"owner"}}})
+	s.do(t, call{Name: "roles/add_not_admin", Method: "PUT", Path: apps + "/billing", Token: login.Token, Header: ifMatch("*"), Body: map[string][]string{"roles": {"owner"}}})
+	s.do(t, call{Name: "roles/add_invalid", Method: "PUT", Path: apps + "/billing", Token: admin, Header: ifMatch("*"), Body: map[string][]string{"roles": {"read", "root"}}})
+	s.do(t, call{Name: "roles/add_not_found", Method: "PUT", Path: apps + "/unknown", Token: admin, Header: ifMatch("*"), Body: map[string][]string{"roles": {"read"}}})
+
+	s.do(t, call{Name: "roles/get", Method: "GET", Path: apps + "/billing"})
+	s.do(t, call{Name: "roles/get_not_found", Method: "GET", Path: apps + "/unknown"})
+
+	for _, app := range []string{"crm", "erp"} {
+		s.do(t, call{Name: "roles/create_" + app, Method: "POST", Path: apps, Token: admin, Body: map[string]string{"app": app}})
+	}
+
+	s.do(t, call{Name: "roles/list", Method: "GET", Path: apps})
+	first := s.do(t, call{Name: "roles/list_page", Method: "GET", Path: apps + "?limit=2"})
+	next := s.do(t, call{Name: "roles/list_next", Method: "GET", Path: apps + "?limit=2&cursor=" + first.Meta.Next})
+	s.do(t, call{Name: "roles/list_prev", Method: "GET", Path: apps + "?limit=2&cursor=" + next.Meta.Prev})
+	s.do(t, call{Name: "roles/list_invalid_cursor", Method: "GET", Path: apps + "?cursor=page-2"})
+}
+
+func TestErrors(t *testing.T) {
+	s := newServer(t)
+
+	s.do(t, call{Name: "errors/catalog", Method: "GET", Path: "/errors"})
+}
+
+// the servers add their routes to the engine of the handler they get,
+// any other handler is kept behind the new routes
+func TestServerWrapsHandlers(t *testing.T) {
+	locales, err := user.NewLocales()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := loghub.New()
+	userService := user.NewService(usertest.NewRepository(), usertest.NewAuth(), nil, nil, locales, logger)
+	roles := role.MakeEndpoints(role.NewService(roletest.NewRepository(), userService, transaction.None, logger), role.Config{LimPageDef: "10"})
+
+	ctx := context.Background()
+	wrapped := handler.AccessControl(handler.NewHTTPServer(ctx, user.MakeEndpoints(userService, user.Config{LimPageDef: "10"})))
+
+	cases := []struct {
+		name    string
+		handler http.Handler
+		paths   map[string]int
+	}{
+		{"without handler", handler.NewHTTPRolesServer(ctx, nil, roles), map[string]int{
+			"/users/id/apps/billing": http.StatusNotFound,
+			"/users":                 http.StatusNotFound,
+		}},
+		{"wrapped engine", handler.NewHTTPRolesServer(ctx, wrapped, roles), map[string]int{
+			"/users/id/apps/billing": http.StatusNotFound,
+			"/users":                 http.StatusBadRequest,
+			"/errors":                http.StatusNotFound,
+		}},
+		{"wrapped twice", handler.NewHTTPErrorServer(ctx, handler.NewHTTPRolesServer(ctx, wrapped, roles)), map[string]int{
+			"/users/id/apps/billing": http.StatusNotFound,
+			"/users":                 http.StatusBadRequest,
+			"/errors":                http.StatusOK,
+		}},
+	}
+
+	for _, c := range cases {
+		for path, status := range c.paths {
+			rec := httptest.NewRecorder()
+			c.handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+
+			if rec.Code != status {
+				t.Errorf("%s: GET %s returned %d, want %d: %s", c.name, path, rec.Code, status, rec.Body)
+			}
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/internal/user/accesstoken"
+	"github.com/ncostamagna/axul-user/internal/user/attribute"
+	"github.com/ncostamagna/axul-user/internal/user/emailchange"
+	"github.com/ncostamagna/axul-user/internal/user/identity"
+	"github.com/ncostamagna/axul-user/internal/user/identity/identitytest"
+	"github.com/ncostamagna/axul-user/internal/user/impersonation"
+	"github.com/ncostamagna/axul-user/internal/user/passkey"
+	"github.com/ncostamagna/axul-user/internal/user/passwordless"
+	"github.com/ncostamagna/axul-user/internal/user/phoneverify"
+	"github.com/ncostamagna/axul-user/internal/user/photo"
+	"github.com/ncostamagna/axul-user/internal/user/role"
+	"github.com/ncostamagna/axul-user/internal/user/usertest"
+	"github.com/ncostamagna/axul-user/pkg/blob"
+	"github.com/ncostamagna/axul-user/pkg/cache"
+	"github.com/ncostamagna/axul-user/pkg/dbtest"
+	"github.com/ncostamagna/axul-user/pkg/handler"
+	"github.com/ncostamagna/axul-user/pkg/mailer/mailertest"
+	"github.com/ncostamagna/axul-user/pkg/sms/smstest"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the responses")
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	os.Exit(m.Run())
+}
+
+// server is the handler stack of cmd/main with a SQLite database, a fake
+// auth and fakes for the identity provider, the mailer and the SMS
+// sender, the requests are served in process
+type server struct {
+	handler http.Handler
+	auth    *usertest.Auth
+	scrub   *scrubber
+	users   user.Service
+	roles   role.Service
+	inbox   *mailertest.Inbox
+	phone   *smstest.Phone
+	idp     *identitytest.IDP
+}
+
+// adminApp is the app of the admins of the tests
+const adminApp = "admin"
+
+// origin is the web app allowed to register and use the passkeys
+const origin = "http://localhost"
+
+func newServer(t *testing.T) *server {
+	t.Helper()
+
+	locales, err := user.NewLocales()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, logger, auth := dbtest.Open(t), loghub.New(), usertest.NewAuth()
+	accessTokenService := accesstoken.NewService(accesstoken.NewRepository(db, logger), logger)
+	impersonationRepository := impersonation.NewRepository(db, logger)
+
+	lru := cache.NewLRU(100)
+	userCache := cache.NewStore("users", lru, time.Minute, logger)
+	roleCache := cache.NewStore("roles", lru, time.Minute, logger)
+
+	userService := user.NewService(user.NewCachedRepository(user.NewRepository(db, logger), userCache), auth, accessTokenService, impersonationRepository, locales, logger)
+	roleService := role.NewService(role.NewCachedRepository(role.NewRepository(db, logger), roleCache), userService, transaction.NewManager(db), logger)
+	impersonationService := impersonation.NewService(impersonationRepository, userService, roleService, impersonation.Config{AdminApp: adminApp}, logger)
+
+	idp := identitytest.NewIDP(t)
+	identityService := identity.NewService(identity.NewRepository(db, logger), userService, []identity.Provider{idp.Provider(t)}, "state-key", logger)
+
+	mails, phone := &mailertest.Inbox{}, &smstest.Phone{}
+	passwordlessService := passwordless.NewService(passwordless.NewRepository(db, logger), userService, mails, passwordless.Config{LinkURL: "http://localhost/login"}, logger)
+	emailChangeService := emailchange.NewService(emailchange.NewRepository(db, logger), userService, transaction.NewManager(db), mails, emailchange.Config{
+		ConfirmURL: "http://localhost/email/confirm",
+		CancelURL:  "http://localhost/email/cancel",
+	}, logger)
+	phoneVerifyService := phoneverify.NewService(phoneverify.NewRepository(db, logger), userService, phone, phoneverify.Config{}, logger)
+
+	store, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	photoService := photo.NewService(userService, store, photo.Config{BaseURL: "http://localhost"}, logger)
+
+	schemas, err := attribute.LoadSchemas(filepath.Join("testdata", "schemas"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attributeService := attribute.NewService(attribute.NewRepository(db, logger), userService, schemas, logger)
+
+	w, err := webauthn.New(&webauthn.Config{RPID: "localhost", RPDisplayName: "Axul", RPOrigins: []string{origin}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	passkeyService := passkey.NewService(passkey.NewRepository(db, logger), userService, w, logger)
+
+	ctx := context.Background()
+	h := handler.NewHTTPServer(ctx, user.MakeEndpoints(userService, user.Config{
+		LimPageDef:   "10",
+		EmailChanges: emailChangeService,
+		Transactions: transaction.NewManager(db),
+		Admins:       roleService,
+		AdminApp:     adminApp,
+		Purger:       roleService,
+	}))
+	h = handler.NewHTTPRolesServer(ctx, h, role.MakeEndpoints(roleService, role.Config{LimPageDef: "10", AdminApp: adminApp}))
+	h = handler.NewHTTPErrorServer(ctx, h)
+	h = handler.NewHTTPIdentityServer(ctx, h, identity.MakeEndpoints(identityService, userService))
+	h = handler.NewHTTPPasswordlessServer(ctx, h, passwordless.MakeEndpoints(passwordlessService))
+	h = handler.NewHTTPEmailChangeServer(ctx, h, emailchange.MakeEndpoints(emailChangeService))
+	h = handler.NewHTTPPhoneVerifyServer(ctx, h, phoneverify.MakeEndpoints(phoneVerifyService, userService))
+	h = handler.NewHTTPPhotoServer(ctx, h, photo.MakeEndpoints(photoService, userService))
+	h = handler.NewHTTPAttributeServer(ctx, h, attribute.MakeEndpoints(attributeService, userService))
+	h = handler.NewHTTPAccessTokenServer(ctx, h, accesstoken.MakeEndpoints(accessTokenService, userService))
+	h = handler.NewHTTPImpersonationServer(ctx, h, impersonation.MakeEndpoints(impersonationService, userService))
+	h = handler.NewHTTPPasskeyServer(ctx, h, passkey.MakeEndpoints(passkeyService, userService))
+
+	return &server{
+		handler: handler.AccessControl(h),
+		auth:    auth,
+		scrub:   newScrubber(),
+		users:   userService,
+		roles:   roleService,
+		inbox:   mails,
+		phone:   phone,
+		idp:     idp,
+	}
+}
+
+// user creates the user of b and returns its id and a token
+func (s *server) user(t *testing.T, b storeBody) (string, string) {
+	t.Helper()
+
+	ctx := context.Background()
+	u, err := s.users.Create(ctx, b.UserName, b.FirstName, b.LastName, b.Password, b.Email, b.Phone, "", "", "", b.Language, b.Timezone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := s.users.IssueToken(ctx, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.ID, token
+}
+
+// admin creates an owner of the admin app and returns its id and token,
+// like the admin command of cmd/main
+func (s *server) admin(t *testing.T) (string, string) {
+	t.Helper()
+
+	id, token := s.user(t, storeBody{UserName: "admin", FirstName: "Ada", LastName: "Admin", Password: "secret-password", Email: "admin@example.com"})
+
+	ctx := context.Background()
+	if _, err := s.roles.Create(ctx, id, adminApp); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.roles.AddRole(ctx, id, adminApp, 0, []string{"owner"}); err != nil {
+		t.Fatal(err)
+	}
+	return id, token
+}
+
+// call is a request of a golden test, Name is the golden file in
+// testdata without the extension
+type call struct {
+	Name   string
+	Method string
+	Path   string
+	Token  string
+	Header map[string]string
+	Body   interface{}
+}
+
+// result is the data of a response body, the cursors of its meta, its
+// ETag and its headers
+type result struct {
+	Data json.RawMessage `json:"data"`
+	Meta struct {
+		Next string `json:"next"`
+		Prev string `json:"prev"`
+	} `json:"meta"`
+	ETag   string      `json:"-"`
+	Header http.Header `json:"-"`
+}
+
+// do serves the call and compares the request and the response with the
+// golden file
+func (s *server) do(t *testing.T, c call) result {
+	t.Helper()
+
+	var body []byte
+	switch b := c.Body.(type) {
+	case nil:
+	case string:
+		body = []byte(b)
+	case []byte:
+		body = b
+	default:
+		var err error
+		if body, err = json.Marshal(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(c.Method, c.Path, bytes.NewReader(body))
+	req.Header.Set("X-Request-ID", c.Name)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", c.Token)
+	}
+	for k, v := range c.Header {
+		req.Header.Set(k, v)
+	}
+
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+
+	keys := []string{"Content-Language", "X-Request-ID", "Access-Control-Allow-Origin", "ETag", "Location", "Set-Cookie"}
+	if c.Method == http.MethodOptions {
+		// the other CORS headers are the same in every response, they're
+		// only kept in the preflights
+		keys = append(keys, "Access-Control-Allow-Methods", "Access-Control-Allow-Headers", "Access-Control-Expose-Headers")
+	}
+
+	var got strings.Builder
+	fmt.Fprintf(&got, "> %s %s\n", c.Method, c.Path)
+	writeHeaders(&got, "> ", req.Header, "Authorization", "Accept-Language", "If-Match", "Cookie", "Access-Control-Request-Method")
+	writeBody(&got, req.Header.Get("Content-Type"), body)
+	fmt.Fprintf(&got, "\n< %d\n", rec.Code)
+	writeHeaders(&got, "< ", rec.Header(), keys...)
+	writeBody(&got, rec.Header().Get("Content-Type"), rec.Body.Bytes())
+
+	compare(t, c.Name, s.scrub.replace(got.String()))
+
+	var res result
+	_ = json.Unmarshal(rec.Body.Bytes(), &res)
+	res.ETag = rec.Header().Get("ETag")
+	res.Header = rec.Header()
+	return res
+}
+
+func writeHeaders(w io.Writer, prefix string, h http.Header, keys ...string) {
+	sort.Strings(keys)
+	for _, k := range keys {
+		if v := h.Get(k); v != "" {
+			fmt.Fprintf(w, "%s%s: %s\n", prefix, k, v)
+		}
+	}
+}
+
+// writeBody indents the JSON bodies, the images and the uploads are
+// binary so only their size is written
+func writeBody(w io.Writer, contentType string, body []byte) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return
+	}
+
+	if t, _, _ := mime.ParseMediaType(contentType); strings.HasPrefix(t, "image/") || strings.HasPrefix(t, "multipart/") {
+		fmt.Fprintf(w, "<%s, %d bytes>\n", t, len(body))
+		return
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, body, "", "  "); err != nil {
+		fmt.Fprintln(w, strings.TrimSpace(string(body)))
+		return
+	}
+
+	fmt.Fprintln(w, strings.TrimSpace(out.String()))
+}
+
+func compare(t *testing.T, name, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run the tests with -update to create it", err)
+	}
+
+	if got != string(want) {
+		t.Errorf("%s doesn't match the golden file, run the tests with -update if the change is expected\n--- got\n%s\n--- want\n%s", name, got, want)
+	}
+}
+
+// decode decodes the data of the response
+func (r result) decode(t *testing.T, v interface{}) {
+	t.Helper()
+
+	if err := json.Unmarshal(r.Data, v); err != nil {
+		t.Fatalf("decode %s: %v", r.Data, err)
+	}
+}
+
+// scrubber replaces the values that change in every run, the ids and the
+// secrets are numbered in the order they appear in the test so the same
+// value has the same placeholder in every golden file of a server. Only
+// the group v is replaced in the patterns that have it
+type scrubber struct {
+	seen map[string]string
+	next map[string]int
+}
+
+var scrubbed = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+	{"cursor", regexp.MustCompile(`eyJ0Ijoi[A-Za-z0-9_-]+`)},
+	{"state", regexp.MustCompile(`eyJwIjoi[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)},
+	{"hash", regexp.MustCompile(`\$2[aby]\$\d{2}\$[./A-Za-z0-9]{53}`)},
+	{"time", regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)},
+	{"idp", regexp.MustCompile(`http://127\.0\.0\.1:\d+`)},
+	{"id", regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)},
+	{"webauthn", regexp.MustCompile(`"(?:challenge|clientDataJSON|signature|userHandle)": "(?P<v>[A-Za-z0-9_-]+)"`)},
+	{"webauthn", regexp.MustCompile(`"displayName": "[^"]*",\s+"id": "(?P<v>[A-Za-z0-9_-]+)"`)},
+	{"code", regexp.MustCompile(`"code": "(?P<v>\d{6})"`)},
+	{"version", regexp.MustCompile(`/photo\?v=(?P<v>\d+)`)},
+	{"token", regexp.MustCompile(regexp.QuoteMeta(accesstoken.Prefix) + `[0-9a-f]+`)},
+	{"hex", regexp.MustCompile(`[0-9a-f]{32,}`)},
+}
+
+func newScrubber() *scrubber {
+	return &scrubber{seen: map[string]string{}, next: map[string]int{}}
+}
+
+func (s *scrubber) replace(text string) string {
+	for _, r := range scrubbed {
+		text = r.re.ReplaceAllStringFunc(text, func(match string) string {
+			start, end := 0, len(match)
+			if i := r.re.SubexpIndex("v"); i > 0 {
+				m := r.re.FindStringSubmatchIndex(match)
+				start, end = m[2*i], m[2*i+1]
+			}
+
+			return match[:start] + s.placeholder(r.name, match[start:end]) + match[end:]
+		})
+	}
+
+	return text
+}
+
+func (s *scrubber) placeholder(name, v string) string {
+	if name == "hash" || name == "time" || name == "idp" {
+		return "<" + name + ">"
+	}
+
+	if p, ok := s.seen[v]; ok {
+		return p
+	}
+
+	s.next[name]++
+	p := fmt.Sprintf("<%s-%d>", name, s.next[name])
+	s.seen[v] = p
+	return p
+}
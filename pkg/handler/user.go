@@ -3,14 +3,24 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-kit/kit/endpoint"
+	"github.com/google/uuid"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/apierror"
+	"github.com/ncostamagna/axul-user/pkg/cursor"
+	"github.com/ncostamagna/axul-user/pkg/i18n"
+	"github.com/ncostamagna/axul-user/pkg/replica"
+	"github.com/ncostamagna/axul-user/pkg/validation"
 	"github.com/ncostamagna/go-http-utils/response"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
+	"sync"
 )
 
 // NewHTTPServer is a server handler
@@ -22,7 +32,7 @@ func NewHTTPServer(_ context.Context, endpoints user.Endpoints) http.Handler {
 		httptransport.ServerErrorEncoder(encodeError),
 	}
 
-	r.Use(ginDecode())
+	r.Use(ginRequestID(), ginDecode(), ginLanguage(endpoints.Language))
 
 
 	//Deprecated
@@ -62,6 +72,27 @@ func NewHTTPServer(_ context.Context, endpoints user.Endpoints) http.Handler {
 		opts...,
 	)))
 
+	r.POST("/users/service-accounts", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.CreateServiceAccount),
+		decodeServiceAccountHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	r.GET("/users/service-accounts", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.GetServiceAccounts),
+		decodeGetHandler,
+		encodeResponse,
+		opts...,
+	)))
+
+	r.POST("/users/login/client", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.ClientCredentials),
+		decodeClientCredentialsHandler,
+		encodeResponse,
+		opts...,
+	)))
+
 	r.PATCH("/users/:id", gin.WrapH(httptransport.NewServer(
 		endpoint.Endpoint(endpoints.Update),
 		decodeUpdate,
@@ -76,6 +107,13 @@ func NewHTTPServer(_ context.Context, endpoints user.Endpoints) http.Handler {
 		opts...,
 	)))
 
+	r.DELETE("/users/:id", gin.WrapH(httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Delete),
+		decodeDelete,
+		encodeResponse,
+		opts...,
+	)))
+
 	return r
 
 }
@@ -84,15 +122,109 @@ func ginDecode() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := context.WithValue(c.Request.Context(), "params", c.Params)
 		ctx = context.WithValue(ctx, "header", c.Request.Header)
-		fmt.Println(c.Header)
+		ctx = replica.Session(ctx)
 		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	}
 }
 
+// ginRequestID keeps the X-Request-ID of the caller or creates one, it's
+// returned in the response headers and in the error bodies
+func ginRequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if id == "" || len(id) > 64 {
+			id = uuid.New().String()
+			// the engines wrapped by engine keep the same id
+			c.Request.Header.Set("X-Request-ID", id)
+		}
+
+		c.Header("X-Request-ID", id)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), "request_id", id))
+		c.Next()
+	}
+}
+
+// ginLanguage chooses the language of the error messages, Accept-Language
+// first and then the language of the authenticated user. It's resolved
+// only when the request fails
+func ginLanguage(userLanguage user.Controller) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var once sync.Once
+		lang := i18n.English
+		ctx := c.Request.Context()
+
+		resolve := func() string {
+			once.Do(func() {
+				if l := i18n.Match(c.GetHeader("Accept-Language")); l != "" {
+					lang = l
+					return
+				}
+
+				token := c.GetHeader("Authorization")
+				if token == "" || userLanguage == nil {
+					return
+				}
+
+				if v, err := userLanguage(ctx, token); err == nil {
+					if l := i18n.Match(v.(string)); l != "" {
+						lang = l
+					}
+				}
+			})
+			return lang
+		}
+
+		c.Request = c.Request.WithContext(context.WithValue(ctx, "language", resolve))
+		c.Next()
+	}
+}
+
+// authorization returns the Authorization header stored by ginDecode
+func authorization(ctx context.Context) string {
+	h, ok := ctx.Value("header").(http.Header)
+	if !ok {
+		return ""
+	}
+	return h.Get("Authorization")
+}
+
+// MaxBodySize is the limit of the JSON bodies
+const MaxBodySize = 1 << 20
+
+var unknownField = regexp.MustCompile(`^json: unknown field "(.*)"$`)
+
+// decodeJSON decodes the body of a request in v, the bodies larger than
+// MaxBodySize and the unknown fields are rejected, every error is a 400
+// response
+func decodeJSON(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, MaxBodySize))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err == nil && dec.More() {
+		err = errors.New("the body must have a single JSON object")
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return apierror.BadRequest(i18n.NewError("REQUEST_TOO_LARGE", "the request body is larger than %d bytes", tooLarge.Limit))
+	}
+
+	if m := unknownField.FindStringSubmatch(err.Error()); m != nil {
+		return validation.Field(m[1], validation.UnknownField, "")
+	}
+
+	return invalidFormat(err)
+}
+
 func decodeStoreHandler(_ context.Context, r *http.Request) (interface{}, error) {
 	var req user.StoreReq
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSON(r, &req); err != nil {
 		return nil, err
 	}
 
@@ -103,7 +235,7 @@ func decodeGetHandler(ctx context.Context, r *http.Request) (interface{}, error)
 	pp := ctx.Value("header").(http.Header)
 	
 	if len(pp["Authorization"]) < 1 {
-		return nil, response.BadRequest("invalid authentication")
+		return nil, apierror.BadRequest(user.InvalidAuthentication)
 	}
 
 	req := user.GetReq{
@@ -125,19 +257,54 @@ func decodeGetAllHandler(_ context.Context, r *http.Request) (interface{}, error
 		Page:     page,
 	}
 
+	if c := v.Get("cursor"); c != "" {
+		var err error
+		if req.Cursor, err = cursor.Parse(c); err != nil {
+			return nil, apierror.BadRequest(err)
+		}
+	}
+
+	for _, a := range v["attribute"] {
+		f, err := attributeFilter(a)
+		if err != nil {
+			return nil, apierror.BadRequest(err)
+		}
+		req.Attributes = append(req.Attributes, f)
+	}
+
 	return req, nil
 }
 
+var attributeKey = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// attributeFilter parses "<app>.<key>:<value>", like "crm.timezone:UTC"
+func attributeFilter(v string) (user.AttributeFilter, error) {
+	name, value, ok := strings.Cut(v, ":")
+	app, key, _ := strings.Cut(name, ".")
+	if !ok || app == "" || !attributeKey.MatchString(key) {
+		return user.AttributeFilter{}, fmt.Errorf("attribute filter '%s' isn't valid, it must be <app>.<key>:<value>", v)
+	}
+
+	return user.AttributeFilter{App: app, Key: key, Value: value}, nil
+}
+
 func decodeUpdate(ctx context.Context, r *http.Request) (interface{}, error) {
 
 	var req user.UpdateReq
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, response.BadRequest(fmt.Sprintf("invalid request format: '%v'", err.Error()))
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
 	}
 
 	params := ctx.Value("params").(gin.Params)
 	req.ID = params.ByName("id")
+	req.Authorization = authorization(ctx)
+
+	version, err := ifMatch(r)
+	if err != nil {
+		return nil, err
+	}
+	req.Version = version
 
 	return req, nil
 }
@@ -146,19 +313,49 @@ func decodeUpdatePassword(ctx context.Context, r *http.Request) (interface{}, er
 
 	var req user.UpdatePasswordReq
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, response.BadRequest(fmt.Sprintf("invalid request format: '%v'", err.Error()))
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
 	}
 
 	params := ctx.Value("params").(gin.Params)
 	req.ID = params.ByName("id")
+	req.Authorization = authorization(ctx)
+
+	return req, nil
+}
+
+func decodeDelete(ctx context.Context, r *http.Request) (interface{}, error) {
+	params := ctx.Value("params").(gin.Params)
+	req := user.DeleteReq{
+		ID:            params.ByName("id"),
+		Authorization: authorization(ctx),
+	}
 
 	return req, nil
 }
 
 func decodeLoginHandler(_ context.Context, r *http.Request) (interface{}, error) {
 	req := user.LoginReq{}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func decodeServiceAccountHandler(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req user.ServiceAccountReq
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	req.Authorization = authorization(ctx)
+	return req, nil
+}
+
+func decodeClientCredentialsHandler(_ context.Context, r *http.Request) (interface{}, error) {
+	var req user.ClientCredentialsReq
+	if err := decodeJSON(r, &req); err != nil {
 		return nil, err
 	}
 
@@ -177,13 +374,62 @@ func decodeTokenHandler(ctx context.Context, r *http.Request) (interface{}, erro
 
 func encodeResponse(ctx context.Context, w http.ResponseWriter, resp interface{}) error {
 	r := resp.(response.Response)
+	if v, ok := data(r).(versioned); ok {
+		w.Header().Set("ETag", etag(v.GetVersion()))
+	}
 	w.WriteHeader(200)
 	return json.NewEncoder(w).Encode(r)
 }
 
-func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+// errorResponse is the error body, Code identifies the error in every
+// language and Details has the fields of the validation errors
+type errorResponse struct {
+	Status    int           `json:"status"`
+	Code      string        `json:"code"`
+	Message   string        `json:"message"`
+	Details   []errorDetail `json:"details,omitempty"`
+	RequestID string        `json:"request_id,omitempty"`
+}
+
+type errorDetail struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func encodeError(ctx context.Context, err error, w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	resp := err.(response.Response)
+
+	lang := i18n.English
+	if f, ok := ctx.Value("language").(func() string); ok {
+		lang = f()
+	}
+
+	t := i18n.Translate(err, lang, resp.StatusCode())
+	body := errorResponse{
+		Status:  resp.StatusCode(),
+		Code:    t.Code,
+		Message: t.Message,
+	}
+
+	if t.Field != "" {
+		body.Details = []errorDetail{{t.Field, t.Code, t.Message}}
+	}
+
+	var invalid *validation.Error
+	if errors.As(err, &invalid) {
+		body.Details = make([]errorDetail, 0, len(invalid.Fields))
+		for _, f := range invalid.Fields {
+			body.Details = append(body.Details, errorDetail{f.Field, f.Code, i18n.Render(f.Code, lang, f.Args()...)})
+		}
+	}
+
+	if id, ok := ctx.Value("request_id").(string); ok {
+		body.RequestID = id
+	}
+
+	w.Header().Set("Content-Language", lang)
 	w.WriteHeader(resp.StatusCode())
-	_ = json.NewEncoder(w).Encode(resp)
+	_ = json.NewEncoder(w).Encode(body)
 }
@@ -0,0 +1,151 @@
+package handler_test
+
+import (
+	"testing"
+)
+
+type storeBody struct {
+	UserName  string `json:"username,omitempty"`
+	FirstName string `json:"firstname,omitempty"`
+	LastName  string `json:"lastname,omitempty"`
+	Password  string `json:"password,omitempty"`
+	Email     string `json:"email,omitempty"`
+	Phone     string `json:"phone,omitempty"`
+	Language  string `json:"language,omitempty"`
+	Timezone  string `json:"timezone,omitempty"`
+}
+
+var john = storeBody{
+	UserName:  "john",
+	FirstName: "John",
+	LastName:  "Doe",
+	Password:  "secret-password",
+	Email:     "john@example.com",
+	Phone:     "+14155550100",
+	Language:  "es-AR",
+	Timezone:  "America/Argentina/Buenos_Aires",
+}
+
+func TestUsers(t *testing.T) {
+	s := newServer(t)
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	s.do(t, call{Name: "users/create", Method: "POST", Path: "/users", Body: john}).decode(t, &created)
+
+	s.do(t, call{Name: "users/create_taken", Method: "POST", Path: "/users", Body: john})
+	s.do(t, call{Name: "users/create_invalid", Method: "POST", Path: "/users", Body: storeBody{UserName: "jane", Password: "short", Email: "jane"}})
+	s.do(t, call{Name: "users/create_username_email", Method: "POST", Path: "/users", Body: storeBody{UserName: "john@example.com", FirstName: "Eve", LastName: "Doe", Password: "secret-password", Email: "eve@example.com"}})
+	s.do(t, call{Name: "users/create_unknown_field", Method: "POST", Path: "/users", Body: `{"username":"jane","admin":true}`})
+	s.do(t, call{Name: "users/create_malformed", Method: "POST", Path: "/users", Body: `{"username":`})
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	s.do(t, call{Name: "users/login", Method: "POST", Path: "/users/login", Body: map[string]string{"login": "John@Example.com", "password": john.Password}}).decode(t, &login)
+
+	s.do(t, call{Name: "users/login_username", Method: "POST", Path: "/users/login", Body: map[string]string{"username": "john", "password": john.Password}})
+	s.do(t, call{Name: "users/login_wrong_password", Method: "POST", Path: "/users/login", Body: map[string]string{"login": "john", "password": "wrong-password"}})
+	s.do(t, call{Name: "users/login_wrong_password_es", Method: "POST", Path: "/users/login", Header: map[string]string{"Accept-Language": "es"}, Body: map[string]string{"login": "john", "password": "wrong-password"}})
+
+	etag := s.do(t, call{Name: "users/get", Method: "GET", Path: "/users", Token: login.Token}).ETag
+	s.do(t, call{Name: "users/get_by_id", Method: "GET", Path: "/users/" + created.ID, Token: login.Token})
+	s.do(t, call{Name: "users/get_without_token", Method: "GET", Path: "/users"})
+	s.do(t, call{Name: "users/get_invalid_token", Method: "GET", Path: "/users", Token: "unknown"})
+
+	s.do(t, call{Name: "users/token", Method: "GET", Path: "/users/" + created.ID + "/token/" + login.Token})
+	s.do(t, call{Name: "users/token_invalid", Method: "GET", Path: "/users/" + created.ID + "/token/unknown"})
+
+	s.do(t, call{Name: "users/update", Method: "PATCH", Path: "/users/" + created.ID, Token: login.Token, Header: ifMatch(etag), Body: map[string]string{"firstname": "Johnny", "phone": "+14155550101"}})
+	s.do(t, call{Name: "users/update_stale", Method: "PATCH", Path: "/users/" + created.ID, Token: login.Token, Header: ifMatch(etag), Body: map[string]string{"firstname": "Jack"}})
+	s.do(t, call{Name: "users/update_stale_es", Method: "PATCH", Path: "/users/" + created.ID, Token: login.Token, Header: map[string]string{"If-Match": etag, "Accept-Language": "es"}, Body: map[string]string{"firstname": "Jack"}})
+	s.do(t, call{Name: "users/update_without_if_match", Method: "PATCH", Path: "/users/" + created.ID, Token: login.Token, Body: map[string]string{"firstname": "Jack"}})
+	s.do(t, call{Name: "users/update_invalid_if_match", Method: "PATCH", Path: "/users/" + created.ID, Token: login.Token, Header: ifMatch("version-1"), Body: map[string]string{"firstname": "Jack"}})
+	s.do(t, call{Name: "users/update_empty_name", Method: "PATCH", Path: "/users/" + created.ID, Token: login.Token, Header: ifMatch("*"), Body: map[string]string{"firstname": ""}})
+	s.do(t, call{Name: "users/update_another_user", Method: "PATCH", Path: "/users/00000000-0000-0000-0000-000000000000", Token: login.Token, Header: ifMatch(`"1"`), Body: map[string]string{"firstname": "Johnny"}})
+	s.do(t, call{Name: "users/update_without_token", Method: "PATCH", Path: "/users/" + created.ID, Header: ifMatch("*"), Body: map[string]string{"firstname": "Jack"}})
+
+	etag = s.do(t, call{Name: "users/get_after_update", Method: "GET", Path: "/users", Token: login.Token}).ETag
+	s.do(t, call{Name: "users/update_weak_etag", Method: "PATCH", Path: "/users/" + created.ID, Token: login.Token, Header: ifMatch("W/" + etag), Body: map[string]string{"lastname": "Smith"}})
+	s.do(t, call{Name: "users/update_any_version", Method: "PATCH", Path: "/users/" + created.ID, Token: login.Token, Header: ifMatch("*"), Body: map[string]string{"lastname": "Doe"}})
+
+	// an old version fails before the confirmation of the new email is sent
+	s.do(t, call{Name: "users/update_email_stale", Method: "PATCH", Path: "/users/" + created.ID, Token: login.Token, Header: ifMatch(etag), Body: map[string]string{"email": "johnny@example.com"}})
+	if n := s.inbox.Len(); n != 0 {
+		t.Errorf("the update of an old version sent %d messages", n)
+	}
+
+	// the other fields are validated before the change of the email is
+	// opened, the emails are sent when both are committed
+	s.do(t, call{Name: "users/update_email_invalid_phone", Method: "PATCH", Path: "/users/" + created.ID, Token: login.Token, Header: ifMatch("*"), Body: map[string]string{"firstname": "Jack", "phone": "invalid", "email": "johnny@example.com"}})
+	s.do(t, call{Name: "users/update_email_same", Method: "PATCH", Path: "/users/" + created.ID, Token: login.Token, Header: ifMatch("*"), Body: map[string]string{"firstname": "Jack", "email": "JOHN@example.com"}})
+	if n := s.inbox.Len(); n != 0 {
+		t.Errorf("the failed updates sent %d messages", n)
+	}
+
+	s.do(t, call{Name: "users/update_email", Method: "PATCH", Path: "/users/" + created.ID, Token: login.Token, Header: ifMatch("*"), Body: map[string]string{"firstname": "Johnny", "email": "johnny@example.com"}})
+	if n := s.inbox.Len(); n != 2 {
+		t.Errorf("the email change sent %d messages, want the confirmation and the cancel", n)
+	}
+
+	s.do(t, call{Name: "users/update_password_without_token", Method: "PUT", Path: "/users/" + created.ID + "/password", Body: map[string]string{"old_password": john.Password, "new_password": "new-password"}})
+	s.do(t, call{Name: "users/update_password_wrong", Method: "PUT", Path: "/users/" + created.ID + "/password", Token: login.Token, Body: map[string]string{"old_password": "wrong-password", "new_password": "new-password"}})
+	s.do(t, call{Name: "users/update_password_short", Method: "PUT", Path: "/users/" + created.ID + "/password", Token: login.Token, Body: map[string]string{"old_password": john.Password, "new_password": "short"}})
+	s.do(t, call{Name: "users/update_password", Method: "PUT", Path: "/users/" + created.ID + "/password", Token: login.Token, Body: map[string]string{"old_password": john.Password, "new_password": "new-password"}})
+	s.do(t, call{Name: "users/login_new_password", Method: "POST", Path: "/users/login", Body: map[string]string{"login": "john", "password": "new-password"}})
+
+	// the browsers ask before a PATCH, a PUT or a DELETE from another origin
+	s.do(t, call{Name: "users/preflight", Method: "OPTIONS", Path: "/users/" + created.ID, Header: map[string]string{"Access-Control-Request-Method": "PATCH"}})
+	s.do(t, call{Name: "users/not_found_route", Method: "GET", Path: "/unknown"})
+}
+
+func TestServiceAccounts(t *testing.T) {
+	s := newServer(t)
+
+	s.do(t, call{Name: "service_accounts/owner", Method: "POST", Path: "/users", Body: john})
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	s.do(t, call{Name: "service_accounts/owner_login", Method: "POST", Path: "/users/login", Body: map[string]string{"login": "john", "password": john.Password}}).decode(t, &login)
+
+	var account struct {
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+	}
+	_, admin := s.admin(t)
+	s.do(t, call{Name: "service_accounts/create", Method: "POST", Path: "/users/service-accounts", Token: admin, Body: map[string]string{"name": "billing", "description": "the billing jobs"}}).decode(t, &account)
+
+	s.do(t, call{Name: "service_accounts/create_without_token", Method: "POST", Path: "/users/service-accounts", Body: map[string]string{"name": "billing"}})
+	s.do(t, call{Name: "service_accounts/create_not_admin", Method: "POST", Path: "/users/service-accounts", Token: login.Token, Body: map[string]string{"name": "reports"}})
+	s.do(t, call{Name: "service_accounts/create_taken", Method: "POST", Path: "/users/service-accounts", Token: admin, Body: map[string]string{"name": "billing"}})
+	s.do(t, call{Name: "service_accounts/list", Method: "GET", Path: "/users/service-accounts", Token: admin})
+	s.do(t, call{Name: "service_accounts/list_not_admin", Method: "GET", Path: "/users/service-accounts", Token: login.Token})
+
+	s.do(t, call{Name: "service_accounts/login", Method: "POST", Path: "/users/login/client", Body: map[string]string{"client_id": account.ClientID, "client_secret": account.ClientSecret}})
+	s.do(t, call{Name: "service_accounts/login_wrong_secret", Method: "POST", Path: "/users/login/client", Body: map[string]string{"client_id": account.ClientID, "client_secret": "wrong-secret"}})
+	s.do(t, call{Name: "service_accounts/login_without_secret", Method: "POST", Path: "/users/login/client", Body: map[string]string{"client_id": account.ClientID}})
+}
+
+func TestDeleteUsers(t *testing.T) {
+	s := newServer(t)
+	id, token := s.user(t, john)
+	adminID, admin := s.admin(t)
+	apps := "/users/" + id + "/apps"
+
+	s.do(t, call{Name: "users/delete_create_role", Method: "POST", Path: apps, Token: admin, Body: map[string]string{"app": "billing"}})
+
+	s.do(t, call{Name: "users/delete_without_token", Method: "DELETE", Path: "/users/" + id})
+	s.do(t, call{Name: "users/delete_not_admin", Method: "DELETE", Path: "/users/" + adminID, Token: token})
+
+	// the roles of the user go with the user
+	s.do(t, call{Name: "users/delete", Method: "DELETE", Path: "/users/" + id, Token: admin})
+	s.do(t, call{Name: "users/delete_not_found", Method: "DELETE", Path: "/users/" + id, Token: admin})
+	s.do(t, call{Name: "users/delete_get_role", Method: "GET", Path: apps + "/billing"})
+	s.do(t, call{Name: "users/delete_login", Method: "POST", Path: "/users/login", Body: map[string]string{"login": "john", "password": john.Password}})
+}
+
+func ifMatch(etag string) map[string]string {
+	return map[string]string{"If-Match": etag}
+}
@@ -0,0 +1,232 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/text/language"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+const (
+	English = "en"
+	Spanish = "es"
+)
+
+// Languages are the languages of the catalogs, the first one is the
+// language of the messages in the code
+var Languages = []string{English, Spanish}
+
+var matcher = language.NewMatcher([]language.Tag{language.English, language.Spanish})
+
+// Message is an error message and its translations, Text[English] is the
+// text of the errors of Code and the translations take the same
+// arguments. Code is stable, the clients rely on it, and Field is the
+// request field of the validation errors
+type Message struct {
+	Code  string            `json:"code"`
+	Field string            `json:"field,omitempty"`
+	Text  map[string]string `json:"messages"`
+}
+
+// Coded is an error of a message of the catalog, Args are the arguments
+// of its text
+type Coded interface {
+	error
+	Code() string
+	Args() []interface{}
+}
+
+// Error is a Coded error built from its code and its English text
+type Error struct {
+	code string
+	text string
+	args []interface{}
+}
+
+// NewError returns the error of a code, format and args are the English
+// text like in fmt.Sprintf and the args are reused in the translations
+func NewError(code, format string, args ...interface{}) *Error {
+	return &Error{code, fmt.Sprintf(format, args...), args}
+}
+
+func (e *Error) Error() string {
+	return e.text
+}
+
+func (e *Error) Code() string {
+	return e.code
+}
+
+func (e *Error) Args() []interface{} {
+	return e.args
+}
+
+// statusCodes are the codes of the messages that aren't in the catalog
+var statusCodes = map[int]string{
+	http.StatusBadRequest:           "BAD_REQUEST",
+	http.StatusUnauthorized:         "UNAUTHORIZED",
+	http.StatusForbidden:            "FORBIDDEN",
+	http.StatusNotFound:             "NOT_FOUND",
+	http.StatusConflict:             "CONFLICT",
+	http.StatusPreconditionFailed:   "PRECONDITION_FAILED",
+	http.StatusPreconditionRequired: "PRECONDITION_REQUIRED",
+	http.StatusTooManyRequests:      "TOO_MANY_REQUESTS",
+	http.StatusInternalServerError:  "INTERNAL_ERROR",
+}
+
+var (
+	mu      sync.RWMutex
+	byCode  = map[string]Message{}
+	codes   []string
+	foreign = map[error]string{}
+)
+
+var verbs = regexp.MustCompile(`%[sdv]`)
+
+// Register adds messages to the catalog, the packages call it from init
+func Register(messages ...Message) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, m := range messages {
+		add(m)
+	}
+}
+
+// add keeps a message, a code is registered once so its text is the same
+// in every package
+func add(m Message) {
+	if _, ok := byCode[m.Code]; ok {
+		panic(fmt.Sprintf("i18n: code %s registered twice", m.Code))
+	}
+
+	byCode[m.Code] = m
+	codes = append(codes, m.Code)
+}
+
+// RegisterError gives the code of a message to an error of another module,
+// it can't implement Coded
+func RegisterError(err error, code string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	foreign[err] = code
+}
+
+// RegisterDetails adds messages that are only rendered by code with
+// Render, like the field errors, no error has their code
+func RegisterDetails(messages ...Message) {
+	Register(messages...)
+}
+
+// Catalog returns one message per code sorted by code, it's the
+// documentation of the error codes
+func Catalog() []Message {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	messages := make([]Message, 0, len(codes))
+	for _, c := range codes {
+		messages = append(messages, byCode[c])
+	}
+
+	sort.Slice(messages, func(i, j int) bool { return messages[i].Code < messages[j].Code })
+	return messages
+}
+
+// Translation is a message rendered in a language
+type Translation struct {
+	Code    string
+	Field   string
+	Message string
+}
+
+// Translate renders the message of the code of err in lang, the code of
+// a Coded error or of a RegisterError error. The other errors keep their
+// text with the code of the status
+func Translate(err error, lang string, status int) Translation {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var coded Coded
+	if errors.As(err, &coded) {
+		if m, ok := byCode[coded.Code()]; ok {
+			return Translation{m.Code, m.Field, render(m, lang, coded.Args()...)}
+		}
+	}
+
+	for e, code := range foreign {
+		if errors.Is(err, e) {
+			m := byCode[code]
+			return Translation{m.Code, m.Field, render(m, lang)}
+		}
+	}
+
+	code, ok := statusCodes[status]
+	if !ok {
+		code = "ERROR"
+	}
+
+	// the default messages of the responses without a message
+	if m, ok := byCode[code]; ok && m.Text[English] == err.Error() {
+		return Translation{Code: code, Message: render(m, lang)}
+	}
+
+	return Translation{Code: code, Message: err.Error()}
+}
+
+// Get returns the message of a code
+func Get(code string) (Message, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	m, ok := byCode[code]
+	return m, ok
+}
+
+// Match picks the catalog language of an Accept-Language header or a
+// user language, empty when nothing matches
+func Match(v string) string {
+	if v == "" {
+		return ""
+	}
+
+	tags, _, err := language.ParseAcceptLanguage(v)
+	if err != nil || len(tags) == 0 {
+		return ""
+	}
+
+	tag, _, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return ""
+	}
+
+	base, _ := tag.Base()
+	return base.String()
+}
+
+// Render renders the message of a code in lang, the English text is
+// used when the code has no translation
+func Render(code, lang string, args ...interface{}) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	m, ok := byCode[code]
+	if !ok {
+		return code
+	}
+
+	return render(m, lang, args...)
+}
+
+func render(m Message, lang string, args ...interface{}) string {
+	text, ok := m.Text[lang]
+	if !ok {
+		text = m.Text[English]
+	}
+
+	return fmt.Sprintf(verbs.ReplaceAllString(text, "%v"), args...)
+}
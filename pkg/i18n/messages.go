@@ -0,0 +1,39 @@
+package i18n
+
+// the messages of the shared response helpers and the decoders
+func init() {
+	Register(
+		Message{Code: "BAD_REQUEST", Text: map[string]string{
+			English: "Your request is in a bad format.",
+			Spanish: "La solicitud tiene un formato incorrecto.",
+		}},
+		Message{Code: "FORBIDDEN", Text: map[string]string{
+			English: "You are not authorized to perform the requested action.",
+			Spanish: "No tienes permiso para realizar la acción solicitada.",
+		}},
+		Message{Code: "UNAUTHORIZED", Text: map[string]string{
+			English: "You are not authenticated to perform the requested action.",
+			Spanish: "No estás autenticado para realizar la acción solicitada.",
+		}},
+		Message{Code: "NOT_FOUND", Text: map[string]string{
+			English: "The requested resource was not found.",
+			Spanish: "No se encontró el recurso solicitado.",
+		}},
+		Message{Code: "INTERNAL_ERROR", Text: map[string]string{
+			English: "We encountered an error while processing your request.",
+			Spanish: "Ocurrió un error al procesar tu solicitud.",
+		}},
+		Message{Code: "INVALID_REQUEST_FORMAT", Text: map[string]string{
+			English: "invalid request format: '%v'",
+			Spanish: "formato de solicitud inválido: '%v'",
+		}},
+		Message{Code: "INVALID_AUTHENTICATION", Text: map[string]string{
+			English: "invalid authentication",
+			Spanish: "autenticación inválida",
+		}},
+		Message{Code: "FIELDS_REQUIRED", Text: map[string]string{
+			English: "fields required",
+			Spanish: "faltan campos obligatorios",
+		}},
+	)
+}
@@ -0,0 +1,66 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"net/smtp"
+	"strings"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends the emails of the service
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type smtpMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTP is a mailer over a SMTP server, user and password are optional
+func NewSMTP(host, port, user, password, from string) Mailer {
+	var auth smtp.Auth
+	if user != "" {
+		auth = smtp.PlainAuth("", user, password, host)
+	}
+
+	return &smtpMailer{
+		addr: fmt.Sprintf("%s:%s", host, port),
+		from: from,
+		auth: auth,
+	}
+}
+
+func (m *smtpMailer) Send(_ context.Context, msg Message) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(msg.Body)
+
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, []byte(b.String()))
+}
+
+type logMailer struct {
+	logger loghub.Logger
+}
+
+// NewLogMailer only logs the messages, it's used in local environments
+func NewLogMailer(logger loghub.Logger) Mailer {
+	return &logMailer{logger}
+}
+
+func (m *logMailer) Send(_ context.Context, msg Message) error {
+	m.logger.Info(fmt.Sprintf("mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body))
+	return nil
+}
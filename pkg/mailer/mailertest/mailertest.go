@@ -0,0 +1,52 @@
+// Package mailertest has the fake mailer of the tests
+package mailertest
+
+import (
+	"context"
+	"github.com/ncostamagna/axul-user/pkg/mailer"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Inbox is a mailer.Mailer that keeps the messages instead of sending them
+type Inbox struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func (i *Inbox) Send(_ context.Context, msg mailer.Message) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.messages = append(i.messages, msg)
+	return nil
+}
+
+// Messages returns the messages sent, the oldest first
+func (i *Inbox) Messages() []mailer.Message {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return append([]mailer.Message(nil), i.messages...)
+}
+
+// Len returns the number of messages sent
+func (i *Inbox) Len() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return len(i.messages)
+}
+
+// Wait returns the messages when there are n, it fails the test when they
+// aren't sent in a few seconds. It's for the mails sent in the background
+func (i *Inbox) Wait(t testing.TB, n int) []mailer.Message {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if messages := i.Messages(); len(messages) >= n {
+			return messages
+		}
+	}
+
+	t.Fatalf("%d messages were sent, want %d", i.Len(), n)
+	return nil
+}
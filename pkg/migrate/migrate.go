@@ -0,0 +1,329 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"gorm.io/gorm"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a version of the schema, Up applies it and Down reverts it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Version is a row of the schema version table, one per applied migration
+type Version struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"type:varchar(255);not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (Version) TableName() string {
+	return "schema_migrations"
+}
+
+// Status is a migration and when it was applied, AppliedAt is nil for
+// the pending ones
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// ErrSchemaBehind is returned by Check when there are pending migrations
+type ErrSchemaBehind struct {
+	Pending []int
+}
+
+func (e ErrSchemaBehind) Error() string {
+	return fmt.Sprintf("the database schema is behind, %d pending migrations, run 'migrate up'", len(e.Pending))
+}
+
+// Migrator applies the migrations of a dialect and keeps the applied
+// versions in the schema_migrations table
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+	logger     loghub.Logger
+}
+
+// New loads the migrations of fsys, it fails when a version doesn't
+// have both files or it's repeated
+func New(db *gorm.DB, fsys fs.FS, logger loghub.Logger) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations, logger: logger}, nil
+}
+
+// Load reads the migration files of fsys sorted by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, f := range files {
+		m := fileName.FindStringSubmatch(f.Name())
+		if f.IsDir() || m == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(m[1])
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %d is repeated: %s and %s", version, migration.Name, m[2])
+		}
+
+		data, err := fs.ReadFile(fsys, f.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		if m[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %04d_%s doesn't have an up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]Version, error) {
+	if err := m.db.WithContext(ctx).AutoMigrate(&Version{}); err != nil {
+		return nil, err
+	}
+
+	var versions []Version
+	if err := m.db.WithContext(ctx).Order("version").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]Version, len(versions))
+	for _, v := range versions {
+		applied[v.Version] = v
+	}
+
+	return applied, nil
+}
+
+// Up applies the pending migrations in order and returns how many were
+// applied, it stops at the first one that fails
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := exec(tx, migration.Up); err != nil {
+				return err
+			}
+
+			return tx.Create(&Version{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		m.logger.Info(fmt.Sprintf("Migration %04d_%s applied", migration.Version, migration.Name))
+		count++
+	}
+
+	return count, nil
+}
+
+// Down reverts the last n applied migrations and returns how many were
+// reverted
+func (m *Migrator) Down(ctx context.Context, n int) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(m.migrations) - 1; i >= 0 && count < n; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		if strings.TrimSpace(migration.Down) == "" {
+			return count, fmt.Errorf("migration %04d_%s can't be reverted, it doesn't have a down file", migration.Version, migration.Name)
+		}
+
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := exec(tx, migration.Down); err != nil {
+				return err
+			}
+
+			return tx.Delete(&Version{}, migration.Version).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		m.logger.Info(fmt.Sprintf("Migration %04d_%s reverted", migration.Version, migration.Name))
+		count++
+	}
+
+	return count, nil
+}
+
+// Status returns every migration and when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		s := Status{Migration: migration}
+		if v, ok := applied[migration.Version]; ok {
+			s.AppliedAt = &v.AppliedAt
+		}
+		status = append(status, s)
+	}
+
+	return status, nil
+}
+
+// Check returns ErrSchemaBehind when there are pending migrations, the
+// versions applied by a newer binary are accepted
+func (m *Migrator) Check(ctx context.Context) error {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var pending []int
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			pending = append(pending, s.Version)
+		}
+	}
+
+	if len(pending) > 0 {
+		return ErrSchemaBehind{pending}
+	}
+
+	return nil
+}
+
+// Create adds the empty files of a new migration to every dir, one per
+// dialect, with the same version. The version is the next one of the
+// files of all the dirs so a dialect behind doesn't reuse a version
+func Create(dirs []string, name string) ([]string, error) {
+	name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "_"))
+	if !regexp.MustCompile(`^[a-z0-9_]+$`).MatchString(name) {
+		return nil, errors.New("the name of the migration must have only letters, numbers and underscores")
+	}
+
+	version := 1
+	for _, dir := range dirs {
+		migrations, err := Load(os.DirFS(dir))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+
+		if len(migrations) > 0 && migrations[len(migrations)-1].Version >= version {
+			version = migrations[len(migrations)-1].Version + 1
+		}
+	}
+
+	var files []string
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return files, err
+		}
+
+		for _, f := range []string{
+			filepath.Join(dir, fmt.Sprintf("%04d_%s.up.sql", version, name)),
+			filepath.Join(dir, fmt.Sprintf("%04d_%s.down.sql", version, name)),
+		} {
+			if err := os.WriteFile(f, []byte("-- "+filepath.Base(f)+"\n"), 0o644); err != nil {
+				return files, err
+			}
+			files = append(files, f)
+		}
+	}
+
+	return files, nil
+}
+
+// exec runs the statements of a migration one by one, the statements end
+// with a semicolon at the end of a line
+func exec(tx *gorm.DB, sql string) error {
+	for _, stmt := range statements(sql) {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func statements(sql string) []string {
+	var stmts []string
+	var cur strings.Builder
+
+	for _, line := range strings.Split(sql, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		cur.WriteString(line)
+		cur.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		}
+	}
+
+	if s := strings.TrimSpace(cur.String()); s != "" {
+		stmts = append(stmts, s)
+	}
+
+	return stmts
+}
@@ -0,0 +1,227 @@
+// Package replica sends the reads of the repositories to the read replicas
+// of the database. The writes, the transactions and the reads of a Session
+// after its first write go to the primary, and the replicas that fail the
+// health check are skipped until they answer again
+package replica
+
+import (
+	"context"
+	"fmt"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"gorm.io/gorm"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCheckInterval is the time between the health checks of a replica
+const DefaultCheckInterval = 10 * time.Second
+
+// Config has the replicas of the database, CheckInterval is the time
+// between the health checks of a replica, DefaultCheckInterval when it's 0
+type Config struct {
+	Replicas      []gorm.Dialector
+	CheckInterval time.Duration
+}
+
+// Router is the gorm plugin that chooses the database of the queries
+type Router struct {
+	config   Config
+	logger   loghub.Logger
+	gorm     *gorm.Config
+	primary  gorm.ConnPool
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+// replica is opened by its first health check that succeeds, pool is nil
+// until then
+type replica struct {
+	id        int
+	dialector gorm.Dialector
+	mu        sync.Mutex
+	pool      gorm.ConnPool
+	healthy   bool
+	checked   time.Time
+	checking  bool
+}
+
+// New returns the Router of the replicas, it's added to the database with
+// db.Use
+func New(config Config, logger loghub.Logger) *Router {
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = DefaultCheckInterval
+	}
+
+	return &Router{config: config, logger: logger}
+}
+
+func (r *Router) Name() string {
+	return "replica"
+}
+
+// Initialize checks the replicas and adds the callbacks. A replica that is
+// down doesn't stop the service, the reads go to the other replicas or to
+// the primary until it's up
+func (r *Router) Initialize(db *gorm.DB) error {
+	r.primary = db.Config.ConnPool
+	r.gorm = &gorm.Config{Logger: db.Config.Logger}
+
+	for i, dialector := range r.config.Replicas {
+		rep := &replica{id: i, dialector: dialector}
+		r.check(rep)
+		r.replicas = append(r.replicas, rep)
+	}
+
+	callbacks := []func() error{
+		func() error { return db.Callback().Create().Before("*").Register("replica:write", r.write) },
+		func() error { return db.Callback().Update().Before("*").Register("replica:write", r.write) },
+		func() error { return db.Callback().Delete().Before("*").Register("replica:write", r.write) },
+		func() error { return db.Callback().Raw().Before("*").Register("replica:write", r.write) },
+		func() error { return db.Callback().Query().Before("*").Register("replica:read", r.read) },
+		func() error { return db.Callback().Row().Before("*").Register("replica:read", r.read) },
+	}
+
+	for _, register := range callbacks {
+		if err := register(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type key struct{}
+
+type session struct {
+	written atomic.Bool
+}
+
+// Session returns a context whose reads go to the primary after its first
+// write, the handlers start a session in every request so the request
+// reads what it wrote even when the replicas are behind
+func Session(ctx context.Context) context.Context {
+	return context.WithValue(ctx, key{}, &session{})
+}
+
+// Primary returns a context whose reads go to the primary, for the reads
+// that can't be behind the writes like the attempts of a code
+func Primary(ctx context.Context) context.Context {
+	s := &session{}
+	s.written.Store(true)
+	return context.WithValue(ctx, key{}, s)
+}
+
+func (r *Router) write(db *gorm.DB) {
+	if s, ok := db.Statement.Context.Value(key{}).(*session); ok {
+		s.written.Store(true)
+	}
+
+	if !inTransaction(db) {
+		db.Statement.ConnPool = r.primary
+	}
+}
+
+func (r *Router) read(db *gorm.DB) {
+	if inTransaction(db) {
+		return
+	}
+
+	if s, ok := db.Statement.Context.Value(key{}).(*session); ok && s.written.Load() {
+		db.Statement.ConnPool = r.primary
+		return
+	}
+
+	// the locking reads and the raw statements that aren't a select
+	// stay in the primary
+	_, locking := db.Statement.Clauses["FOR"]
+	if sql := strings.TrimSpace(db.Statement.SQL.String()); locking || (sql != "" && !isSelect(sql)) {
+		db.Statement.ConnPool = r.primary
+		return
+	}
+
+	db.Statement.ConnPool = r.pick()
+}
+
+// pick returns the next healthy replica, or the primary when every replica
+// is down. The replicas checked more than CheckInterval ago are checked
+// again in the background
+func (r *Router) pick() gorm.ConnPool {
+	n := len(r.replicas)
+	start := int(r.next.Add(1) % uint64(max(n, 1)))
+
+	for i := 0; i < n; i++ {
+		rep := r.replicas[(start+i)%n]
+
+		rep.mu.Lock()
+		pool, healthy := rep.pool, rep.healthy
+		stale := !rep.checking && time.Since(rep.checked) > r.config.CheckInterval
+		if stale {
+			rep.checking = true
+		}
+		rep.mu.Unlock()
+
+		if stale {
+			go r.check(rep)
+		}
+
+		if healthy {
+			return pool
+		}
+	}
+
+	return r.primary
+}
+
+func (r *Router) check(rep *replica) {
+	pool, err := r.ping(rep)
+
+	rep.mu.Lock()
+	changed := rep.healthy != (err == nil) || rep.checked.IsZero()
+	rep.pool, rep.healthy, rep.checked, rep.checking = pool, err == nil, time.Now(), false
+	rep.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if err != nil {
+		r.logger.Error(fmt.Errorf("replica %d is down, its reads go to the other replicas or the primary: %w", rep.id, err))
+		return
+	}
+	r.logger.Info(fmt.Sprintf("replica %d is up", rep.id))
+}
+
+// ping opens the replica when it isn't open and pings it
+func (r *Router) ping(rep *replica) (gorm.ConnPool, error) {
+	rep.mu.Lock()
+	pool := rep.pool
+	rep.mu.Unlock()
+
+	if pool == nil {
+		conn, err := gorm.Open(rep.dialector, r.gorm)
+		if err != nil {
+			return nil, err
+		}
+		pool = conn.Config.ConnPool
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.config.CheckInterval)
+	defer cancel()
+
+	if pinger, ok := pool.(interface{ PingContext(context.Context) error }); ok {
+		return pool, pinger.PingContext(ctx)
+	}
+
+	return pool, nil
+}
+
+func inTransaction(db *gorm.DB) bool {
+	_, ok := db.Statement.ConnPool.(gorm.TxCommitter)
+	return ok
+}
+
+func isSelect(sql string) bool {
+	return len(sql) > 6 && strings.EqualFold(sql[:6], "select") && !strings.HasSuffix(strings.ToLower(sql), "for update")
+}
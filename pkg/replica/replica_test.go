@@ -0,0 +1,167 @@
+package replica_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/pkg/bootstrap"
+	"github.com/ncostamagna/axul-user/pkg/cache"
+	"github.com/ncostamagna/axul-user/pkg/replica"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	domain "github.com/ncostamagna/axul_domain/domain/user"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// open returns the migrated SQLite database of the file, the primary and
+// the replicas are different files so the test sees where a read went
+func open(t *testing.T, path string) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := bootstrap.NewMigrator(db, loghub.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+
+	return db
+}
+
+// newRepository returns the user repository of a primary with a replica
+// in the file, the primary has a user and the replica is empty
+func newRepository(t *testing.T, replicaPath string, interval time.Duration) (user.Repository, *gorm.DB) {
+	t.Helper()
+
+	db := open(t, filepath.Join(t.TempDir(), "primary.db"))
+	if err := db.Use(replica.New(replica.Config{Replicas: []gorm.Dialector{sqlite.Open(replicaPath)}, CheckInterval: interval}, loghub.New())); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := user.NewRepository(db, loghub.New())
+	create(t, context.Background(), repo, "john")
+	return repo, db
+}
+
+func create(t *testing.T, ctx context.Context, repo user.Repository, userName string) {
+	t.Helper()
+
+	if err := repo.Create(ctx, &domain.User{UserName: userName, Email: userName + "@example.com", Language: "en"}, nil); err != nil {
+		t.Fatalf("create %s: %v", userName, err)
+	}
+}
+
+func count(t *testing.T, ctx context.Context, repo user.Repository) int {
+	t.Helper()
+
+	n, err := repo.Count(ctx, user.Filters{})
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	return n
+}
+
+func TestReadsGoToTheReplica(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replica.db")
+	open(t, path)
+	repo, _ := newRepository(t, path, 0)
+
+	if n := count(t, context.Background(), repo); n != 0 {
+		t.Errorf("count returned %d users, want the 0 of the replica", n)
+	}
+}
+
+func TestSessionReadsItsWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replica.db")
+	open(t, path)
+	repo, _ := newRepository(t, path, 0)
+
+	ctx := replica.Session(context.Background())
+	if n := count(t, ctx, repo); n != 0 {
+		t.Errorf("count before a write returned %d users, want the 0 of the replica", n)
+	}
+
+	create(t, ctx, repo, "jane")
+	if n := count(t, ctx, repo); n != 2 {
+		t.Errorf("count after a write returned %d users, want the 2 of the primary", n)
+	}
+
+	if n := count(t, context.Background(), repo); n != 0 {
+		t.Errorf("count out of the session returned %d users, want the 0 of the replica", n)
+	}
+}
+
+// the cache loads read the primary, a miss after a write can't cache the
+// value of a replica that is behind
+func TestCacheLoadsThePrimary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replica.db")
+	open(t, path)
+	repo, _ := newRepository(t, path, 0)
+
+	john, err := repo.GetByLogin(replica.Primary(context.Background()), "john")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cached := user.NewCachedRepository(repo, cache.NewStore("users", cache.NewLRU(10), time.Minute, loghub.New()))
+	if _, err := cached.Get(context.Background(), john.ID); err != nil {
+		t.Errorf("get of the cache returned %v, want the user of the primary", err)
+	}
+}
+
+func TestTransactionsReadThePrimary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replica.db")
+	open(t, path)
+	repo, db := newRepository(t, path, 0)
+
+	err := transaction.NewManager(db).Do(context.Background(), func(ctx context.Context) error {
+		if n := count(t, ctx, repo); n != 1 {
+			t.Errorf("count in a transaction returned %d users, want the 1 of the primary", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplicaDown(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "replica")
+	repo, _ := newRepository(t, filepath.Join(dir, "replica.db"), 50*time.Millisecond)
+
+	if n := count(t, context.Background(), repo); n != 1 {
+		t.Errorf("count with the replica down returned %d users, want the 1 of the primary", n)
+	}
+
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	open(t, filepath.Join(dir, "replica.db"))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for count(t, context.Background(), repo) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the reads didn't go back to the replica when it was up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
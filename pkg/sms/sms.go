@@ -0,0 +1,26 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+)
+
+// Sender sends the text messages of the service, to is an E.164 number
+type Sender interface {
+	Send(ctx context.Context, to, body string) error
+}
+
+type logSender struct {
+	logger loghub.Logger
+}
+
+// NewLogSender only logs the messages, it's used in local environments
+func NewLogSender(logger loghub.Logger) Sender {
+	return &logSender{logger}
+}
+
+func (s *logSender) Send(_ context.Context, to, body string) error {
+	s.logger.Info(fmt.Sprintf("sms to %s: %s", to, body))
+	return nil
+}
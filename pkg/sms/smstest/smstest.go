@@ -0,0 +1,27 @@
+// Package smstest has the fake SMS sender of the tests
+package smstest
+
+import (
+	"context"
+	"sync"
+)
+
+// Phone is an sms.Sender that keeps the messages instead of sending them
+type Phone struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (p *Phone) Send(_ context.Context, _, body string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = append(p.messages, body)
+	return nil
+}
+
+// Messages returns the bodies of the messages sent, the oldest first
+func (p *Phone) Messages() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.messages...)
+}
@@ -0,0 +1,85 @@
+// Package transaction runs the calls of several repositories in a single
+// database transaction, the transaction goes to the repositories in the
+// context
+package transaction
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+type key struct{}
+
+type hooksKey struct{}
+
+// Manager runs fn in a transaction, it's committed when fn returns nil and
+// rolled back when fn returns an error or panics. The repositories get the
+// transaction from the context of fn with DB
+type Manager interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type manager struct {
+	db *gorm.DB
+}
+
+// NewManager returns the Manager of the repositories of db
+func NewManager(db *gorm.DB) Manager {
+	return &manager{db}
+}
+
+// Do starts a transaction, or a savepoint when ctx already has one, so a
+// nested Do that fails only rolls back its own calls
+func (m *manager) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	var hooks []func()
+	err := DB(ctx, m.db).Transaction(func(tx *gorm.DB) error {
+		ctx := context.WithValue(ctx, key{}, tx)
+		return fn(context.WithValue(ctx, hooksKey{}, &hooks))
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		AfterCommit(ctx, hook)
+	}
+
+	return nil
+}
+
+// Active reports whether ctx has a transaction
+func Active(ctx context.Context) bool {
+	_, ok := ctx.Value(key{}).(*gorm.DB)
+	return ok
+}
+
+// AfterCommit runs fn when the transaction of ctx is committed, or now when
+// ctx has no transaction. fn doesn't run when the transaction, or the
+// nested Do that added it, is rolled back
+func AfterCommit(ctx context.Context, fn func()) {
+	if hooks, ok := ctx.Value(hooksKey{}).(*[]func()); ok {
+		*hooks = append(*hooks, fn)
+		return
+	}
+
+	fn()
+}
+
+// DB returns the transaction of ctx, or db when there is none, with ctx
+func DB(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(key{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+
+	return db.WithContext(ctx)
+}
+
+// None runs fn without a transaction, it's the Manager of the repositories
+// that aren't a database, like the fakes of the tests
+var None Manager = none{}
+
+type none struct{}
+
+func (none) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
@@ -0,0 +1,159 @@
+package transaction_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ncostamagna/axul-user/internal/user"
+	"github.com/ncostamagna/axul-user/internal/user/role"
+	"github.com/ncostamagna/axul-user/pkg/dbtest"
+	"github.com/ncostamagna/axul-user/pkg/transaction"
+	domain "github.com/ncostamagna/axul_domain/domain/user"
+	"github.com/ncostamagna/go-logger-hub/loghub"
+)
+
+type repos struct {
+	tx    transaction.Manager
+	users user.Repository
+	roles role.Repository
+}
+
+func newRepos(t *testing.T) repos {
+	db := dbtest.Open(t)
+	return repos{transaction.NewManager(db), user.NewRepository(db, loghub.New()), role.NewRepository(db, loghub.New())}
+}
+
+// create adds a user and its role in the transaction of ctx, if any
+func (r repos) create(ctx context.Context, userName string) (*domain.User, error) {
+	u := &domain.User{UserName: userName, Email: userName + "@example.com", Language: "en"}
+	if err := r.users.Create(ctx, u, nil); err != nil {
+		return nil, err
+	}
+
+	return u, r.roles.Create(ctx, &domain.Role{UserID: u.ID, App: "crm"})
+}
+
+// count returns the users and the roles of the database
+func (r repos) count(t *testing.T) (int, int) {
+	t.Helper()
+
+	ctx := context.Background()
+	users, err := r.users.Count(ctx, user.Filters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles, err := r.roles.Count(ctx, role.Filters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return users, roles
+}
+
+func TestManagerCommits(t *testing.T) {
+	r := newRepos(t)
+
+	err := r.tx.Do(context.Background(), func(ctx context.Context) error {
+		_, err := r.create(ctx, "john")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("do: %v", err)
+	}
+
+	if users, roles := r.count(t); users != 1 || roles != 1 {
+		t.Errorf("there are %d users and %d roles after the commit, want 1 and 1", users, roles)
+	}
+}
+
+func TestManagerRollsBackOnError(t *testing.T) {
+	r := newRepos(t)
+	failed := errors.New("failed")
+
+	err := r.tx.Do(context.Background(), func(ctx context.Context) error {
+		if _, err := r.create(ctx, "john"); err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("do returned %v, want the error of fn", err)
+	}
+
+	if users, roles := r.count(t); users != 0 || roles != 0 {
+		t.Errorf("there are %d users and %d roles after the rollback, want none", users, roles)
+	}
+}
+
+func TestManagerRollsBackOnPanic(t *testing.T) {
+	r := newRepos(t)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("do didn't panic again")
+			}
+		}()
+
+		_ = r.tx.Do(context.Background(), func(ctx context.Context) error {
+			if _, err := r.create(ctx, "john"); err != nil {
+				return err
+			}
+			panic("failed")
+		})
+	}()
+
+	if users, roles := r.count(t); users != 0 || roles != 0 {
+		t.Errorf("there are %d users and %d roles after the panic, want none", users, roles)
+	}
+}
+
+func TestManagerNested(t *testing.T) {
+	r := newRepos(t)
+
+	err := r.tx.Do(context.Background(), func(ctx context.Context) error {
+		if _, err := r.create(ctx, "john"); err != nil {
+			return err
+		}
+
+		nested := r.tx.Do(ctx, func(ctx context.Context) error {
+			if _, err := r.create(ctx, "jane"); err != nil {
+				return err
+			}
+			return errors.New("failed")
+		})
+		if nested == nil {
+			t.Error("the nested do didn't fail")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("do: %v", err)
+	}
+
+	if users, roles := r.count(t); users != 1 || roles != 1 {
+		t.Errorf("there are %d users and %d roles, want only the ones of the outer transaction", users, roles)
+	}
+}
+
+func TestNone(t *testing.T) {
+	r := newRepos(t)
+	failed := errors.New("failed")
+
+	err := transaction.None.Do(context.Background(), func(ctx context.Context) error {
+		if _, err := r.create(ctx, "john"); err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("do returned %v, want the error of fn", err)
+	}
+
+	if users, roles := r.count(t); users != 1 || roles != 1 {
+		t.Errorf("there are %d users and %d roles, want the calls kept without a transaction", users, roles)
+	}
+}
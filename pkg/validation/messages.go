@@ -0,0 +1,57 @@
+package validation
+
+import "github.com/ncostamagna/axul-user/pkg/i18n"
+
+// the messages of the invalid requests, the field messages are rendered
+// by code with the FieldError Args
+func init() {
+	i18n.Register(
+		i18n.Message{Code: "INVALID_FIELDS", Text: map[string]string{
+			i18n.English: "invalid fields: %s",
+			i18n.Spanish: "campos inválidos: %s",
+		}},
+		i18n.Message{Code: "REQUEST_TOO_LARGE", Text: map[string]string{
+			i18n.English: "the request body is larger than %d bytes",
+			i18n.Spanish: "el cuerpo de la solicitud supera los %d bytes",
+		}},
+		i18n.Message{Code: "IF_MATCH_REQUIRED", Text: map[string]string{
+			i18n.English: "the If-Match header is required, send the ETag of the last GET",
+			i18n.Spanish: "el header If-Match es obligatorio, envía el ETag del último GET",
+		}},
+		i18n.Message{Code: "IF_MATCH_INVALID", Text: map[string]string{
+			i18n.English: "the If-Match header %s isn't an ETag of this resource",
+			i18n.Spanish: "el header If-Match %s no es un ETag de este recurso",
+		}},
+	)
+
+	i18n.RegisterDetails(
+		i18n.Message{Code: Required, Text: map[string]string{
+			i18n.English: "%s is required",
+			i18n.Spanish: "%s es obligatorio",
+		}},
+		i18n.Message{Code: InvalidEmail, Text: map[string]string{
+			i18n.English: "%s must be a valid email",
+			i18n.Spanish: "%s debe ser un email válido",
+		}},
+		i18n.Message{Code: TooShort, Text: map[string]string{
+			i18n.English: "%s must have at least %s characters",
+			i18n.Spanish: "%s debe tener al menos %s caracteres",
+		}},
+		i18n.Message{Code: TooLong, Text: map[string]string{
+			i18n.English: "%s must have at most %s characters",
+			i18n.Spanish: "%s debe tener como máximo %s caracteres",
+		}},
+		i18n.Message{Code: InvalidValue, Text: map[string]string{
+			i18n.English: "%s must be one of %s",
+			i18n.Spanish: "%s debe ser uno de %s",
+		}},
+		i18n.Message{Code: UnknownField, Text: map[string]string{
+			i18n.English: "%s isn't a field of the request",
+			i18n.Spanish: "%s no es un campo de la solicitud",
+		}},
+		i18n.Message{Code: Invalid, Text: map[string]string{
+			i18n.English: "%s isn't valid",
+			i18n.Spanish: "%s no es válido",
+		}},
+	)
+}
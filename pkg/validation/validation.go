@@ -0,0 +1,151 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// the codes of the field errors, the messages are in messages.go
+const (
+	Required     = "REQUIRED"
+	InvalidEmail = "INVALID_EMAIL"
+	TooShort     = "TOO_SHORT"
+	TooLong      = "TOO_LONG"
+	InvalidValue = "INVALID_VALUE"
+	UnknownField = "UNKNOWN_FIELD"
+	Invalid      = "INVALID"
+)
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+
+	// the fields are reported with their json names, the fields that
+	// aren't decoded from the body keep the struct name
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			return ""
+		case "":
+			return f.Name
+		}
+		return name
+	})
+
+	return v
+}
+
+// FieldError is a field that failed its rules, Param is the value of the
+// rule, like the length of min and max or the values of oneof
+type FieldError struct {
+	Field string `json:"field"`
+	Code  string `json:"code"`
+	Param string `json:"param,omitempty"`
+}
+
+// Error is the error of an invalid request, it's a 400 response with the
+// invalid fields
+type Error struct {
+	Fields []FieldError
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid fields: %s", e.Args()...)
+}
+
+func (e *Error) Code() string {
+	return "INVALID_FIELDS"
+}
+
+// Args are the arguments of the INVALID_FIELDS message, the names of the
+// fields
+func (e *Error) Args() []interface{} {
+	names := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		names = append(names, f.Field)
+	}
+
+	return []interface{}{strings.Join(names, ", ")}
+}
+
+func (e *Error) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+func (e *Error) GetBody() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+func (e *Error) GetData() interface{} {
+	return e.Fields
+}
+
+// Args are the arguments of the field message
+func (f FieldError) Args() []interface{} {
+	if f.Param == "" {
+		return []interface{}{f.Field}
+	}
+
+	return []interface{}{f.Field, strings.ReplaceAll(f.Param, " ", ", ")}
+}
+
+// Field returns the error of a single field
+func Field(name, code, param string) *Error {
+	return &Error{[]FieldError{{name, code, param}}}
+}
+
+// Struct checks the validate tags of a request, it returns an *Error
+// with every invalid field or nil
+func Struct(v interface{}) error {
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+
+	fields := make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		fields = append(fields, fieldError(fe))
+	}
+
+	return &Error{fields}
+}
+
+func fieldError(fe validator.FieldError) FieldError {
+	// the namespace has the struct name first, roles[0] is kept for the
+	// fields inside the slices
+	name := fe.Field()
+	if _, ns, ok := strings.Cut(fe.Namespace(), "."); ok {
+		name = ns
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return FieldError{name, Required, ""}
+	case "email":
+		return FieldError{name, InvalidEmail, ""}
+	case "min":
+		// an empty value of a field that must be sent
+		if fe.Param() == "1" && fe.Kind() == reflect.String {
+			return FieldError{name, Required, ""}
+		}
+		return FieldError{name, TooShort, fe.Param()}
+	case "max":
+		return FieldError{name, TooLong, fe.Param()}
+	case "oneof":
+		return FieldError{name, InvalidValue, fe.Param()}
+	}
+
+	return FieldError{name, Invalid, ""}
+}